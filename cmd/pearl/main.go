@@ -8,16 +8,28 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
 	"syscall"
 	"time"
 
+	"github.com/its-the-vibe/pearl/internal/annotations"
+	"github.com/its-the-vibe/pearl/internal/auth"
 	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+	"github.com/its-the-vibe/pearl/internal/calendar"
 	"github.com/its-the-vibe/pearl/internal/config"
+	"github.com/its-the-vibe/pearl/internal/digest"
+	"github.com/its-the-vibe/pearl/internal/mail"
+	"github.com/its-the-vibe/pearl/internal/stations"
+	"github.com/its-the-vibe/pearl/internal/tags"
 	"github.com/its-the-vibe/pearl/internal/web"
 )
 
 func main() {
 	configPath := flag.String("config", "/config.yaml", "path to configuration file")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-config path] [aggregate [-rebuild] | digest [-period week|month]]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
 	cfg, err := config.Load(*configPath)
@@ -29,33 +41,122 @@ func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
-	bqClient, err := bq.New(ctx, cfg.BigQuery.ProjectID, cfg.BigQuery.Dataset, cfg.BigQuery.RatingsDataset)
+	opts := bq.Options{
+		Limits: bq.QueryLimits{
+			MaxBytesBilled: cfg.BigQuery.MaxBytesBilled,
+			DryRun:         cfg.BigQuery.DryRun,
+		},
+		DailySummary: cfg.Summary.Enabled,
+	}
+	bqClient, err := bq.New(ctx, cfg.BigQuery.ProjectID, cfg.BigQuery.Dataset, cfg.BigQuery.RatingsDataset, opts)
 	if err != nil {
 		slog.Error("creating bigquery client", "error", err)
 		os.Exit(1)
 	}
 	defer bqClient.Close()
 
-	handler, err := web.NewHandler(bqClient)
+	cards := make([]web.Card, len(cfg.Cards))
+	for i, c := range cfg.Cards {
+		cards[i] = web.Card{
+			ID:     c.ID,
+			Name:   c.Name,
+			Client: bqClient.ForDataset(c.Dataset, c.RatingsDataset),
+			Users:  c.Users,
+		}
+	}
+
+	if flag.Arg(0) == "aggregate" {
+		if err := runAggregate(ctx, cards, flag.Args()[1:]); err != nil {
+			slog.Error("refreshing daily summary", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if flag.NArg() > 0 && flag.Arg(0) != "digest" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if cfg.Summary.RefreshInterval > 0 {
+		go refreshSummaryLoop(ctx, cards, cfg.Summary.RefreshInterval)
+	}
+
+	overrides := make(map[string]web.AttendanceOverride, len(cfg.Attendance.Overrides))
+	for _, o := range cfg.Attendance.Overrides {
+		overrides[o.Date] = web.AttendanceOverride{Office: o.InOffice(), Note: o.Note}
+	}
+	cal, err := loadCalendar(cfg.Calendar)
+	if err != nil {
+		slog.Error("loading calendar", "error", err)
+		os.Exit(1)
+	}
+	dir, err := loadStations(cfg.Stations)
+	if err != nil {
+		slog.Error("loading station data", "error", err)
+		os.Exit(1)
+	}
+	notes, err := openAnnotations(ctx, cfg.Annotations, bqClient)
+	if err != nil {
+		slog.Error("opening annotations", "error", err)
+		os.Exit(1)
+	}
+	rules, err := loadTags(cfg.Tags)
+	if err != nil {
+		slog.Error("loading tag rules", "error", err)
+		os.Exit(1)
+	}
+	handler, err := web.NewHandler(cards, web.Options{
+		Calendar:    cal,
+		Stations:    dir,
+		Annotations: notes,
+		Tags:        rules,
+		BaseURL:     cfg.Digest.BaseURL,
+		Attendance: web.AttendanceOptions{
+			WorkStations:  cfg.Attendance.WorkStations,
+			WeeklyTarget:  cfg.Attendance.WeeklyTarget,
+			MonthlyTarget: cfg.Attendance.MonthlyTarget,
+			Overrides:     overrides,
+		},
+	})
 	if err != nil {
 		slog.Error("creating web handler", "error", err)
 		os.Exit(1)
 	}
 
+	if flag.Arg(0) == "digest" {
+		if err := runDigest(ctx, handler, digestCards(cards, cfg.Digest.Cards), cfg.Digest, flag.Args()[1:]); err != nil {
+			slog.Error("sending digest", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(cfg.Digest.Periods) > 0 {
+		go sendDigestsLoop(ctx, handler, digestCards(cards, cfg.Digest.Cards), cfg.Digest)
+	}
+
+	authn, err := auth.New(ctx, cfg.Auth)
+	if err != nil {
+		slog.Error("configuring authentication", "error", err)
+		os.Exit(1)
+	}
+
 	mux := http.NewServeMux()
 	handler.RegisterRoutes(mux)
+	if authn != nil {
+		authn.RegisterRoutes(mux)
+	}
 
 	addr := fmt.Sprintf(":%d", cfg.Server.Port)
 	srv := &http.Server{
 		Addr:         addr,
-		Handler:      mux,
+		Handler:      auth.Middleware(authn, auth.PublicPaths(cfg.Auth), mux),
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 30 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
 
 	go func() {
-		slog.Info("starting server", "addr", addr)
+		slog.Info("starting server", "addr", addr, "auth", cfg.Auth.Mode)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			slog.Error("server error", "error", err)
 			os.Exit(1)
@@ -71,3 +172,236 @@ func main() {
 		slog.Error("server shutdown error", "error", err)
 	}
 }
+
+// runAggregate implements the "aggregate" subcommand, which refreshes the
+// daily summary table of every card once and exits.
+func runAggregate(ctx context.Context, cards []web.Card, args []string) error {
+	fs := flag.NewFlagSet("aggregate", flag.ExitOnError)
+	rebuild := fs.Bool("rebuild", false, "recreate the daily summary tables from scratch")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	for _, card := range cards {
+		result, err := card.Client.RefreshDailySummary(ctx, *rebuild)
+		if err != nil {
+			return fmt.Errorf("card %s: %w", card.ID, err)
+		}
+		slog.Info("daily summary refreshed",
+			"card", card.ID,
+			"rebuilt", result.Rebuilt,
+			"dates", result.Dates,
+			"late_dates", result.LateDates,
+		)
+	}
+	return nil
+}
+
+// refreshSummaryLoop refreshes every card's daily summary table every
+// interval until ctx is cancelled. Failures are logged and retried on the
+// next tick.
+func refreshSummaryLoop(ctx context.Context, cards []web.Card, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for _, card := range cards {
+			result, err := card.Client.RefreshDailySummary(ctx, false)
+			if err != nil {
+				slog.Error("refreshing daily summary", "card", card.ID, "error", err)
+			} else if result.Rebuilt || result.Dates > 0 {
+				slog.Info("daily summary refreshed",
+					"card", card.ID,
+					"rebuilt", result.Rebuilt,
+					"dates", result.Dates,
+					"late_dates", result.LateDates,
+				)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runDigest implements the "digest" subcommand, which sends the digest of
+// every card once and exits, such as to try the mail settings.
+func runDigest(ctx context.Context, handler *web.Handler, cards []web.Card, cfg config.Digest, args []string) error {
+	fs := flag.NewFlagSet("digest", flag.ExitOnError)
+	period := fs.String("period", config.DigestWeek, "digest to send: week or month")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *period != config.DigestWeek && *period != config.DigestMonth {
+		return fmt.Errorf("unknown period %q, use week or month", *period)
+	}
+	if cfg.SMTP.Host == "" || cfg.From == "" || len(cfg.To) == 0 {
+		return fmt.Errorf("digest.smtp.host, digest.from and digest.to are required")
+	}
+
+	sender := mail.New(cfg.SMTP, cfg.From)
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	for _, card := range cards {
+		if err := sendDigest(ctx, handler, sender, card, cfg.To, *period, today); err != nil {
+			return fmt.Errorf("card %s: %w", card.ID, err)
+		}
+	}
+	return nil
+}
+
+// sendDigestsLoop sends the configured digests of every card on schedule
+// until ctx is cancelled. Failures are logged and not retried.
+func sendDigestsLoop(ctx context.Context, handler *web.Handler, cards []web.Card, cfg config.Digest) {
+	sender := mail.New(cfg.SMTP, cfg.From)
+	schedule := digest.Schedule{Periods: cfg.Periods, At: cfg.SendTime()}
+	slog.Info("digests scheduled", "periods", cfg.Periods, "next", schedule.Next(time.Now()))
+	schedule.Run(ctx, func(ctx context.Context, period string, day time.Time) {
+		for _, card := range cards {
+			if err := sendDigest(ctx, handler, sender, card, cfg.To, period, day); err != nil {
+				slog.Error("sending digest", "card", card.ID, "period", period, "error", err)
+			}
+		}
+	})
+}
+
+// sendDigest renders the digest of card for period and mails it to to.
+func sendDigest(ctx context.Context, handler *web.Handler, sender *mail.Sender, card web.Card, to []string, period string, today time.Time) error {
+	subject, body, err := handler.Digest(bq.WithPage(ctx, "digest"), card, period, today)
+	if err != nil {
+		return err
+	}
+	if err := sender.Send(ctx, to, subject, body); err != nil {
+		return err
+	}
+	slog.Info("digest sent", "card", card.ID, "period", period, "subject", subject)
+	return nil
+}
+
+// digestCards returns the cards with the given IDs, or every card when ids is
+// empty.
+func digestCards(cards []web.Card, ids []string) []web.Card {
+	if len(ids) == 0 {
+		return cards
+	}
+	var selected []web.Card
+	for _, c := range cards {
+		if slices.Contains(ids, c.ID) {
+			selected = append(selected, c)
+		}
+	}
+	return selected
+}
+
+// loadCalendar builds the calendar of bank holidays and leave days from the
+// built-in or configured bank holiday list, the leave periods and the leave
+// iCalendar file.
+func loadCalendar(cfg config.Calendar) (*calendar.Calendar, error) {
+	var holidays []calendar.DayOff
+	if cfg.BankHolidaysFile != "" {
+		f, err := os.Open(cfg.BankHolidaysFile)
+		if err != nil {
+			return nil, fmt.Errorf("opening bank holidays file: %w", err)
+		}
+		defer f.Close()
+		if holidays, err = calendar.BankHolidays(f, cfg.Division); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if holidays, err = calendar.EmbeddedBankHolidays(cfg.Division); err != nil {
+			return nil, err
+		}
+	}
+
+	var leave []calendar.DayOff
+	for _, l := range cfg.Leave {
+		// Dates were validated by config.Load.
+		from, _ := time.Parse("2006-01-02", l.From)
+		to, _ := time.Parse("2006-01-02", l.To)
+		days, err := calendar.Leave(from, to, l.Note)
+		if err != nil {
+			return nil, err
+		}
+		leave = append(leave, days...)
+	}
+	if cfg.LeaveICal != "" {
+		f, err := os.Open(cfg.LeaveICal)
+		if err != nil {
+			return nil, fmt.Errorf("opening leave calendar: %w", err)
+		}
+		defer f.Close()
+		days, err := calendar.ParseICal(f)
+		if err != nil {
+			return nil, fmt.Errorf("parsing leave calendar: %w", err)
+		}
+		leave = append(leave, days...)
+	}
+
+	cal := calendar.New(holidays, leave)
+	slog.Info("calendar loaded", "division", cfg.Division, "days_off", cal.Len())
+	return cal, nil
+}
+
+// loadStations reads the configured station data file, falling back to the
+// built-in station list.
+func loadStations(cfg config.Stations) (*stations.Directory, error) {
+	if cfg.File == "" {
+		dir, err := stations.Embedded()
+		if err != nil {
+			return nil, err
+		}
+		slog.Info("station data loaded", "file", "built-in", "stations", dir.Len())
+		return dir, nil
+	}
+
+	f, err := os.Open(cfg.File)
+	if err != nil {
+		return nil, fmt.Errorf("opening station data file: %w", err)
+	}
+	defer f.Close()
+	dir, err := stations.Load(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", cfg.File, err)
+	}
+	slog.Info("station data loaded", "file", cfg.File, "stations", dir.Len())
+	return dir, nil
+}
+
+// loadTags builds the configured tag rules.
+func loadTags(cfg []config.TagRule) (tags.Rules, error) {
+	list := make([]tags.Rule, len(cfg))
+	for i, r := range cfg {
+		list[i] = r.Rule()
+	}
+	rules, err := tags.New(list)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("tag rules loaded", "rules", len(rules), "tags", rules.Tags())
+	return rules, nil
+}
+
+// openAnnotations returns the configured annotations store, or nil when
+// annotations are disabled.
+func openAnnotations(ctx context.Context, cfg config.Annotations, client *bq.Client) (annotations.Store, error) {
+	switch cfg.Backend {
+	case config.AnnotationsFile:
+		s, err := annotations.OpenFile(cfg.File)
+		if err != nil {
+			return nil, err
+		}
+		slog.Info("annotations loaded", "file", cfg.File, "annotations", s.Len())
+		return s, nil
+	case config.AnnotationsBigQuery:
+		s := client.Annotations()
+		if err := s.Init(ctx); err != nil {
+			return nil, err
+		}
+		slog.Info("annotations loaded", "backend", cfg.Backend)
+		return s, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,236 @@
+// Package annotations stores personal notes and tags attached to days or to
+// individual journeys, such as "strike" or "client visit", to explain
+// outliers long after the event.
+package annotations
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/its-the-vibe/pearl/internal/tags"
+)
+
+// ErrNotFound is returned by Store.Delete when no annotation has the ID.
+var ErrNotFound = errors.New("annotation not found")
+
+// maxNoteLength bounds the note of an annotation.
+const maxNoteLength = 1000
+
+// Annotation is a note and tags attached to a day or to one of its journeys.
+// A journey is identified by its start time and journey action, as the
+// journeys table has no key of its own.
+type Annotation struct {
+	ID        string    `json:"id"`
+	Card      string    `json:"card"` // ID of the card whose journeys are annotated
+	Date      time.Time `json:"date"`
+	StartTime string    `json:"start_time,omitempty"` // empty for a whole day
+	Action    string    `json:"action,omitempty"`
+	Note      string    `json:"note,omitempty"`
+	Tags      []string  `json:"tags,omitempty"`
+	Created   time.Time `json:"created"`
+}
+
+// Day returns the date of a as used in URLs, e.g. "2024-03-05".
+func (a Annotation) Day() string {
+	return a.Date.Format("2006-01-02")
+}
+
+// Journey reports whether a is attached to a single journey rather than to
+// the whole day.
+func (a Annotation) Journey() bool {
+	return a.StartTime != "" || a.Action != ""
+}
+
+// HasTag reports whether a is tagged with tag, ignoring case.
+func (a Annotation) HasTag(tag string) bool {
+	return slices.Contains(a.Tags, tags.NormalizeTag(tag))
+}
+
+// Validate reports whether a can be stored.
+func (a Annotation) Validate() error {
+	switch {
+	case a.Card == "":
+		return errors.New("annotation has no card")
+	case a.Date.IsZero():
+		return errors.New("annotation has no date")
+	case a.Note == "" && len(a.Tags) == 0:
+		return errors.New("add a note or at least one tag")
+	case len(a.Note) > maxNoteLength:
+		return fmt.Errorf("note is longer than %d characters", maxNoteLength)
+	}
+	return nil
+}
+
+// ParseTags splits a comma-separated list of tags, such as "strike, WFH",
+// into normalised tags without blanks or duplicates.
+func ParseTags(s string) []string {
+	var list []string
+	for _, t := range strings.Split(s, ",") {
+		if t = tags.NormalizeTag(t); t != "" && !slices.Contains(list, t) {
+			list = append(list, t)
+		}
+	}
+	return list
+}
+
+// NewID returns a random annotation ID.
+func NewID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Store persists annotations.
+type Store interface {
+	// List returns the annotations of card dated from from to to inclusive,
+	// ordered by date and start time. A zero from or to leaves that end of
+	// the range unbounded.
+	List(ctx context.Context, card string, from, to time.Time) ([]Annotation, error)
+	// Add stores a, assigning its ID and creation time, and returns it.
+	Add(ctx context.Context, a Annotation) (Annotation, error)
+	// Delete removes the annotation of card with the ID.
+	Delete(ctx context.Context, card, id string) error
+}
+
+// Prepare validates a and fills in its ID and creation time for storing. It
+// is called by Store implementations from Add.
+func Prepare(a Annotation, now time.Time) (Annotation, error) {
+	a.Date = a.Date.UTC().Truncate(24 * time.Hour)
+	a.Note = strings.TrimSpace(a.Note)
+	if err := a.Validate(); err != nil {
+		return Annotation{}, err
+	}
+	a.ID = NewID()
+	a.Created = now.UTC()
+	return a, nil
+}
+
+// inRange reports whether a is dated from from to to inclusive, where zero
+// bounds are unbounded.
+func inRange(a Annotation, from, to time.Time) bool {
+	return (from.IsZero() || !a.Date.Before(from)) && (to.IsZero() || !a.Date.After(to))
+}
+
+// sortAnnotations orders annotations by date, then day annotations before
+// those of journeys in order of start time.
+func sortAnnotations(list []Annotation) {
+	slices.SortStableFunc(list, func(a, b Annotation) int {
+		if c := a.Date.Compare(b.Date); c != 0 {
+			return c
+		}
+		return strings.Compare(a.StartTime, b.StartTime)
+	})
+}
+
+// FileStore keeps annotations in a JSON file, which suits a single Pearl
+// server. The file is rewritten in full on every change.
+type FileStore struct {
+	path string
+
+	mu   sync.Mutex
+	list []Annotation
+}
+
+// OpenFile returns a store backed by the JSON file at path, which is created
+// on the first change if it does not exist.
+func OpenFile(path string) (*FileStore, error) {
+	s := &FileStore{path: path}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading annotations file: %w", err)
+	}
+	if err := json.Unmarshal(b, &s.list); err != nil {
+		return nil, fmt.Errorf("parsing annotations file %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Len returns the number of annotations in s across all cards.
+func (s *FileStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.list)
+}
+
+func (s *FileStore) List(_ context.Context, card string, from, to time.Time) ([]Annotation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var list []Annotation
+	for _, a := range s.list {
+		if a.Card == card && inRange(a, from, to) {
+			list = append(list, a)
+		}
+	}
+	sortAnnotations(list)
+	return list, nil
+}
+
+func (s *FileStore) Add(_ context.Context, a Annotation) (Annotation, error) {
+	a, err := Prepare(a, time.Now())
+	if err != nil {
+		return Annotation{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := append(slices.Clip(s.list), a)
+	if err := s.save(list); err != nil {
+		return Annotation{}, err
+	}
+	s.list = list
+	return a, nil
+}
+
+func (s *FileStore) Delete(_ context.Context, card, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.list, func(a Annotation) bool { return a.Card == card && a.ID == id })
+	if i < 0 {
+		return ErrNotFound
+	}
+	list := slices.Delete(slices.Clone(s.list), i, i+1)
+	if err := s.save(list); err != nil {
+		return err
+	}
+	s.list = list
+	return nil
+}
+
+// save writes list to the file via a temporary file, so that a failed write
+// does not lose the existing annotations.
+func (s *FileStore) save(list []Annotation) error {
+	b, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding annotations: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".annotations-*.json")
+	if err != nil {
+		return fmt.Errorf("writing annotations file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(b, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing annotations file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing annotations file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("writing annotations file: %w", err)
+	}
+	return nil
+}
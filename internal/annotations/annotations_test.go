@@ -0,0 +1,123 @@
+package annotations
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"strike", []string{"strike"}},
+		{" Strike, WFH ,strike,, client visit", []string{"strike", "wfh", "client visit"}},
+		{"", nil},
+		{" , ", nil},
+	}
+	for _, tt := range tests {
+		if got := ParseTags(tt.in); !slices.Equal(got, tt.want) {
+			t.Errorf("ParseTags(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestAnnotationValidate(t *testing.T) {
+	valid := Annotation{Card: "a", Date: date("2024-03-05"), Tags: []string{"strike"}}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Validate() = %v, want nil", err)
+	}
+
+	for name, a := range map[string]Annotation{
+		"no card":          {Date: date("2024-03-05"), Note: "late"},
+		"no date":          {Card: "a", Note: "late"},
+		"no note nor tags": {Card: "a", Date: date("2024-03-05")},
+	} {
+		if err := a.Validate(); err == nil {
+			t.Errorf("%s: Validate() = nil, want an error", name)
+		}
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "annotations.json")
+	s, err := OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+
+	journey, err := s.Add(ctx, Annotation{Card: "a", Date: date("2024-03-05"), StartTime: "08:00", Action: "Clapham Common to Bank", Note: "signal failure"})
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if journey.ID == "" || journey.Created.IsZero() || !journey.Journey() {
+		t.Errorf("Add() = %+v, want an ID, creation time and journey", journey)
+	}
+	day, err := s.Add(ctx, Annotation{Card: "a", Date: date("2024-03-05"), Tags: []string{"strike"}})
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if _, err := s.Add(ctx, Annotation{Card: "b", Date: date("2024-03-06"), Tags: []string{"wfh"}}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if _, err := s.Add(ctx, Annotation{Card: "a", Date: date("2024-03-06")}); err == nil {
+		t.Errorf("Add(without note or tags) succeeded, want an error")
+	}
+
+	// Reopen to check the annotations were saved.
+	s, err = OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	if s.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", s.Len())
+	}
+	list, err := s.List(ctx, "a", date("2024-03-01"), date("2024-03-31"))
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != 2 || list[0].ID != day.ID || list[1].ID != journey.ID {
+		t.Errorf("List(a) = %+v, want the day annotation before the journey one", list)
+	}
+	if !list[0].HasTag("Strike") {
+		t.Errorf("HasTag(Strike) = false, want true")
+	}
+	if list, _ := s.List(ctx, "a", date("2024-03-06"), time.Time{}); len(list) != 0 {
+		t.Errorf("List(a, from 2024-03-06) = %+v, want none", list)
+	}
+
+	if err := s.Delete(ctx, "b", day.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete(another card's annotation) = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(ctx, "a", day.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	s, _ = OpenFile(path)
+	if list, _ := s.List(ctx, "a", time.Time{}, time.Time{}); len(list) != 1 || list[0].ID != journey.ID {
+		t.Errorf("List(a) after Delete = %+v, want the journey annotation only", list)
+	}
+}
+
+func TestOpenFileInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "annotations.json")
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenFile(path); err == nil {
+		t.Errorf("OpenFile(invalid JSON) succeeded, want an error")
+	}
+}
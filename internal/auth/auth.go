@@ -0,0 +1,99 @@
+// Package auth authenticates dashboard users according to the configured mode.
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/its-the-vibe/pearl/internal/config"
+)
+
+// User identifies an authenticated dashboard user.
+type User struct {
+	Name  string
+	Email string // empty when the auth mode does not provide one
+}
+
+type userKey struct{}
+
+// WithUser returns a context carrying the authenticated user.
+func WithUser(ctx context.Context, u User) context.Context {
+	return context.WithValue(ctx, userKey{}, u)
+}
+
+// UserFromContext returns the user attached by the auth middleware. ok is
+// false when authentication is disabled or the route is public.
+func UserFromContext(ctx context.Context) (u User, ok bool) {
+	u, ok = ctx.Value(userKey{}).(User)
+	return u, ok
+}
+
+// Authenticator identifies the user making a request.
+type Authenticator interface {
+	// Authenticate returns the user making r. When ok is false the
+	// authenticator has already written a response challenging the client.
+	Authenticate(w http.ResponseWriter, r *http.Request) (u User, ok bool)
+	// RegisterRoutes registers any endpoints the authenticator needs, such
+	// as login callbacks. They are served without authentication.
+	RegisterRoutes(mux *http.ServeMux)
+}
+
+// RoutePrefix is the path prefix under which authenticators register their
+// own endpoints. Requests under it bypass authentication.
+const RoutePrefix = "/auth/"
+
+// New returns the Authenticator for the configured mode, or nil when
+// authentication is disabled.
+func New(ctx context.Context, cfg config.Auth) (Authenticator, error) {
+	switch cfg.Mode {
+	case config.AuthModeNone, "":
+		return nil, nil
+	case config.AuthModeBasic:
+		return newBasic(cfg.Basic), nil
+	case config.AuthModeProxy:
+		return newProxy(cfg.Proxy)
+	case config.AuthModeOIDC:
+		return newOIDC(ctx, cfg.OIDC)
+	default:
+		return nil, fmt.Errorf("unknown auth mode %q", cfg.Mode)
+	}
+}
+
+// PublicPaths returns the exact paths that are served without authentication
+// according to the health and metrics access settings.
+func PublicPaths(cfg config.Auth) []string {
+	var paths []string
+	if cfg.Health != config.AccessProtected {
+		paths = append(paths, "/health")
+	}
+	if cfg.Metrics == config.AccessPublic {
+		paths = append(paths, "/admin/usage")
+	}
+	return paths
+}
+
+// Middleware authenticates every request with a before passing it to next,
+// except for publicPaths and the authenticator's own routes. A nil
+// Authenticator disables authentication.
+func Middleware(a Authenticator, publicPaths []string, next http.Handler) http.Handler {
+	if a == nil {
+		return next
+	}
+	public := make(map[string]bool, len(publicPaths))
+	for _, p := range publicPaths {
+		public[p] = true
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if public[r.URL.Path] || strings.HasPrefix(r.URL.Path, RoutePrefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		u, ok := a.Authenticate(w, r)
+		if !ok {
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithUser(r.Context(), u)))
+	})
+}
@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/its-the-vibe/pearl/internal/config"
+)
+
+// echoUser is a handler that writes the authenticated user's name.
+var echoUser = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	u, _ := UserFromContext(r.Context())
+	w.Write([]byte(u.Name))
+})
+
+func TestMiddleware_NilAuthenticatorDisablesAuth(t *testing.T) {
+	h := Middleware(nil, nil, echoUser)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200", rec.Code)
+	}
+}
+
+func TestPublicPaths(t *testing.T) {
+	paths := PublicPaths(config.Auth{Health: config.AccessPublic, Metrics: config.AccessProtected})
+	if len(paths) != 1 || paths[0] != "/health" {
+		t.Errorf("PublicPaths = %v, want [/health]", paths)
+	}
+	paths = PublicPaths(config.Auth{Health: config.AccessProtected, Metrics: config.AccessPublic})
+	if len(paths) != 1 || paths[0] != "/admin/usage" {
+		t.Errorf("PublicPaths = %v, want [/admin/usage]", paths)
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hashing password: %v", err)
+	}
+	a := newBasic(config.BasicAuth{Realm: "Pearl", Users: map[string]string{"alice": string(hash)}})
+	h := Middleware(a, []string{"/health"}, echoUser)
+
+	tests := []struct {
+		name       string
+		path       string
+		user, pass string
+		wantCode   int
+		wantBody   string
+	}{
+		{"valid credentials", "/", "alice", "s3cret", http.StatusOK, "alice"},
+		{"wrong password", "/", "alice", "nope", http.StatusUnauthorized, ""},
+		{"unknown user", "/", "mallory", "s3cret", http.StatusUnauthorized, ""},
+		{"no credentials", "/", "", "", http.StatusUnauthorized, ""},
+		{"public path", "/health", "", "", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.user != "" {
+				req.SetBasicAuth(tt.user, tt.pass)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if tt.wantCode == http.StatusOK && rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+			if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("expected a WWW-Authenticate challenge")
+			}
+		})
+	}
+}
+
+func TestProxyAuth(t *testing.T) {
+	a, err := newProxy(config.ProxyAuth{
+		UserHeader:     "X-Forwarded-User",
+		EmailHeader:    "X-Forwarded-Email",
+		TrustedProxies: []string{"10.0.0.0/8"},
+	})
+	if err != nil {
+		t.Fatalf("newProxy: %v", err)
+	}
+	h := Middleware(a, nil, echoUser)
+
+	tests := []struct {
+		name     string
+		remote   string
+		user     string
+		wantCode int
+	}{
+		{"trusted proxy with user", "10.1.2.3:5555", "bob", http.StatusOK},
+		{"trusted proxy without user", "10.1.2.3:5555", "", http.StatusUnauthorized},
+		{"untrusted peer", "203.0.113.9:5555", "bob", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remote
+			if tt.user != "" {
+				req.Header.Set("X-Forwarded-User", tt.user)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestNewProxy_InvalidCIDR(t *testing.T) {
+	if _, err := newProxy(config.ProxyAuth{TrustedProxies: []string{"not-a-cidr"}}); err == nil {
+		t.Fatal("newProxy expected an error for an invalid CIDR, got nil")
+	}
+}
+
+func TestSessionCodec(t *testing.T) {
+	c := sessionCodec{key: []byte("0123456789abcdef0123456789abcdef")}
+	now := time.Now()
+
+	v, err := c.encode(sessionPayload{Name: "alice", Email: "alice@example.com", Expires: now.Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	p, err := c.decode(v, now)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if p.Name != "alice" || p.Email != "alice@example.com" {
+		t.Errorf("decoded payload = %+v", p)
+	}
+
+	if _, err := c.decode(v, now.Add(2*time.Hour)); err == nil {
+		t.Error("decode accepted an expired session")
+	}
+	if _, err := c.decode(v+"x", now); err == nil {
+		t.Error("decode accepted a tampered signature")
+	}
+	other := sessionCodec{key: []byte("fedcba9876543210fedcba9876543210")}
+	if _, err := other.decode(v, now); err == nil {
+		t.Error("decode accepted a session signed with a different key")
+	}
+}
+
+func TestSafeRedirect(t *testing.T) {
+	tests := map[string]string{
+		"/commutes?days=7":     "/commutes?days=7",
+		"":                     "/",
+		"https://evil.example": "/",
+		"//evil.example":       "/",
+		"/\\evil.example":      "/",
+	}
+	for in, want := range tests {
+		if got := safeRedirect(in); got != want {
+			t.Errorf("safeRedirect(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestNew_NoneReturnsNil(t *testing.T) {
+	a, err := New(context.Background(), config.Auth{Mode: config.AuthModeNone})
+	if err != nil || a != nil {
+		t.Errorf("New(none) = %v, %v; want nil, nil", a, err)
+	}
+}
@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/its-the-vibe/pearl/internal/config"
+)
+
+// dummyHash is compared against when the username is unknown so that the
+// response time does not reveal which usernames exist.
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("pearl"), bcrypt.DefaultCost)
+	return hash
+})
+
+type basicAuth struct {
+	realm string
+	users map[string][]byte
+}
+
+func newBasic(cfg config.BasicAuth) *basicAuth {
+	users := make(map[string][]byte, len(cfg.Users))
+	for name, hash := range cfg.Users {
+		users[name] = []byte(hash)
+	}
+	return &basicAuth{realm: cfg.Realm, users: users}
+}
+
+func (b *basicAuth) Authenticate(w http.ResponseWriter, r *http.Request) (User, bool) {
+	if name, password, ok := r.BasicAuth(); ok {
+		hash, known := b.users[name]
+		if !known {
+			hash = dummyHash()
+		}
+		if bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil && known {
+			return User{Name: name}, true
+		}
+	}
+
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", b.realm))
+	http.Error(w, "authentication required", http.StatusUnauthorized)
+	return User{}, false
+}
+
+func (b *basicAuth) RegisterRoutes(*http.ServeMux) {}
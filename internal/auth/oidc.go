@@ -0,0 +1,304 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/its-the-vibe/pearl/internal/config"
+)
+
+const (
+	sessionCookie    = "pearl_session"
+	loginStateCookie = "pearl_login"
+	loginStateTTL    = 10 * time.Minute
+)
+
+// discoveryDocument holds the fields Pearl uses from an OpenID Provider's
+// /.well-known/openid-configuration document.
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+}
+
+// userInfo holds the claims Pearl reads from the provider's userinfo endpoint.
+type userInfo struct {
+	Subject           string `json:"sub"`
+	Email             string `json:"email"`
+	EmailVerified     *bool  `json:"email_verified"`
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+}
+
+// oidcAuth implements the authorization code flow with PKCE. The user's
+// identity is read from the provider's userinfo endpoint over TLS using the
+// access token, and kept in a signed session cookie.
+type oidcAuth struct {
+	oauth        *oauth2.Config
+	userinfoURL  string
+	callbackPath string
+	allowed      map[string]bool
+	sessions     sessionCodec
+	loginStates  sessionCodec
+	ttl          time.Duration
+	secure       bool
+	httpClient   *http.Client
+}
+
+func newOIDC(ctx context.Context, cfg config.OIDCAuth) (*oidcAuth, error) {
+	redirect, err := url.Parse(cfg.RedirectURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing oidc redirect_url: %w", err)
+	}
+	if !strings.HasPrefix(redirect.Path, RoutePrefix) {
+		return nil, fmt.Errorf("oidc redirect_url path must start with %s, got %q", RoutePrefix, redirect.Path)
+	}
+
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	doc, err := discover(ctx, httpClient, cfg.IssuerURL)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := make(map[string]bool, len(cfg.AllowedEmails))
+	for _, email := range cfg.AllowedEmails {
+		allowed[strings.ToLower(email)] = true
+	}
+
+	return &oidcAuth{
+		oauth: &oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       cfg.Scopes,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  doc.AuthorizationEndpoint,
+				TokenURL: doc.TokenEndpoint,
+			},
+		},
+		userinfoURL:  doc.UserinfoEndpoint,
+		callbackPath: redirect.Path,
+		allowed:      allowed,
+		sessions:     newSessionCodec(cfg.SessionSecret, sessionCookie),
+		loginStates:  newSessionCodec(cfg.SessionSecret, loginStateCookie),
+		ttl:          cfg.SessionTTL,
+		secure:       redirect.Scheme == "https",
+		httpClient:   httpClient,
+	}, nil
+}
+
+// discover fetches the provider's discovery document.
+func discover(ctx context.Context, client *http.Client, issuer string) (discoveryDocument, error) {
+	wellKnown := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnown, nil)
+	if err != nil {
+		return discoveryDocument{}, fmt.Errorf("building oidc discovery request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return discoveryDocument{}, fmt.Errorf("fetching oidc discovery document: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return discoveryDocument{}, fmt.Errorf("fetching oidc discovery document: %s", resp.Status)
+	}
+
+	var doc discoveryDocument
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return discoveryDocument{}, fmt.Errorf("parsing oidc discovery document: %w", err)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.UserinfoEndpoint == "" {
+		return discoveryDocument{}, fmt.Errorf("oidc discovery document for %s is missing endpoints", issuer)
+	}
+	return doc, nil
+}
+
+func (o *oidcAuth) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc(RoutePrefix+"login", o.handleLogin)
+	mux.HandleFunc(o.callbackPath, o.handleCallback)
+	mux.HandleFunc(RoutePrefix+"logout", o.handleLogout)
+}
+
+func (o *oidcAuth) Authenticate(w http.ResponseWriter, r *http.Request) (User, bool) {
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		// Sessions always name the user and never carry login state.
+		if p, err := o.sessions.decode(c.Value, time.Now()); err == nil && p.Name != "" && p.Extra == "" {
+			return User{Name: p.Name, Email: p.Email}, true
+		}
+	}
+
+	if r.Method == http.MethodGet && !strings.HasPrefix(r.URL.Path, "/api/") {
+		http.Redirect(w, r, RoutePrefix+"login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusFound)
+	} else {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+	}
+	return User{}, false
+}
+
+func (o *oidcAuth) handleLogin(w http.ResponseWriter, r *http.Request) {
+	state := randomString()
+	verifier := oauth2.GenerateVerifier()
+	next := safeRedirect(r.URL.Query().Get("next"))
+
+	value, err := o.loginStates.encode(sessionPayload{
+		Expires: time.Now().Add(loginStateTTL).Unix(),
+		Extra:   strings.Join([]string{state, verifier, next}, "\n"),
+	})
+	if err != nil {
+		slog.Error("encoding login state", "error", err)
+		http.Error(w, "failed to start login", http.StatusInternalServerError)
+		return
+	}
+	o.setCookie(w, loginStateCookie, value, int(loginStateTTL.Seconds()))
+	http.Redirect(w, r, o.oauth.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier)), http.StatusFound)
+}
+
+func (o *oidcAuth) handleCallback(w http.ResponseWriter, r *http.Request) {
+	c, err := r.Cookie(loginStateCookie)
+	if err != nil {
+		http.Error(w, "login session expired, please try again", http.StatusBadRequest)
+		return
+	}
+	p, err := o.loginStates.decode(c.Value, time.Now())
+	if err != nil {
+		http.Error(w, "login session expired, please try again", http.StatusBadRequest)
+		return
+	}
+	parts := strings.SplitN(p.Extra, "\n", 3)
+	if len(parts) != 3 || r.URL.Query().Get("state") != parts[0] {
+		http.Error(w, "invalid login state", http.StatusBadRequest)
+		return
+	}
+	verifier, next := parts[1], parts[2]
+	o.setCookie(w, loginStateCookie, "", -1)
+
+	if e := r.URL.Query().Get("error"); e != "" {
+		http.Error(w, "login failed: "+e, http.StatusForbidden)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), oauth2.HTTPClient, o.httpClient)
+	token, err := o.oauth.Exchange(ctx, r.URL.Query().Get("code"), oauth2.VerifierOption(verifier))
+	if err != nil {
+		slog.Warn("exchanging oidc code", "error", err)
+		http.Error(w, "login failed", http.StatusForbidden)
+		return
+	}
+	info, err := o.fetchUserInfo(ctx, token)
+	if err != nil {
+		slog.Warn("fetching oidc userinfo", "error", err)
+		http.Error(w, "login failed", http.StatusForbidden)
+		return
+	}
+
+	u, err := o.authorize(info)
+	if err != nil {
+		slog.Warn("oidc login rejected", "email", info.Email, "error", err)
+		http.Error(w, "you are not allowed to access Pearl", http.StatusForbidden)
+		return
+	}
+
+	value, err := o.sessions.encode(sessionPayload{
+		Name:    u.Name,
+		Email:   u.Email,
+		Expires: time.Now().Add(o.ttl).Unix(),
+	})
+	if err != nil {
+		slog.Error("encoding session", "error", err)
+		http.Error(w, "login failed", http.StatusInternalServerError)
+		return
+	}
+	o.setCookie(w, sessionCookie, value, int(o.ttl.Seconds()))
+	http.Redirect(w, r, next, http.StatusFound)
+}
+
+func (o *oidcAuth) handleLogout(w http.ResponseWriter, r *http.Request) {
+	o.setCookie(w, sessionCookie, "", -1)
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+func (o *oidcAuth) fetchUserInfo(ctx context.Context, token *oauth2.Token) (userInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.userinfoURL, nil)
+	if err != nil {
+		return userInfo{}, err
+	}
+	resp, err := o.oauth.Client(ctx, token).Do(req)
+	if err != nil {
+		return userInfo{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return userInfo{}, fmt.Errorf("userinfo endpoint returned %s", resp.Status)
+	}
+	var info userInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return userInfo{}, fmt.Errorf("parsing userinfo: %w", err)
+	}
+	return info, nil
+}
+
+// authorize checks info against the allowed emails and returns the user.
+func (o *oidcAuth) authorize(info userInfo) (User, error) {
+	if info.Subject == "" {
+		return User{}, fmt.Errorf("userinfo has no subject")
+	}
+	if len(o.allowed) > 0 {
+		if info.EmailVerified != nil && !*info.EmailVerified {
+			return User{}, fmt.Errorf("email %q is not verified", info.Email)
+		}
+		if !o.allowed[strings.ToLower(info.Email)] {
+			return User{}, fmt.Errorf("email %q is not allowed", info.Email)
+		}
+	}
+
+	name := info.PreferredUsername
+	if name == "" {
+		name = info.Email
+	}
+	if name == "" {
+		name = info.Name
+	}
+	if name == "" {
+		name = info.Subject
+	}
+	return User{Name: name, Email: info.Email}, nil
+}
+
+// setCookie sets a session cookie; a negative maxAge deletes it.
+func (o *oidcAuth) setCookie(w http.ResponseWriter, name, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   o.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// safeRedirect returns next if it is a local path, or "/" otherwise, so that
+// the login flow cannot be used as an open redirect.
+func safeRedirect(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
+
+func randomString() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
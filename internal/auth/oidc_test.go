@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/its-the-vibe/pearl/internal/config"
+)
+
+// fakeProvider serves the discovery, token and userinfo endpoints of a
+// minimal OpenID Provider that always authenticates email.
+func fakeProvider(t *testing.T, email string) *httptest.Server {
+	t.Helper()
+	var srv *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, _ *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 srv.URL,
+			"authorization_endpoint": srv.URL + "/authorize",
+			"token_endpoint":         srv.URL + "/token",
+			"userinfo_endpoint":      srv.URL + "/userinfo",
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "good-code" || r.FormValue("code_verifier") == "" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"access_token": "tok", "token_type": "Bearer", "expires_in": 3600})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"sub": "123", "email": email, "email_verified": true})
+	})
+	srv = httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestOIDC(t *testing.T, issuer string, allowed ...string) *oidcAuth {
+	t.Helper()
+	a, err := newOIDC(context.Background(), config.OIDCAuth{
+		IssuerURL:     issuer,
+		ClientID:      "pearl",
+		RedirectURL:   "http://pearl.test/auth/callback",
+		Scopes:        []string{"openid", "email"},
+		AllowedEmails: allowed,
+		SessionSecret: "0123456789abcdef0123456789abcdef",
+		SessionTTL:    time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("newOIDC: %v", err)
+	}
+	return a
+}
+
+// login drives the login flow through the callback and returns its response.
+func login(t *testing.T, a *oidcAuth, mux http.Handler) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/login?next=/commutes", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("login status = %d, want 302", rec.Code)
+	}
+	loc, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("parsing authorize redirect: %v", err)
+	}
+	if loc.Query().Get("code_challenge") == "" {
+		t.Error("authorize redirect has no PKCE code challenge")
+	}
+	state := loc.Query().Get("state")
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/callback?code=good-code&state="+url.QueryEscape(state), nil)
+	for _, c := range rec.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	cb := httptest.NewRecorder()
+	mux.ServeHTTP(cb, req)
+	return cb
+}
+
+func TestOIDC_LoginFlow(t *testing.T) {
+	provider := fakeProvider(t, "alice@example.com")
+	a := newTestOIDC(t, provider.URL, "Alice@example.com")
+	mux := http.NewServeMux()
+	a.RegisterRoutes(mux)
+	mux.Handle("/", echoUser)
+	h := Middleware(a, nil, mux)
+
+	// Unauthenticated page requests are redirected to login.
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/commutes", nil))
+	if rec.Code != http.StatusFound || !strings.HasPrefix(rec.Header().Get("Location"), "/auth/login") {
+		t.Fatalf("unauthenticated request: status %d, location %q", rec.Code, rec.Header().Get("Location"))
+	}
+
+	cb := login(t, a, h)
+	if cb.Code != http.StatusFound || cb.Header().Get("Location") != "/commutes" {
+		t.Fatalf("callback: status %d, location %q, body %q", cb.Code, cb.Header().Get("Location"), cb.Body.String())
+	}
+
+	var session *http.Cookie
+	for _, c := range cb.Result().Cookies() {
+		if c.Name == sessionCookie {
+			session = c
+		}
+	}
+	if session == nil {
+		t.Fatal("callback did not set a session cookie")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(session)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "alice@example.com" {
+		t.Errorf("authenticated request: status %d, body %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestOIDC_RejectsDisallowedEmail(t *testing.T) {
+	provider := fakeProvider(t, "mallory@example.com")
+	a := newTestOIDC(t, provider.URL, "alice@example.com")
+	mux := http.NewServeMux()
+	a.RegisterRoutes(mux)
+
+	cb := login(t, a, mux)
+	if cb.Code != http.StatusForbidden {
+		t.Errorf("callback status = %d, want 403", cb.Code)
+	}
+}
+
+func TestOIDC_APIRequestsGetUnauthorized(t *testing.T) {
+	provider := fakeProvider(t, "alice@example.com")
+	a := newTestOIDC(t, provider.URL)
+	rec := httptest.NewRecorder()
+	Middleware(a, nil, echoUser).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/commutes", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", rec.Code)
+	}
+}
+
+func TestOIDC_RejectsLoginStateAsSession(t *testing.T) {
+	provider := fakeProvider(t, "alice@example.com")
+	a := newTestOIDC(t, provider.URL)
+	mux := http.NewServeMux()
+	a.RegisterRoutes(mux)
+	h := Middleware(a, nil, echoUser)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/login", nil))
+	var state *http.Cookie
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == loginStateCookie {
+			state = c
+		}
+	}
+	if state == nil {
+		t.Fatal("login did not set a login state cookie")
+	}
+
+	// The login state cookie is handed out to anyone; replayed as a session
+	// it must not authenticate.
+	req := httptest.NewRequest(http.MethodGet, "/api/commutes", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookie, Value: state.Value})
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("login state replayed as session: status = %d, want 401", rec.Code)
+	}
+
+	// Nor does a session without a user name or with login state.
+	for _, p := range []sessionPayload{
+		{Expires: time.Now().Add(time.Hour).Unix()},
+		{Name: "alice", Expires: time.Now().Add(time.Hour).Unix(), Extra: "state"},
+	} {
+		v, err := a.sessions.encode(p)
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/api/commutes", nil)
+		req.AddCookie(&http.Cookie{Name: sessionCookie, Value: v})
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("session %+v: status = %d, want 401", p, rec.Code)
+		}
+	}
+}
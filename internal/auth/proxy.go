@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+
+	"github.com/its-the-vibe/pearl/internal/config"
+)
+
+type proxyAuth struct {
+	userHeader  string
+	emailHeader string
+	trusted     []netip.Prefix
+}
+
+func newProxy(cfg config.ProxyAuth) (*proxyAuth, error) {
+	p := &proxyAuth{userHeader: cfg.UserHeader, emailHeader: cfg.EmailHeader}
+	for _, cidr := range cfg.TrustedProxies {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("parsing trusted proxy %q: %w", cidr, err)
+		}
+		p.trusted = append(p.trusted, prefix.Masked())
+	}
+	return p, nil
+}
+
+// isTrusted reports whether the request's immediate peer is a trusted proxy.
+func (p *proxyAuth) isTrusted(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range p.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *proxyAuth) Authenticate(w http.ResponseWriter, r *http.Request) (User, bool) {
+	if !p.isTrusted(r.RemoteAddr) {
+		http.Error(w, "requests must come through the authenticating proxy", http.StatusForbidden)
+		return User{}, false
+	}
+	name := r.Header.Get(p.userHeader)
+	if name == "" {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return User{}, false
+	}
+	u := User{Name: name}
+	if p.emailHeader != "" {
+		u.Email = r.Header.Get(p.emailHeader)
+	}
+	return u, true
+}
+
+func (p *proxyAuth) RegisterRoutes(*http.ServeMux) {}
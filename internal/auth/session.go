@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// errInvalidSession is returned for session values that are malformed,
+// tampered with or expired.
+var errInvalidSession = errors.New("invalid session")
+
+// sessionCodec signs and verifies session cookie values so that they can be
+// stored client-side without a server-side session store.
+type sessionCodec struct {
+	key []byte
+}
+
+// newSessionCodec returns a codec whose key is derived from secret and
+// purpose, so that a value signed for one purpose, such as the login state,
+// is rejected when presented for another, such as a session.
+func newSessionCodec(secret, purpose string) sessionCodec {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("pearl " + purpose))
+	return sessionCodec{key: mac.Sum(nil)}
+}
+
+type sessionPayload struct {
+	Name    string `json:"n"`
+	Email   string `json:"e,omitempty"`
+	Expires int64  `json:"x"`
+	// Extra carries short-lived login state such as the OAuth state value.
+	Extra string `json:"s,omitempty"`
+}
+
+// encode returns a "payload.signature" string for p.
+func (c sessionCodec) encode(p sessionPayload) (string, error) {
+	raw, err := json.Marshal(p)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(raw)
+	return payload + "." + c.sign(payload), nil
+}
+
+// decode verifies v and returns its payload if it has not expired at now.
+func (c sessionCodec) decode(v string, now time.Time) (sessionPayload, error) {
+	payload, sig, ok := strings.Cut(v, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(c.sign(payload))) {
+		return sessionPayload{}, errInvalidSession
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return sessionPayload{}, errInvalidSession
+	}
+	var p sessionPayload
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return sessionPayload{}, errInvalidSession
+	}
+	if now.Unix() >= p.Expires {
+		return sessionPayload{}, errInvalidSession
+	}
+	return p, nil
+}
+
+func (c sessionCodec) sign(payload string) string {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,152 @@
+package bigquery
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"google.golang.org/api/iterator"
+
+	"github.com/its-the-vibe/pearl/internal/annotations"
+)
+
+const annotationsTable = "annotations"
+
+// annotationColumns is the schema of the annotations table.
+const annotationColumns = `
+  id STRING NOT NULL,
+  card STRING NOT NULL,
+  date DATE NOT NULL,
+  start_time STRING,
+  action STRING,
+  note STRING,
+  tags ARRAY<STRING>,
+  created TIMESTAMP`
+
+// AnnotationStore keeps the annotations of every card in a table that Pearl
+// maintains in the client's dataset. It implements annotations.Store.
+type AnnotationStore struct {
+	c *Client
+}
+
+var _ annotations.Store = (*AnnotationStore)(nil)
+
+// Annotations returns a store keeping annotations in c's dataset.
+func (c *Client) Annotations() *AnnotationStore {
+	return &AnnotationStore{c: c}
+}
+
+func (s *AnnotationStore) tableRef() string {
+	return fmt.Sprintf("`%s.%s.%s`", s.c.project, s.c.dataset, annotationsTable)
+}
+
+// Init creates the annotations table if it does not exist yet.
+func (s *AnnotationStore) Init(ctx context.Context) error {
+	ctx = WithPage(ctx, "annotations")
+	ddl := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s\n) CLUSTER BY card, date", s.tableRef(), annotationColumns)
+	if _, _, err := s.c.run(ctx, ddl); err != nil {
+		return fmt.Errorf("creating annotations table: %w", err)
+	}
+	return nil
+}
+
+func (s *AnnotationStore) List(ctx context.Context, card string, from, to time.Time) ([]annotations.Annotation, error) {
+	clauses := []string{"card = @card"}
+	params := []bigquery.QueryParameter{{Name: "card", Value: card}}
+	if !from.IsZero() {
+		clauses = append(clauses, "date >= @from")
+		params = append(params, bigquery.QueryParameter{Name: "from", Value: civil.DateOf(from.UTC())})
+	}
+	if !to.IsZero() {
+		clauses = append(clauses, "date <= @to")
+		params = append(params, bigquery.QueryParameter{Name: "to", Value: civil.DateOf(to.UTC())})
+	}
+	query := fmt.Sprintf(
+		"SELECT id, card, date, IFNULL(start_time, '') AS start_time, IFNULL(action, '') AS action, IFNULL(note, '') AS note, tags, created FROM %s WHERE %s ORDER BY date, start_time, created",
+		s.tableRef(), strings.Join(clauses, " AND "),
+	)
+
+	it, err := s.c.read(ctx, query, params...)
+	if err != nil {
+		return nil, fmt.Errorf("executing query: %w", err)
+	}
+
+	type row struct {
+		ID        string     `bigquery:"id"`
+		Card      string     `bigquery:"card"`
+		Date      civil.Date `bigquery:"date"`
+		StartTime string     `bigquery:"start_time"`
+		Action    string     `bigquery:"action"`
+		Note      string     `bigquery:"note"`
+		Tags      []string   `bigquery:"tags"`
+		Created   time.Time  `bigquery:"created"`
+	}
+
+	var list []annotations.Annotation
+	for {
+		var r row
+		err := it.Next(&r)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading row: %w", err)
+		}
+		list = append(list, annotations.Annotation{
+			ID:        r.ID,
+			Card:      r.Card,
+			Date:      r.Date.In(time.UTC),
+			StartTime: r.StartTime,
+			Action:    r.Action,
+			Note:      r.Note,
+			Tags:      r.Tags,
+			Created:   r.Created,
+		})
+	}
+	return list, nil
+}
+
+func (s *AnnotationStore) Add(ctx context.Context, a annotations.Annotation) (annotations.Annotation, error) {
+	a, err := annotations.Prepare(a, time.Now())
+	if err != nil {
+		return annotations.Annotation{}, err
+	}
+	insert := fmt.Sprintf(
+		"INSERT INTO %s (id, card, date, start_time, action, note, tags, created) VALUES (@id, @card, @date, NULLIF(@start_time, ''), NULLIF(@action, ''), NULLIF(@note, ''), @tags, @created)",
+		s.tableRef(),
+	)
+	_, _, err = s.c.run(ctx, insert,
+		bigquery.QueryParameter{Name: "id", Value: a.ID},
+		bigquery.QueryParameter{Name: "card", Value: a.Card},
+		bigquery.QueryParameter{Name: "date", Value: civil.DateOf(a.Date)},
+		bigquery.QueryParameter{Name: "start_time", Value: a.StartTime},
+		bigquery.QueryParameter{Name: "action", Value: a.Action},
+		bigquery.QueryParameter{Name: "note", Value: a.Note},
+		bigquery.QueryParameter{Name: "tags", Value: append([]string{}, a.Tags...)},
+		bigquery.QueryParameter{Name: "created", Value: a.Created},
+	)
+	if err != nil {
+		return annotations.Annotation{}, fmt.Errorf("inserting annotation: %w", err)
+	}
+	return a, nil
+}
+
+func (s *AnnotationStore) Delete(ctx context.Context, card, id string) error {
+	del := fmt.Sprintf("DELETE FROM %s WHERE card = @card AND id = @id", s.tableRef())
+	_, status, err := s.c.run(ctx, del,
+		bigquery.QueryParameter{Name: "card", Value: card},
+		bigquery.QueryParameter{Name: "id", Value: id},
+	)
+	if err != nil {
+		return fmt.Errorf("deleting annotation: %w", err)
+	}
+	if status.Statistics != nil {
+		if qs, ok := status.Statistics.Details.(*bigquery.QueryStatistics); ok && qs.NumDMLAffectedRows == 0 {
+			return annotations.ErrNotFound
+		}
+	}
+	return nil
+}
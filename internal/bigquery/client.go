@@ -3,6 +3,7 @@ package bigquery
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"time"
 
 	"cloud.google.com/go/bigquery"
@@ -27,17 +28,20 @@ type Journey struct {
 	SubscriptionName string
 }
 
-// DayCount holds a date and its journey count used for the heatmap.
+// DayCount holds a date with its journey count and spend used for the heatmap.
 type DayCount struct {
-	Date  time.Time
-	Count int
+	Date       time.Time
+	Count      int
+	Spend      float64 // total charged in pounds
+	FirstTapIn string  // earliest start time, e.g. "07:52"; empty when none was recorded
 }
 
 // CommuteJourney holds the fields needed for commute analysis.
 type CommuteJourney struct {
-	Date      string
-	StartTime string
-	EndTime   string
+	Date          string
+	StartTime     string
+	EndTime       string
+	JourneyAction string // e.g. "Clapham Common to Bank"
 }
 
 // DailyRating holds a date, its rating value, and an optional comment fetched
@@ -48,22 +52,64 @@ type DailyRating struct {
 	Comment string // empty when the comment field is NULL in the database
 }
 
+// QueryLimits holds the cost guardrails applied to every query Pearl issues.
+type QueryLimits struct {
+	// MaxBytesBilled caps the bytes billed per query; BigQuery fails the job
+	// without charge when the cap would be exceeded. 0 means no limit.
+	MaxBytesBilled int64
+	// DryRun runs a dry-run estimation before each query and logs the number
+	// of bytes it is expected to process.
+	DryRun bool
+}
+
+// Options configures optional Client behaviour.
+type Options struct {
+	Limits QueryLimits
+	// DailySummary makes per-day aggregates read from the daily summary table
+	// maintained by RefreshDailySummary instead of the raw journeys table.
+	DailySummary bool
+}
+
 // Client wraps a BigQuery client for querying Pearl data.
 type Client struct {
 	bq             *bigquery.Client
 	project        string
 	dataset        string
 	ratingsDataset string
+	opts           Options
+	usage          *Usage
 }
 
 // New creates a new BigQuery Client using Application Default Credentials.
 // ratingsDataset is optional; pass an empty string to disable the ratings overlay.
-func New(ctx context.Context, project, dataset, ratingsDataset string) (*Client, error) {
+func New(ctx context.Context, project, dataset, ratingsDataset string, opts Options) (*Client, error) {
 	bq, err := bigquery.NewClient(ctx, project)
 	if err != nil {
 		return nil, fmt.Errorf("creating bigquery client: %w", err)
 	}
-	return &Client{bq: bq, project: project, dataset: dataset, ratingsDataset: ratingsDataset}, nil
+	return &Client{
+		bq:             bq,
+		project:        project,
+		dataset:        dataset,
+		ratingsDataset: ratingsDataset,
+		opts:           opts,
+		usage:          NewUsage(),
+	}, nil
+}
+
+// ForDataset returns a Client reading from another dataset in the same
+// project. It shares the underlying connection, options and usage tracker
+// with c, so only c needs to be closed.
+func (c *Client) ForDataset(dataset, ratingsDataset string) *Client {
+	clone := *c
+	clone.dataset = dataset
+	clone.ratingsDataset = ratingsDataset
+	return &clone
+}
+
+// Usage returns the tracker recording bytes processed by this client's queries.
+func (c *Client) Usage() *Usage {
+	return c.usage
 }
 
 // Close releases the underlying BigQuery client resources.
@@ -71,22 +117,106 @@ func (c *Client) Close() error {
 	return c.bq.Close()
 }
 
-// JourneyCountsByDay returns the count of journeys per day ordered by date.
-func (c *Client) JourneyCountsByDay(ctx context.Context) ([]DayCount, error) {
+// newQuery builds a query with the configured byte limit and job labels
+// identifying the Pearl page that issued it.
+func (c *Client) newQuery(ctx context.Context, sql string) *bigquery.Query {
+	q := c.bq.Query(sql)
+	q.MaxBytesBilled = c.opts.Limits.MaxBytesBilled
+	q.Labels = map[string]string{
+		"app":  "pearl",
+		"page": PageFromContext(ctx),
+	}
+	return q
+}
+
+// run executes sql with the configured cost guardrails, waits for it to
+// complete and records the bytes it processed against the calling page.
+func (c *Client) run(ctx context.Context, sql string, params ...bigquery.QueryParameter) (*bigquery.Job, *bigquery.JobStatus, error) {
+	page := PageFromContext(ctx)
+
+	if c.opts.Limits.DryRun {
+		dry := c.newQuery(ctx, sql)
+		dry.Parameters = params
+		dry.DryRun = true
+		job, err := dry.Run(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("dry-running query: %w", err)
+		}
+		if status := job.LastStatus(); status != nil && status.Statistics != nil {
+			slog.Info("bigquery dry run",
+				"page", page,
+				"estimated_bytes", status.Statistics.TotalBytesProcessed,
+				"max_bytes_billed", c.opts.Limits.MaxBytesBilled,
+			)
+		}
+	}
+
+	q := c.newQuery(ctx, sql)
+	q.Parameters = params
+	job, err := q.Run(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	status, err := job.Wait(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := status.Err(); err != nil {
+		return nil, nil, err
+	}
+	if stats := status.Statistics; stats != nil {
+		var billed int64
+		var cacheHit bool
+		if qs, ok := stats.Details.(*bigquery.QueryStatistics); ok {
+			billed = qs.TotalBytesBilled
+			cacheHit = qs.CacheHit
+		}
+		c.usage.Record(page, time.Now(), stats.TotalBytesProcessed, billed)
+		slog.Debug("bigquery query complete",
+			"page", page,
+			"bytes_processed", stats.TotalBytesProcessed,
+			"bytes_billed", billed,
+			"cache_hit", cacheHit,
+		)
+	}
+	return job, status, nil
+}
+
+// read runs sql via run and returns an iterator over its rows.
+func (c *Client) read(ctx context.Context, sql string, params ...bigquery.QueryParameter) (*bigquery.RowIterator, error) {
+	job, _, err := c.run(ctx, sql, params...)
+	if err != nil {
+		return nil, err
+	}
+	return job.Read(ctx)
+}
+
+// JourneyCountsByDay returns the count of journeys, spend and first tap-in
+// per day matching filter, ordered by date. When the client is configured to
+// use the daily summary table the counts are read from there instead of raw
+// journeys, unless the filter selects tagged journeys, which the summary
+// cannot tell apart.
+func (c *Client) JourneyCountsByDay(ctx context.Context, filter JourneyFilter) ([]DayCount, error) {
+	if c.opts.DailySummary && len(filter.Tags) == 0 {
+		return c.summaryCountsByDay(ctx, filter)
+	}
+
+	where, params := filter.where(time.Now())
 	query := fmt.Sprintf(
-		"SELECT date, COUNT(*) AS journey_count FROM `%s.%s.%s` GROUP BY date ORDER BY date",
-		c.project, c.dataset, journeysTable,
+		"SELECT date, COUNT(*) AS journey_count, IFNULL(SUM(charge), 0) AS spend, FORMAT_TIME('%%H:%%M', MIN(SAFE.PARSE_TIME('%%H:%%M', start_time))) AS first_tap_in FROM `%s.%s.%s` WHERE %s GROUP BY date ORDER BY date",
+		c.project, c.dataset, journeysTable, where,
 	)
 
-	q := c.bq.Query(query)
-	it, err := q.Read(ctx)
+	it, err := c.read(ctx, query, params...)
 	if err != nil {
 		return nil, fmt.Errorf("executing query: %w", err)
 	}
 
 	type row struct {
-		Date         string `bigquery:"date"`
-		JourneyCount int    `bigquery:"journey_count"`
+		Date         string              `bigquery:"date"`
+		JourneyCount int                 `bigquery:"journey_count"`
+		Spend        float64             `bigquery:"spend"`
+		FirstTapIn   bigquery.NullString `bigquery:"first_tap_in"`
 	}
 
 	var counts []DayCount
@@ -109,30 +239,31 @@ func (c *Client) JourneyCountsByDay(ctx context.Context) ([]DayCount, error) {
 			}
 		}
 
-		counts = append(counts, DayCount{Date: t, Count: r.JourneyCount})
+		counts = append(counts, DayCount{Date: t, Count: r.JourneyCount, Spend: r.Spend, FirstTapIn: r.FirstTapIn.StringVal})
 	}
 
 	return counts, nil
 }
 
-// CommuteJourneys returns all journeys with their start and end times for
-// commute analysis. Filtering by day and time window is done in the caller.
-func (c *Client) CommuteJourneys(ctx context.Context) ([]CommuteJourney, error) {
+// CommuteJourneys returns journeys matching filter with their start and end
+// times for commute analysis. Filtering by time window is done in the caller.
+func (c *Client) CommuteJourneys(ctx context.Context, filter JourneyFilter) ([]CommuteJourney, error) {
+	where, params := filter.where(time.Now())
 	query := fmt.Sprintf(
-		"SELECT date, start_time, end_time FROM `%s.%s.%s` WHERE start_time IS NOT NULL AND end_time IS NOT NULL ORDER BY date, start_time",
-		c.project, c.dataset, journeysTable,
+		"SELECT date, start_time, end_time, IFNULL(journey_action, '') AS journey_action FROM `%s.%s.%s` WHERE start_time IS NOT NULL AND end_time IS NOT NULL AND %s ORDER BY date, start_time",
+		c.project, c.dataset, journeysTable, where,
 	)
 
-	q := c.bq.Query(query)
-	it, err := q.Read(ctx)
+	it, err := c.read(ctx, query, params...)
 	if err != nil {
 		return nil, fmt.Errorf("executing query: %w", err)
 	}
 
 	type row struct {
-		Date      string `bigquery:"date"`
-		StartTime string `bigquery:"start_time"`
-		EndTime   string `bigquery:"end_time"`
+		Date          string `bigquery:"date"`
+		StartTime     string `bigquery:"start_time"`
+		EndTime       string `bigquery:"end_time"`
+		JourneyAction string `bigquery:"journey_action"`
 	}
 
 	var journeys []CommuteJourney
@@ -146,29 +277,32 @@ func (c *Client) CommuteJourneys(ctx context.Context) ([]CommuteJourney, error)
 			return nil, fmt.Errorf("reading row: %w", err)
 		}
 		journeys = append(journeys, CommuteJourney{
-			Date:      r.Date,
-			StartTime: r.StartTime,
-			EndTime:   r.EndTime,
+			Date:          r.Date,
+			StartTime:     r.StartTime,
+			EndTime:       r.EndTime,
+			JourneyAction: r.JourneyAction,
 		})
 	}
 
 	return journeys, nil
 }
 
-// Ratings returns daily ratings from the ratings table, ordered by date.
+// Ratings returns daily ratings from the ratings table within the filter's
+// date range, ordered by date. The filter's weekdays and tags are
+// ignored.
 // It returns nil without error when no ratings dataset has been configured.
-func (c *Client) Ratings(ctx context.Context) ([]DailyRating, error) {
+func (c *Client) Ratings(ctx context.Context, filter JourneyFilter) ([]DailyRating, error) {
 	if c.ratingsDataset == "" {
 		return nil, nil
 	}
 
+	where, params := filter.ratingsWhere()
 	query := fmt.Sprintf(
-		"SELECT CAST(DATE(timestamp) AS STRING) AS day, rating, comment FROM `%s.%s.ratings` ORDER BY 1",
-		c.project, c.ratingsDataset,
+		"SELECT CAST(DATE(timestamp) AS STRING) AS day, rating, comment FROM `%s.%s.ratings` WHERE %s ORDER BY 1",
+		c.project, c.ratingsDataset, where,
 	)
 
-	q := c.bq.Query(query)
-	it, err := q.Read(ctx)
+	it, err := c.read(ctx, query, params...)
 	if err != nil {
 		return nil, fmt.Errorf("executing ratings query: %w", err)
 	}
@@ -203,3 +337,203 @@ func (c *Client) Ratings(ctx context.Context) ([]DailyRating, error) {
 
 	return ratings, nil
 }
+
+// HourCount holds the journeys starting within one hour on one day of the
+// week, used for the punchcard.
+type HourCount struct {
+	Weekday    time.Weekday
+	Hour       int // 0–23, from the journey start time
+	Count      int
+	AvgMinutes float64 // average duration of journeys with an end time; 0 when none
+}
+
+// JourneysByHour returns journey counts and average durations grouped by day
+// of the week and start hour for journeys matching filter. Journeys without a
+// parseable start time are omitted.
+func (c *Client) JourneysByHour(ctx context.Context, filter JourneyFilter) ([]HourCount, error) {
+	where, params := filter.where(time.Now())
+	query := fmt.Sprintf(`SELECT
+  EXTRACT(DAYOFWEEK FROM d) AS dow,
+  EXTRACT(HOUR FROM s) AS hour,
+  COUNT(*) AS journeys,
+  IFNULL(AVG(IF(e > s, TIME_DIFF(e, s, MINUTE), NULL)), 0) AS avg_minutes
+FROM (
+  SELECT %s AS d, SAFE.PARSE_TIME('%%H:%%M', start_time) AS s, SAFE.PARSE_TIME('%%H:%%M', end_time) AS e
+  FROM `+"`%s.%s.%s`"+`
+  WHERE %s
+)
+WHERE d IS NOT NULL AND s IS NOT NULL
+GROUP BY dow, hour
+ORDER BY dow, hour`, journeyDateExpr, c.project, c.dataset, journeysTable, where)
+
+	it, err := c.read(ctx, query, params...)
+	if err != nil {
+		return nil, fmt.Errorf("executing query: %w", err)
+	}
+
+	type row struct {
+		DOW        int64   `bigquery:"dow"`
+		Hour       int64   `bigquery:"hour"`
+		Journeys   int     `bigquery:"journeys"`
+		AvgMinutes float64 `bigquery:"avg_minutes"`
+	}
+
+	var counts []HourCount
+	for {
+		var r row
+		err := it.Next(&r)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading row: %w", err)
+		}
+		counts = append(counts, HourCount{
+			// DAYOFWEEK numbers days from Sunday = 1.
+			Weekday:    time.Weekday(r.DOW - 1),
+			Hour:       int(r.Hour),
+			Count:      r.Journeys,
+			AvgMinutes: r.AvgMinutes,
+		})
+	}
+
+	return counts, nil
+}
+
+// ActionCount holds the number of journeys with one journey action, such as
+// "Clapham Common to Bank".
+type ActionCount struct {
+	Action string
+	Count  int
+}
+
+// JourneyActions returns the distinct journey actions of journeys matching
+// filter with their counts, most frequent first.
+func (c *Client) JourneyActions(ctx context.Context, filter JourneyFilter) ([]ActionCount, error) {
+	where, params := filter.where(time.Now())
+	query := fmt.Sprintf(
+		"SELECT journey_action, COUNT(*) AS journeys FROM `%s.%s.%s` WHERE journey_action IS NOT NULL AND %s GROUP BY journey_action ORDER BY journeys DESC, journey_action",
+		c.project, c.dataset, journeysTable, where,
+	)
+
+	it, err := c.read(ctx, query, params...)
+	if err != nil {
+		return nil, fmt.Errorf("executing query: %w", err)
+	}
+
+	type row struct {
+		JourneyAction string `bigquery:"journey_action"`
+		Journeys      int    `bigquery:"journeys"`
+	}
+
+	var actions []ActionCount
+	for {
+		var r row
+		err := it.Next(&r)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading row: %w", err)
+		}
+		actions = append(actions, ActionCount{Action: r.JourneyAction, Count: r.Journeys})
+	}
+
+	return actions, nil
+}
+
+// BusJourney holds the fields of a bus journey. Bus journeys have no end
+// time or destination, only the route.
+type BusJourney struct {
+	Date          string
+	StartTime     string // empty when not recorded
+	JourneyAction string // e.g. "Bus journey, route 88"
+	Charge        float64
+}
+
+// BusJourneys returns bus journeys matching filter ordered by date and start
+// time.
+func (c *Client) BusJourneys(ctx context.Context, filter JourneyFilter) ([]BusJourney, error) {
+	where, params := filter.where(time.Now())
+	query := fmt.Sprintf(
+		"SELECT date, IFNULL(start_time, '') AS start_time, journey_action, IFNULL(charge, 0) AS charge FROM `%s.%s.%s` WHERE STARTS_WITH(journey_action, 'Bus journey') AND %s ORDER BY date, start_time",
+		c.project, c.dataset, journeysTable, where,
+	)
+
+	it, err := c.read(ctx, query, params...)
+	if err != nil {
+		return nil, fmt.Errorf("executing query: %w", err)
+	}
+
+	type row struct {
+		Date          string  `bigquery:"date"`
+		StartTime     string  `bigquery:"start_time"`
+		JourneyAction string  `bigquery:"journey_action"`
+		Charge        float64 `bigquery:"charge"`
+	}
+
+	var journeys []BusJourney
+	for {
+		var r row
+		err := it.Next(&r)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading row: %w", err)
+		}
+		journeys = append(journeys, BusJourney{
+			Date:          r.Date,
+			StartTime:     r.StartTime,
+			JourneyAction: r.JourneyAction,
+			Charge:        r.Charge,
+		})
+	}
+
+	return journeys, nil
+}
+
+// Journeys returns journeys matching filter ordered by date and start time.
+// Only the date, times, journey action and charge are read; times are empty
+// when not recorded.
+func (c *Client) Journeys(ctx context.Context, filter JourneyFilter) ([]Journey, error) {
+	where, params := filter.where(time.Now())
+	query := fmt.Sprintf(
+		"SELECT date, IFNULL(start_time, '') AS start_time, IFNULL(end_time, '') AS end_time, IFNULL(journey_action, '') AS journey_action, IFNULL(charge, 0) AS charge FROM `%s.%s.%s` WHERE %s ORDER BY date, start_time",
+		c.project, c.dataset, journeysTable, where,
+	)
+
+	it, err := c.read(ctx, query, params...)
+	if err != nil {
+		return nil, fmt.Errorf("executing query: %w", err)
+	}
+
+	type row struct {
+		Date          string  `bigquery:"date"`
+		StartTime     string  `bigquery:"start_time"`
+		EndTime       string  `bigquery:"end_time"`
+		JourneyAction string  `bigquery:"journey_action"`
+		Charge        float64 `bigquery:"charge"`
+	}
+
+	var journeys []Journey
+	for {
+		var r row
+		err := it.Next(&r)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading row: %w", err)
+		}
+		journeys = append(journeys, Journey{
+			Date:          r.Date,
+			StartTime:     r.StartTime,
+			EndTime:       r.EndTime,
+			JourneyAction: r.JourneyAction,
+			Charge:        r.Charge,
+		})
+	}
+
+	return journeys, nil
+}
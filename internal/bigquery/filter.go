@@ -0,0 +1,255 @@
+package bigquery
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+
+	"github.com/its-the-vibe/pearl/internal/tags"
+)
+
+// journeyDateFormats lists the formats found in the journeys date column:
+// Oyster CSV exports use "02-Jan-06" while older imports used ISO dates.
+var journeyDateFormats = []string{"02-Jan-06", "2006-01-02"}
+
+// maxListedDays bounds the number of days a bounded range is expanded into;
+// longer ranges are compared on the parsed date column instead.
+const maxListedDays = 5 * 366
+
+// journeyDateExpr parses the journeys STRING date column into a DATE. It is
+// only used where the predicate cannot be expressed directly on the column.
+const journeyDateExpr = "COALESCE(SAFE.PARSE_DATE('%d-%b-%y', date), SAFE.PARSE_DATE('%Y-%m-%d', date))"
+
+// Expressions extracting the stations of a journey action the same way as
+// journeyStations in the web package: "A to B" departs from A and arrives at
+// B, while "Entered B" and "Exited B" only name B. Suffixes such as
+// " [No touch-out]" are dropped.
+const (
+	journeyFromExpr = `TRIM(REGEXP_REPLACE(REGEXP_EXTRACT(journey_action, r'^(.*?) to '), r'[\[(].*$', ''))`
+	journeyToExpr   = `TRIM(REGEXP_REPLACE(COALESCE(REGEXP_EXTRACT(journey_action, r'^.*? to (.*)$'), REGEXP_EXTRACT(journey_action, r'^(?:Entered|Exited) (.*)$')), r'[\[(].*$', ''))`
+)
+
+// JourneyFilter restricts which journeys a query reads. The zero value
+// matches every journey.
+type JourneyFilter struct {
+	From     time.Time      // first day included; zero means unbounded
+	To       time.Time      // last day included; zero means today
+	Weekdays []time.Weekday // days of the week included; empty means all
+	Tags     []TagFilter    // journeys carrying every one of the tags; empty means all
+}
+
+// TagFilter selects the journeys carrying a tag, whether assigned by one of
+// the tag rules or by an annotation. A TagFilter without rules or annotated
+// journeys matches none.
+type TagFilter struct {
+	Rules     []tags.Rule
+	Annotated *JourneySelection // nil when no journey is annotated with the tag
+}
+
+// matchesWeekday reports whether d falls on one of the filter's weekdays.
+func (f JourneyFilter) matchesWeekday(d time.Weekday) bool {
+	if len(f.Weekdays) == 0 {
+		return true
+	}
+	for _, wd := range f.Weekdays {
+		if wd == d {
+			return true
+		}
+	}
+	return false
+}
+
+// where returns a SQL predicate over the journeys table implementing the
+// filter, together with the query parameters it references.
+//
+// Bounded ranges are expanded into the list of matching date strings in every
+// stored format so the predicate compares the raw date column, which lets
+// BigQuery prune clustered blocks instead of parsing every row. Unbounded
+// ranges with a weekday restriction fall back to parsing the column.
+func (f JourneyFilter) where(now time.Time) (string, []bigquery.QueryParameter) {
+	where, params := f.dateWhere(now)
+	if len(f.Tags) == 0 {
+		return where, params
+	}
+	clauses := []string{where}
+	for i, t := range f.Tags {
+		clause, p := t.where(fmt.Sprintf("tag%d", i))
+		clauses = append(clauses, clause)
+		params = append(params, p...)
+	}
+	return strings.Join(clauses, " AND "), params
+}
+
+// dateWhere returns the part of the predicate selecting the filter's days.
+// The range never extends past now, and ranges longer than maxListedDays are
+// compared on the parsed column rather than listed.
+func (f JourneyFilter) dateWhere(now time.Time) (string, []bigquery.QueryParameter) {
+	to := f.To
+	if !to.IsZero() && to.After(now) {
+		to = now
+	}
+	if !f.From.IsZero() {
+		if to.IsZero() {
+			to = now
+		}
+		from := f.From.UTC().Truncate(24 * time.Hour)
+		to = to.UTC().Truncate(24 * time.Hour)
+		if to.Sub(from) > maxListedDays*24*time.Hour {
+			return f.parsedDateWhere(from, to)
+		}
+
+		dates := []string{}
+		for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+			if !f.matchesWeekday(d.Weekday()) {
+				continue
+			}
+			for _, layout := range journeyDateFormats {
+				dates = append(dates, d.Format(layout))
+			}
+		}
+		return "date IN UNNEST(@dates)", []bigquery.QueryParameter{{Name: "dates", Value: dates}}
+	}
+	return f.parsedDateWhere(time.Time{}, to)
+}
+
+// parsedDateWhere returns a predicate selecting the filter's weekdays between
+// from and to, either of which may be zero, by parsing the date column.
+func (f JourneyFilter) parsedDateWhere(from, to time.Time) (string, []bigquery.QueryParameter) {
+	var clauses []string
+	var params []bigquery.QueryParameter
+	if !from.IsZero() {
+		clauses = append(clauses, journeyDateExpr+" >= @from")
+		params = append(params, bigquery.QueryParameter{Name: "from", Value: civil.DateOf(from.UTC())})
+	}
+	if !to.IsZero() {
+		clauses = append(clauses, journeyDateExpr+" <= @to")
+		params = append(params, bigquery.QueryParameter{Name: "to", Value: civil.DateOf(to.UTC())})
+	}
+	if len(f.Weekdays) > 0 {
+		clauses = append(clauses, "EXTRACT(DAYOFWEEK FROM "+journeyDateExpr+") IN UNNEST(@weekdays)")
+		params = append(params, weekdaysParam("weekdays", f.Weekdays))
+	}
+	if len(clauses) == 0 {
+		return "TRUE", nil
+	}
+	return strings.Join(clauses, " AND "), params
+}
+
+// where returns a predicate selecting the journeys carrying the tag, naming
+// its query parameters with prefix.
+func (t TagFilter) where(prefix string) (string, []bigquery.QueryParameter) {
+	var alternatives []string
+	var params []bigquery.QueryParameter
+	for i, r := range t.Rules {
+		clause, p := ruleWhere(r, fmt.Sprintf("%s_%d", prefix, i))
+		alternatives = append(alternatives, clause)
+		params = append(params, p...)
+	}
+	if t.Annotated != nil {
+		clause, p := t.Annotated.where(prefix)
+		alternatives = append(alternatives, clause)
+		params = append(params, p...)
+	}
+	if len(alternatives) == 0 {
+		return "FALSE", nil
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")", params
+}
+
+// ruleWhere returns a predicate matching the journeys r assigns its tag to,
+// in the same way as Rule.Matches, naming its query parameters with prefix.
+func ruleWhere(r tags.Rule, prefix string) (string, []bigquery.QueryParameter) {
+	var clauses []string
+	var params []bigquery.QueryParameter
+	if len(r.Stations) > 0 {
+		stations := make([]string, len(r.Stations))
+		for i, s := range r.Stations {
+			stations[i] = strings.ToLower(s)
+		}
+		name := prefix + "_stations"
+		clauses = append(clauses, fmt.Sprintf("(LOWER(%s) IN UNNEST(@%s) OR LOWER(%s) IN UNNEST(@%s))", journeyFromExpr, name, journeyToExpr, name))
+		params = append(params, bigquery.QueryParameter{Name: name, Value: stations})
+	}
+	if len(r.Weekdays) > 0 {
+		name := prefix + "_weekdays"
+		clauses = append(clauses, fmt.Sprintf("EXTRACT(DAYOFWEEK FROM %s) IN UNNEST(@%s)", journeyDateExpr, name))
+		params = append(params, weekdaysParam(name, r.Weekdays))
+	}
+	if from, to, ok := r.Window(); ok {
+		op := "AND"
+		if from > to {
+			op = "OR" // the window spans midnight
+		}
+		clauses = append(clauses, fmt.Sprintf("(SAFE.PARSE_TIME('%%H:%%M', start_time) >= @%[1]s_from %[2]s SAFE.PARSE_TIME('%%H:%%M', start_time) <= @%[1]s_to)", prefix, op))
+		params = append(params,
+			bigquery.QueryParameter{Name: prefix + "_from", Value: civil.Time{Hour: from / 60, Minute: from % 60}},
+			bigquery.QueryParameter{Name: prefix + "_to", Value: civil.Time{Hour: to / 60, Minute: to % 60}},
+		)
+	}
+	if r.Note != "" {
+		name := prefix + "_note"
+		clauses = append(clauses, fmt.Sprintf("STRPOS(LOWER(IFNULL(note, '')), @%s) > 0", name))
+		params = append(params, bigquery.QueryParameter{Name: name, Value: strings.ToLower(r.Note)})
+	}
+	if len(clauses) == 0 {
+		return "TRUE", nil
+	}
+	return "(" + strings.Join(clauses, " AND ") + ")", params
+}
+
+// weekdaysParam returns weekdays as the query parameter called name using
+// BigQuery's DAYOFWEEK numbering, which starts from Sunday = 1.
+func weekdaysParam(name string, weekdays []time.Weekday) bigquery.QueryParameter {
+	days := make([]int64, len(weekdays))
+	for i, wd := range weekdays {
+		days[i] = int64(wd) + 1
+	}
+	return bigquery.QueryParameter{Name: name, Value: days}
+}
+
+// summaryWhere returns a predicate over the daily summary table, whose date
+// column is a DATE partitioning column and can be compared directly.
+func (f JourneyFilter) summaryWhere() (string, []bigquery.QueryParameter) {
+	var clauses []string
+	var params []bigquery.QueryParameter
+	if !f.From.IsZero() {
+		clauses = append(clauses, "date >= @from")
+		params = append(params, bigquery.QueryParameter{Name: "from", Value: civil.DateOf(f.From.UTC())})
+	}
+	if !f.To.IsZero() {
+		clauses = append(clauses, "date <= @to")
+		params = append(params, bigquery.QueryParameter{Name: "to", Value: civil.DateOf(f.To.UTC())})
+	}
+	if len(f.Weekdays) > 0 {
+		clauses = append(clauses, "EXTRACT(DAYOFWEEK FROM date) IN UNNEST(@weekdays)")
+		params = append(params, weekdaysParam("weekdays", f.Weekdays))
+	}
+	if len(clauses) == 0 {
+		return "TRUE", nil
+	}
+	return strings.Join(clauses, " AND "), params
+}
+
+// ratingsWhere returns a predicate over the ratings table restricting it to
+// the filter's date range. Weekdays are ignored so that ratings for days
+// without a matching journey are still returned. The range is expressed on
+// the raw timestamp column so that a timestamp-partitioned table is pruned.
+func (f JourneyFilter) ratingsWhere() (string, []bigquery.QueryParameter) {
+	var clauses []string
+	var params []bigquery.QueryParameter
+	if !f.From.IsZero() {
+		clauses = append(clauses, "timestamp >= @from")
+		params = append(params, bigquery.QueryParameter{Name: "from", Value: f.From.UTC().Truncate(24 * time.Hour)})
+	}
+	if !f.To.IsZero() {
+		clauses = append(clauses, "timestamp < @to")
+		params = append(params, bigquery.QueryParameter{Name: "to", Value: f.To.UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)})
+	}
+	if len(clauses) == 0 {
+		return "TRUE", nil
+	}
+	return strings.Join(clauses, " AND "), params
+}
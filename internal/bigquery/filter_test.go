@@ -0,0 +1,178 @@
+package bigquery
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+
+	"github.com/its-the-vibe/pearl/internal/tags"
+)
+
+func TestJourneyFilterWhere_Unfiltered(t *testing.T) {
+	where, params := JourneyFilter{}.where(time.Now())
+	if where != "TRUE" || params != nil {
+		t.Errorf("where() = %q, %v; want TRUE with no params", where, params)
+	}
+}
+
+func TestJourneyFilterWhere_BoundedRangeListsDates(t *testing.T) {
+	// 2024-01-01 is a Monday.
+	f := JourneyFilter{
+		From:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		To:       time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC),
+		Weekdays: []time.Weekday{time.Tuesday, time.Thursday},
+	}
+	where, params := f.where(time.Now())
+	if where != "date IN UNNEST(@dates)" {
+		t.Errorf("where = %q, want direct predicate on the date column", where)
+	}
+	if len(params) != 1 {
+		t.Fatalf("expected 1 param, got %d", len(params))
+	}
+	dates, ok := params[0].Value.([]string)
+	if !ok {
+		t.Fatalf("dates param has type %T, want []string", params[0].Value)
+	}
+	want := []string{"02-Jan-24", "2024-01-02", "04-Jan-24", "2024-01-04"}
+	if !slices.Equal(dates, want) {
+		t.Errorf("dates = %v, want %v", dates, want)
+	}
+}
+
+func TestJourneyFilterWhere_OpenEndedUsesNow(t *testing.T) {
+	now := time.Date(2024, 1, 3, 15, 0, 0, 0, time.UTC)
+	f := JourneyFilter{From: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)}
+	_, params := f.where(now)
+	dates := params[0].Value.([]string)
+	if len(dates) != 4 {
+		t.Errorf("expected 2 days × 2 formats, got %v", dates)
+	}
+}
+
+func TestJourneyFilterWhere_FutureToUsesNow(t *testing.T) {
+	now := time.Date(2024, 1, 3, 15, 0, 0, 0, time.UTC)
+	f := JourneyFilter{
+		From: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC),
+	}
+	_, params := f.where(now)
+	dates := params[0].Value.([]string)
+	if len(dates) != 4 {
+		t.Errorf("expected 2 days × 2 formats, got %d dates", len(dates))
+	}
+
+	f = JourneyFilter{To: f.To}
+	_, params = f.where(now)
+	if len(params) != 1 || params[0].Value != civil.DateOf(now) {
+		t.Errorf("params = %v, want to clamped to %s", params, civil.DateOf(now))
+	}
+}
+
+func TestJourneyFilterWhere_LongRangeParsesDates(t *testing.T) {
+	now := time.Date(2024, 1, 3, 15, 0, 0, 0, time.UTC)
+	f := JourneyFilter{From: time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)}
+	where, params := f.where(now)
+	if want := journeyDateExpr + " >= @from AND " + journeyDateExpr + " <= @to"; where != want {
+		t.Errorf("where = %q, want %q", where, want)
+	}
+	if len(params) != 2 || params[0].Value != (civil.Date{Year: 1900, Month: 1, Day: 1}) || params[1].Value != civil.DateOf(now) {
+		t.Errorf("params = %v", params)
+	}
+}
+
+func TestJourneyFilterWhere_UnboundedWeekdays(t *testing.T) {
+	f := JourneyFilter{Weekdays: []time.Weekday{time.Sunday, time.Saturday}}
+	where, params := f.where(time.Now())
+	if len(params) != 1 || params[0].Name != "weekdays" {
+		t.Fatalf("params = %v, want a single weekdays param", params)
+	}
+	// BigQuery numbers Sunday as 1.
+	if got := params[0].Value.([]int64); !slices.Equal(got, []int64{1, 7}) {
+		t.Errorf("weekdays = %v, want [1 7]", got)
+	}
+	if where == "TRUE" {
+		t.Error("expected a DAYOFWEEK predicate")
+	}
+}
+
+func TestJourneyFilterRatingsWhere(t *testing.T) {
+	f := JourneyFilter{
+		From:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		To:       time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+		Weekdays: []time.Weekday{time.Tuesday},
+	}
+	where, params := f.ratingsWhere()
+	if where != "timestamp >= @from AND timestamp < @to" {
+		t.Errorf("ratingsWhere = %q", where)
+	}
+	// The upper bound is exclusive so the last day is included in full.
+	if to := params[1].Value.(time.Time); !to.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("to = %v, want 2024-02-01", to)
+	}
+}
+
+func TestJourneyFilterSummaryWhere(t *testing.T) {
+	f := JourneyFilter{
+		From:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Weekdays: []time.Weekday{time.Monday},
+	}
+	where, params := f.summaryWhere()
+	want := "date >= @from AND EXTRACT(DAYOFWEEK FROM date) IN UNNEST(@weekdays)"
+	if where != want {
+		t.Errorf("summaryWhere = %q, want %q", where, want)
+	}
+	if len(params) != 2 {
+		t.Fatalf("expected 2 params, got %d", len(params))
+	}
+	if got := params[0].Value.(civil.Date); got != (civil.Date{Year: 2024, Month: time.January, Day: 1}) {
+		t.Errorf("from = %v, want 2024-01-01", got)
+	}
+}
+
+func TestJourneyFilterWhere_Tags(t *testing.T) {
+	day := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	f := JourneyFilter{Tags: []TagFilter{
+		{Rules: []tags.Rule{
+			{Tag: "work", Stations: []string{"Bank"}, Weekdays: []time.Weekday{time.Monday}},
+			{Tag: "work", From: "22:00", To: "02:00", Note: "Late"},
+		}},
+		{Annotated: &JourneySelection{
+			Days:     []time.Time{day},
+			Journeys: []JourneyKey{{Date: day.AddDate(0, 0, 1), StartTime: "08:00", Action: "Clapham Common to Bank"}},
+		}},
+	}}
+	where, params := f.where(time.Now())
+	names := make(map[string]any)
+	for _, p := range params {
+		names[p.Name] = p.Value
+	}
+	for _, name := range []string{"tag0_0_stations", "tag0_0_weekdays", "tag0_1_from", "tag0_1_to", "tag0_1_note", "tag1_days", "tag1_journeys"} {
+		if _, ok := names[name]; !ok || !strings.Contains(where, "@"+name) {
+			t.Errorf("where() = %q does not use @%s", where, name)
+		}
+	}
+	if len(params) != len(names) {
+		t.Errorf("params = %v, want unique names", params)
+	}
+	if got := names["tag0_0_stations"].([]string); !slices.Equal(got, []string{"bank"}) {
+		t.Errorf("stations = %q, want [bank]", got)
+	}
+	if got := names["tag0_1_note"]; got != "late" {
+		t.Errorf("note = %v, want late", got)
+	}
+	// The window spans midnight, so either bound is enough.
+	if !strings.Contains(where, "@tag0_1_from OR") {
+		t.Errorf("where() = %q, want an overnight window", where)
+	}
+	if got := names["tag1_journeys"].([]string); len(got) != 1 || got[0] != "2024-03-06 08:00 Clapham Common to Bank" {
+		t.Errorf("journeys = %q, want [2024-03-06 08:00 Clapham Common to Bank]", got)
+	}
+
+	// A tag nothing assigns matches no journeys rather than all of them.
+	if where, _ := (JourneyFilter{Tags: []TagFilter{{}}}).where(time.Now()); where != "TRUE AND FALSE" {
+		t.Errorf("empty tag: where() = %q, want TRUE AND FALSE", where)
+	}
+}
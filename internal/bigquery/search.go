@@ -0,0 +1,223 @@
+package bigquery
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"google.golang.org/api/iterator"
+)
+
+// Journey types, as classified by JourneyType.
+const (
+	JourneyTypeRail  = "rail"  // tube, overground and rail journeys between stations
+	JourneyTypeBus   = "bus"   // bus and tram journeys
+	JourneyTypeTopUp = "topup" // top-ups and other credits
+	JourneyTypeOther = "other"
+)
+
+// JourneyTypes lists the journey types in display order.
+var JourneyTypes = []string{JourneyTypeRail, JourneyTypeBus, JourneyTypeTopUp, JourneyTypeOther}
+
+// topUpPattern matches lower-case journey actions of top-ups, such as
+// "Auto top-up" or "Topped up".
+const topUpPattern = `top(ped)?.?up`
+
+var topUpRegexp = regexp.MustCompile(topUpPattern)
+
+// journeyTypeExpr classifies a journeys row in SQL the same way as
+// JourneyType does in Go.
+const journeyTypeExpr = `CASE
+  WHEN STARTS_WITH(IFNULL(journey_action, ''), 'Bus journey') THEN 'bus'
+  WHEN IFNULL(credit, 0) > 0 OR REGEXP_CONTAINS(LOWER(IFNULL(journey_action, '')), r'` + topUpPattern + `') THEN 'topup'
+  WHEN STRPOS(IFNULL(journey_action, ''), ' to ') > 0 OR STARTS_WITH(IFNULL(journey_action, ''), 'Entered ') OR STARTS_WITH(IFNULL(journey_action, ''), 'Exited ') THEN 'rail'
+  ELSE 'other'
+END`
+
+// JourneyType classifies a journey as rail, bus, top-up or other from its
+// journey action and credit.
+func JourneyType(j Journey) string {
+	action := j.JourneyAction
+	switch {
+	case strings.HasPrefix(action, "Bus journey"):
+		return JourneyTypeBus
+	case j.Credit > 0 || topUpRegexp.MatchString(strings.ToLower(action)):
+		return JourneyTypeTopUp
+	case strings.Contains(action, " to ") || strings.HasPrefix(action, "Entered ") || strings.HasPrefix(action, "Exited "):
+		return JourneyTypeRail
+	default:
+		return JourneyTypeOther
+	}
+}
+
+// Sort orders accepted by JourneySearch.Sort.
+const (
+	SortByDate    = "date"
+	SortByAction  = "action"
+	SortByCharge  = "charge"
+	SortByBalance = "balance"
+)
+
+// journeySortColumns maps each sort order to the SQL it orders by. Rows with
+// equal values are ordered by date and start time.
+var journeySortColumns = map[string]string{
+	SortByDate:    journeyDateExpr,
+	SortByAction:  "journey_action",
+	SortByCharge:  "IFNULL(charge, 0)",
+	SortByBalance: "balance",
+}
+
+// JourneyKey identifies a journey, as the journeys table has no key of its
+// own.
+type JourneyKey struct {
+	Date      time.Time
+	StartTime string
+	Action    string
+}
+
+// String formats k as matched by JourneySelection, e.g.
+// "2024-03-05 08:00 Clapham Common to Bank".
+func (k JourneyKey) String() string {
+	return k.Date.Format("2006-01-02") + " " + k.StartTime + " " + k.Action
+}
+
+// JourneySelection selects the journeys on whole days and individual
+// journeys, such as those annotated with a tag.
+type JourneySelection struct {
+	Days     []time.Time
+	Journeys []JourneyKey
+}
+
+// where returns a predicate matching the selected journeys, naming its query
+// parameters with prefix.
+func (s JourneySelection) where(prefix string) (string, []bigquery.QueryParameter) {
+	days := make([]civil.Date, len(s.Days))
+	for i, d := range s.Days {
+		days[i] = civil.DateOf(d)
+	}
+	keys := make([]string, len(s.Journeys))
+	for i, k := range s.Journeys {
+		keys[i] = k.String()
+	}
+	clause := fmt.Sprintf("(%[1]s IN UNNEST(@%[2]s_days) OR CONCAT(FORMAT_DATE('%%F', %[1]s), ' ', IFNULL(start_time, ''), ' ', IFNULL(journey_action, '')) IN UNNEST(@%[2]s_journeys))", journeyDateExpr, prefix)
+	return clause, []bigquery.QueryParameter{
+		{Name: prefix + "_days", Value: days},
+		{Name: prefix + "_journeys", Value: keys},
+	}
+}
+
+// JourneySearch selects a page of journeys for browsing. The zero value
+// matches every journey, most recent first.
+type JourneySearch struct {
+	Filter    JourneyFilter
+	Text      string   // found anywhere in the journey action, ignoring case
+	Type      string   // one of JourneyTypes; empty matches all
+	MinCharge *float64 // nil means no lower bound
+	MaxCharge *float64 // nil means no upper bound
+	Note      string   // found anywhere in the note, ignoring case
+	Sort      string   // one of the SortBy orders; empty sorts by date
+	Ascending bool
+	Limit     int // rows per page; 0 means no limit
+	Offset    int
+}
+
+// where returns the predicate selecting the searched journeys together with
+// the query parameters it references.
+func (s JourneySearch) where(now time.Time) (string, []bigquery.QueryParameter) {
+	where, params := s.Filter.where(now)
+	clauses := []string{where}
+	if s.Text != "" {
+		clauses = append(clauses, "STRPOS(LOWER(IFNULL(journey_action, '')), LOWER(@text)) > 0")
+		params = append(params, bigquery.QueryParameter{Name: "text", Value: s.Text})
+	}
+	if s.Type != "" {
+		clauses = append(clauses, "("+journeyTypeExpr+") = @type")
+		params = append(params, bigquery.QueryParameter{Name: "type", Value: s.Type})
+	}
+	if s.MinCharge != nil {
+		clauses = append(clauses, "IFNULL(charge, 0) >= @min_charge")
+		params = append(params, bigquery.QueryParameter{Name: "min_charge", Value: *s.MinCharge})
+	}
+	if s.MaxCharge != nil {
+		clauses = append(clauses, "IFNULL(charge, 0) <= @max_charge")
+		params = append(params, bigquery.QueryParameter{Name: "max_charge", Value: *s.MaxCharge})
+	}
+	if s.Note != "" {
+		clauses = append(clauses, "STRPOS(LOWER(IFNULL(note, '')), LOWER(@note)) > 0")
+		params = append(params, bigquery.QueryParameter{Name: "note", Value: s.Note})
+	}
+	return strings.Join(clauses, " AND "), params
+}
+
+// orderBy returns the ORDER BY expression list for the search's sort order.
+func (s JourneySearch) orderBy() string {
+	dir := "DESC"
+	if s.Ascending {
+		dir = "ASC"
+	}
+	col, ok := journeySortColumns[s.Sort]
+	if !ok || s.Sort == SortByDate {
+		return fmt.Sprintf("%s %s, start_time %s", journeyDateExpr, dir, dir)
+	}
+	return fmt.Sprintf("%s %s, %s DESC, start_time DESC", col, dir, journeyDateExpr)
+}
+
+// SearchJourneys returns the page of journeys selected by s and the number of
+// journeys matching it across all pages.
+func (c *Client) SearchJourneys(ctx context.Context, s JourneySearch) ([]Journey, int, error) {
+	where, params := s.where(time.Now())
+	query := fmt.Sprintf(
+		"SELECT date, IFNULL(start_time, '') AS start_time, IFNULL(end_time, '') AS end_time, IFNULL(journey_action, '') AS journey_action, IFNULL(charge, 0) AS charge, IFNULL(credit, 0) AS credit, IFNULL(balance, 0) AS balance, IFNULL(note, '') AS note, COUNT(*) OVER () AS total FROM `%s.%s.%s` WHERE %s ORDER BY %s",
+		c.project, c.dataset, journeysTable, where, s.orderBy(),
+	)
+	if s.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d OFFSET %d", s.Limit, max(0, s.Offset))
+	}
+
+	it, err := c.read(ctx, query, params...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("executing query: %w", err)
+	}
+
+	type row struct {
+		Date          string  `bigquery:"date"`
+		StartTime     string  `bigquery:"start_time"`
+		EndTime       string  `bigquery:"end_time"`
+		JourneyAction string  `bigquery:"journey_action"`
+		Charge        float64 `bigquery:"charge"`
+		Credit        float64 `bigquery:"credit"`
+		Balance       float64 `bigquery:"balance"`
+		Note          string  `bigquery:"note"`
+		Total         int     `bigquery:"total"`
+	}
+
+	var journeys []Journey
+	total := 0
+	for {
+		var r row
+		err := it.Next(&r)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("reading row: %w", err)
+		}
+		total = r.Total
+		journeys = append(journeys, Journey{
+			Date:          r.Date,
+			StartTime:     r.StartTime,
+			EndTime:       r.EndTime,
+			JourneyAction: r.JourneyAction,
+			Charge:        r.Charge,
+			Credit:        r.Credit,
+			Balance:       r.Balance,
+			Note:          r.Note,
+		})
+	}
+
+	return journeys, total, nil
+}
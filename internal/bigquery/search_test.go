@@ -0,0 +1,76 @@
+package bigquery
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJourneySearchWhere(t *testing.T) {
+	where, params := JourneySearch{}.where(time.Now())
+	if where != "TRUE" || len(params) != 0 {
+		t.Errorf("zero search: where() = %q, %v; want TRUE with no params", where, params)
+	}
+
+	minCharge, maxCharge := 1.5, 3.0
+	s := JourneySearch{
+		Filter:    JourneyFilter{From: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+		Text:      "bank",
+		Type:      JourneyTypeRail,
+		MinCharge: &minCharge,
+		MaxCharge: &maxCharge,
+		Note:      "refund",
+	}
+	where, params = s.where(time.Now())
+	if !strings.HasPrefix(where, "date IN UNNEST(@dates) AND ") {
+		t.Errorf("where = %q, want the date filter first", where)
+	}
+	var names []string
+	for _, p := range params {
+		names = append(names, p.Name)
+	}
+	if got := strings.Join(names, ","); got != "dates,text,type,min_charge,max_charge,note" {
+		t.Errorf("params = %s", got)
+	}
+	for _, name := range names {
+		if !strings.Contains(where, "@"+name) {
+			t.Errorf("where does not reference @%s: %q", name, where)
+		}
+	}
+}
+
+func TestJourneySearchOrderBy(t *testing.T) {
+	tests := []struct {
+		search JourneySearch
+		want   string
+	}{
+		{JourneySearch{}, journeyDateExpr + " DESC, start_time DESC"},
+		{JourneySearch{Sort: SortByDate, Ascending: true}, journeyDateExpr + " ASC, start_time ASC"},
+		{JourneySearch{Sort: SortByCharge}, "IFNULL(charge, 0) DESC, " + journeyDateExpr + " DESC, start_time DESC"},
+		{JourneySearch{Sort: "charge; DROP TABLE journeys"}, journeyDateExpr + " DESC, start_time DESC"},
+	}
+	for _, tt := range tests {
+		if got := tt.search.orderBy(); got != tt.want {
+			t.Errorf("orderBy(%q, asc %v) = %q, want %q", tt.search.Sort, tt.search.Ascending, got, tt.want)
+		}
+	}
+}
+
+func TestJourneyType(t *testing.T) {
+	tests := []struct {
+		journey Journey
+		want    string
+	}{
+		{Journey{JourneyAction: "Clapham Common to Bank"}, JourneyTypeRail},
+		{Journey{JourneyAction: "Entered Bank"}, JourneyTypeRail},
+		{Journey{JourneyAction: "Bus journey, route 88"}, JourneyTypeBus},
+		{Journey{JourneyAction: "Auto top-up, Bank", Credit: 20}, JourneyTypeTopUp},
+		{Journey{JourneyAction: "Topped up"}, JourneyTypeTopUp},
+		{Journey{JourneyAction: "Season ticket added"}, JourneyTypeOther},
+	}
+	for _, tt := range tests {
+		if got := JourneyType(tt.journey); got != tt.want {
+			t.Errorf("JourneyType(%q) = %q, want %q", tt.journey.JourneyAction, got, tt.want)
+		}
+	}
+}
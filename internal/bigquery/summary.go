@@ -0,0 +1,240 @@
+package bigquery
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"google.golang.org/api/iterator"
+)
+
+const summaryTable = "daily_summary"
+
+// summaryColumns is the schema of the daily summary table. The aggregate
+// query in summarySelect must produce columns in the same order.
+const summaryColumns = `
+  date DATE NOT NULL,
+  journeys INT64,
+  spend FLOAT64,
+  transit_minutes INT64,
+  first_tap_in STRING,
+  last_tap_out STRING,
+  stations ARRAY<STRING>,
+  last_published_at TIMESTAMP,
+  refreshed_at TIMESTAMP`
+
+// RefreshResult describes the outcome of a daily summary refresh.
+type RefreshResult struct {
+	Rebuilt   bool // the table was recreated from scratch
+	Dates     int  // dates re-aggregated by an incremental refresh
+	LateDates int  // of those, dates that had already been summarised
+}
+
+// summarySelect returns a query aggregating raw journeys into one row per
+// day, restricted by a predicate over the parsed date column d.
+func (c *Client) summarySelect(where string) string {
+	return fmt.Sprintf(`SELECT
+  d AS date,
+  COUNT(*) AS journeys,
+  ROUND(IFNULL(SUM(charge), 0), 2) AS spend,
+  IFNULL(SUM(GREATEST(TIME_DIFF(SAFE.PARSE_TIME('%%H:%%M', end_time), SAFE.PARSE_TIME('%%H:%%M', start_time), MINUTE), 0)), 0) AS transit_minutes,
+  FORMAT_TIME('%%H:%%M', MIN(SAFE.PARSE_TIME('%%H:%%M', start_time))) AS first_tap_in,
+  FORMAT_TIME('%%H:%%M', MAX(SAFE.PARSE_TIME('%%H:%%M', end_time))) AS last_tap_out,
+  ARRAY(
+    SELECT DISTINCT s FROM UNNEST(ARRAY_CONCAT_AGG(ARRAY(
+      SELECT x FROM UNNEST([
+        REGEXP_EXTRACT(journey_action, r'^(.+?) to '),
+        REGEXP_EXTRACT(journey_action, r' to (.+)$')
+      ]) AS x WHERE x IS NOT NULL
+    ))) AS s ORDER BY s
+  ) AS stations,
+  MAX(publish_time) AS last_published_at,
+  CURRENT_TIMESTAMP() AS refreshed_at
+FROM (SELECT *, %s AS d FROM `+"`%s.%s.%s`"+`)
+WHERE d IS NOT NULL AND %s
+GROUP BY d`, journeyDateExpr, c.project, c.dataset, journeysTable, where)
+}
+
+// summaryMerge returns a statement re-aggregating the days listed in the
+// @dates parameter into the daily summary table.
+func (c *Client) summaryMerge() string {
+	return fmt.Sprintf(`MERGE %s T
+USING (%s) S
+ON T.date = S.date
+WHEN MATCHED THEN UPDATE SET
+  journeys = S.journeys,
+  spend = S.spend,
+  transit_minutes = S.transit_minutes,
+  first_tap_in = S.first_tap_in,
+  last_tap_out = S.last_tap_out,
+  stations = S.stations,
+  last_published_at = S.last_published_at,
+  refreshed_at = S.refreshed_at
+WHEN NOT MATCHED THEN INSERT (date, journeys, spend, transit_minutes, first_tap_in, last_tap_out, stations, last_published_at, refreshed_at)
+  VALUES (S.date, S.journeys, S.spend, S.transit_minutes, S.first_tap_in, S.last_tap_out, S.stations, S.last_published_at, S.refreshed_at)`,
+		c.summaryTableRef(), c.summarySelect("d IN UNNEST(@dates)"))
+}
+
+// summaryWatermarkQuery returns a query for the latest publish time already
+// summarised.
+func (c *Client) summaryWatermarkQuery() string {
+	return fmt.Sprintf("SELECT MAX(last_published_at) AS watermark FROM %s", c.summaryTableRef())
+}
+
+// changedDatesQuery returns a query for the distinct journey dates with rows
+// published after the @since parameter.
+func (c *Client) changedDatesQuery() string {
+	return fmt.Sprintf(
+		"SELECT DISTINCT %s AS d FROM `%s.%s.%s` WHERE publish_time > @since ORDER BY d",
+		journeyDateExpr, c.project, c.dataset, journeysTable,
+	)
+}
+
+func (c *Client) summaryTableRef() string {
+	return fmt.Sprintf("`%s.%s.%s`", c.project, c.dataset, summaryTable)
+}
+
+// RefreshDailySummary brings the daily summary table up to date with the raw
+// journeys table. Incremental refreshes re-aggregate every date that has rows
+// published since the last refresh, which picks up late-arriving journeys for
+// days that were already summarised. The table is rebuilt from scratch when
+// rebuild is true or when it is empty.
+func (c *Client) RefreshDailySummary(ctx context.Context, rebuild bool) (RefreshResult, error) {
+	ctx = WithPage(ctx, "aggregate")
+
+	if rebuild {
+		return c.rebuildDailySummary(ctx)
+	}
+
+	ddl := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s\n) PARTITION BY date", c.summaryTableRef(), summaryColumns)
+	if _, _, err := c.run(ctx, ddl); err != nil {
+		return RefreshResult{}, fmt.Errorf("creating summary table: %w", err)
+	}
+
+	watermark, err := c.summaryWatermark(ctx)
+	if err != nil {
+		return RefreshResult{}, err
+	}
+	if !watermark.Valid {
+		return c.rebuildDailySummary(ctx)
+	}
+
+	dates, err := c.datesPublishedSince(ctx, watermark.Timestamp)
+	if err != nil {
+		return RefreshResult{}, err
+	}
+	if len(dates) == 0 {
+		return RefreshResult{}, nil
+	}
+
+	result := RefreshResult{Dates: len(dates)}
+	watermarkDay := civil.DateOf(watermark.Timestamp.UTC())
+	for _, d := range dates {
+		if d.Before(watermarkDay) {
+			result.LateDates++
+		}
+	}
+	if result.LateDates > 0 {
+		slog.Info("late-arriving journeys found", "dates", result.LateDates)
+	}
+
+	if _, _, err := c.run(ctx, c.summaryMerge(), bigquery.QueryParameter{Name: "dates", Value: dates}); err != nil {
+		return RefreshResult{}, fmt.Errorf("merging summary rows: %w", err)
+	}
+	return result, nil
+}
+
+func (c *Client) rebuildDailySummary(ctx context.Context) (RefreshResult, error) {
+	ddl := fmt.Sprintf("CREATE OR REPLACE TABLE %s (%s\n) PARTITION BY date AS\n%s",
+		c.summaryTableRef(), summaryColumns, c.summarySelect("TRUE"))
+	if _, _, err := c.run(ctx, ddl); err != nil {
+		return RefreshResult{}, fmt.Errorf("rebuilding summary table: %w", err)
+	}
+	return RefreshResult{Rebuilt: true}, nil
+}
+
+// summaryWatermark returns the latest publish time already summarised.
+func (c *Client) summaryWatermark(ctx context.Context) (bigquery.NullTimestamp, error) {
+	it, err := c.read(ctx, c.summaryWatermarkQuery())
+	if err != nil {
+		return bigquery.NullTimestamp{}, fmt.Errorf("reading summary watermark: %w", err)
+	}
+	var r struct {
+		Watermark bigquery.NullTimestamp `bigquery:"watermark"`
+	}
+	if err := it.Next(&r); err != nil && err != iterator.Done {
+		return bigquery.NullTimestamp{}, fmt.Errorf("reading summary watermark: %w", err)
+	}
+	return r.Watermark, nil
+}
+
+// datesPublishedSince returns the distinct journey dates with rows published
+// after t, in ascending order.
+func (c *Client) datesPublishedSince(ctx context.Context, t time.Time) ([]civil.Date, error) {
+	it, err := c.read(ctx, c.changedDatesQuery(), bigquery.QueryParameter{Name: "since", Value: t})
+	if err != nil {
+		return nil, fmt.Errorf("finding changed dates: %w", err)
+	}
+
+	var dates []civil.Date
+	for {
+		var r struct {
+			D bigquery.NullDate `bigquery:"d"`
+		}
+		err := it.Next(&r)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading changed dates: %w", err)
+		}
+		if r.D.Valid {
+			dates = append(dates, r.D.Date)
+		}
+	}
+	return dates, nil
+}
+
+// summaryCountsByDay returns per-day journey counts, spend and first tap-in
+// from the daily summary table.
+func (c *Client) summaryCountsByDay(ctx context.Context, filter JourneyFilter) ([]DayCount, error) {
+	where, params := filter.summaryWhere()
+	query := fmt.Sprintf(
+		"SELECT date, journeys, spend, first_tap_in FROM %s WHERE %s ORDER BY date",
+		c.summaryTableRef(), where,
+	)
+
+	it, err := c.read(ctx, query, params...)
+	if err != nil {
+		return nil, fmt.Errorf("executing summary query: %w", err)
+	}
+
+	type row struct {
+		Date       civil.Date          `bigquery:"date"`
+		Journeys   int                 `bigquery:"journeys"`
+		Spend      float64             `bigquery:"spend"`
+		FirstTapIn bigquery.NullString `bigquery:"first_tap_in"`
+	}
+
+	var counts []DayCount
+	for {
+		var r row
+		err := it.Next(&r)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading summary row: %w", err)
+		}
+		counts = append(counts, DayCount{
+			Date:       r.Date.In(time.UTC),
+			Count:      r.Journeys,
+			Spend:      r.Spend,
+			FirstTapIn: r.FirstTapIn.StringVal,
+		})
+	}
+	return counts, nil
+}
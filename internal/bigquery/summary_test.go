@@ -0,0 +1,92 @@
+package bigquery
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// summaryColumnNames returns the column names of summaryColumns in order.
+func summaryColumnNames() []string {
+	var names []string
+	for _, line := range strings.Split(strings.TrimSpace(summaryColumns), "\n") {
+		names = append(names, strings.Fields(line)[0])
+	}
+	return names
+}
+
+func TestSummarySelect(t *testing.T) {
+	c := &Client{project: "p", dataset: "d"}
+	query := c.summarySelect("d IN UNNEST(@dates)")
+
+	// CREATE TABLE ... AS relies on the columns coming out in schema order.
+	aliases := regexp.MustCompile(`(?m) AS (\w+),?$`).FindAllStringSubmatch(query, -1)
+	var got []string
+	for _, m := range aliases {
+		got = append(got, m[1])
+	}
+	want := []string{"date", "journeys", "spend", "transit_minutes", "first_tap_in", "last_tap_out", "stations", "last_published_at", "refreshed_at"}
+	if names := summaryColumnNames(); !slices.Equal(names, want) {
+		t.Errorf("summary columns = %q, want %q", names, want)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("selected columns = %q, want %q", got, want)
+	}
+
+	for _, want := range []string{
+		"FROM (SELECT *, " + journeyDateExpr + " AS d FROM `p.d.journeys`)",
+		"WHERE d IS NOT NULL AND d IN UNNEST(@dates)",
+		"MINUTE), 0)), 0) AS transit_minutes",
+		"MAX(SAFE.PARSE_TIME('%H:%M', end_time))) AS last_tap_out",
+		"REGEXP_EXTRACT(journey_action, r'^(.+?) to ')",
+		"REGEXP_EXTRACT(journey_action, r' to (.+)$')",
+		"MAX(publish_time) AS last_published_at",
+		"GROUP BY d",
+	} {
+		if !strings.Contains(query, want) {
+			t.Errorf("summarySelect missing %q:\n%s", want, query)
+		}
+	}
+}
+
+func TestSummaryMerge(t *testing.T) {
+	c := &Client{project: "p", dataset: "d"}
+	merge := c.summaryMerge()
+
+	if !strings.HasPrefix(merge, "MERGE `p.d.daily_summary` T\nUSING (") || !strings.Contains(merge, "d IN UNNEST(@dates)") {
+		t.Errorf("merge does not re-aggregate the @dates days into the summary table:\n%s", merge)
+	}
+	if !strings.Contains(merge, "ON T.date = S.date") {
+		t.Errorf("merge does not match rows by date:\n%s", merge)
+	}
+	columns := summaryColumnNames()
+	for _, col := range columns[1:] {
+		if !strings.Contains(merge, col+" = S."+col) {
+			t.Errorf("merge does not update %s:\n%s", col, merge)
+		}
+	}
+	if want := "INSERT (" + strings.Join(columns, ", ") + ")"; !strings.Contains(merge, want) {
+		t.Errorf("merge missing %q:\n%s", want, merge)
+	}
+	if want := "VALUES (S." + strings.Join(columns, ", S.") + ")"; !strings.Contains(merge, want) {
+		t.Errorf("merge missing %q:\n%s", want, merge)
+	}
+}
+
+func TestSummaryWatermark(t *testing.T) {
+	c := &Client{project: "p", dataset: "d"}
+
+	if got, want := c.summaryWatermarkQuery(), "SELECT MAX(last_published_at) AS watermark FROM `p.d.daily_summary`"; got != want {
+		t.Errorf("summaryWatermarkQuery() = %q, want %q", got, want)
+	}
+	// Rows published after the watermark mark their dates as changed, however
+	// old the dates are.
+	query := c.changedDatesQuery()
+	if !strings.Contains(query, "FROM `p.d.journeys` WHERE publish_time > @since") {
+		t.Errorf("changedDatesQuery() = %q, want rows published after @since", query)
+	}
+	if !strings.Contains(query, "SELECT DISTINCT "+journeyDateExpr+" AS d") {
+		t.Errorf("changedDatesQuery() = %q, want distinct parsed dates", query)
+	}
+}
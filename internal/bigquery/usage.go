@@ -0,0 +1,89 @@
+package bigquery
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultPage is the job label used for queries issued outside a page handler.
+const defaultPage = "other"
+
+type pageKey struct{}
+
+// WithPage returns a context whose queries are labelled and accounted as
+// issued by the named Pearl page. Names must be valid BigQuery label values:
+// lowercase letters, digits, underscores and dashes.
+func WithPage(ctx context.Context, page string) context.Context {
+	return context.WithValue(ctx, pageKey{}, page)
+}
+
+// PageFromContext returns the page name attached by WithPage, or "other".
+func PageFromContext(ctx context.Context) string {
+	if page, ok := ctx.Value(pageKey{}).(string); ok && page != "" {
+		return page
+	}
+	return defaultPage
+}
+
+// UsageRecord aggregates the queries a single page issued on a single day.
+type UsageRecord struct {
+	Page           string
+	Day            time.Time // UTC midnight
+	Queries        int
+	BytesProcessed int64
+	BytesBilled    int64
+}
+
+type usageKey struct {
+	page string
+	day  string
+}
+
+// Usage records bytes processed per page per day since the process started.
+// It is safe for concurrent use.
+type Usage struct {
+	mu      sync.Mutex
+	records map[usageKey]*UsageRecord
+}
+
+// NewUsage creates an empty Usage tracker.
+func NewUsage() *Usage {
+	return &Usage{records: make(map[usageKey]*UsageRecord)}
+}
+
+// Record adds a completed query to the totals for page on the day of at.
+func (u *Usage) Record(page string, at time.Time, processed, billed int64) {
+	day := at.UTC().Truncate(24 * time.Hour)
+	key := usageKey{page: page, day: day.Format("2006-01-02")}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	rec, ok := u.records[key]
+	if !ok {
+		rec = &UsageRecord{Page: page, Day: day}
+		u.records[key] = rec
+	}
+	rec.Queries++
+	rec.BytesProcessed += processed
+	rec.BytesBilled += billed
+}
+
+// Snapshot returns a copy of all records ordered by day, then page.
+func (u *Usage) Snapshot() []UsageRecord {
+	u.mu.Lock()
+	out := make([]UsageRecord, 0, len(u.records))
+	for _, rec := range u.records {
+		out = append(out, *rec)
+	}
+	u.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].Day.Equal(out[j].Day) {
+			return out[i].Day.Before(out[j].Day)
+		}
+		return out[i].Page < out[j].Page
+	})
+	return out
+}
@@ -0,0 +1,45 @@
+package bigquery
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPageFromContext(t *testing.T) {
+	if got := PageFromContext(context.Background()); got != "other" {
+		t.Errorf("PageFromContext(background) = %q, want %q", got, "other")
+	}
+	ctx := WithPage(context.Background(), "heatmap")
+	if got := PageFromContext(ctx); got != "heatmap" {
+		t.Errorf("PageFromContext = %q, want %q", got, "heatmap")
+	}
+}
+
+func TestUsage_RecordAggregatesByPageAndDay(t *testing.T) {
+	u := NewUsage()
+	morning := time.Date(2024, 3, 5, 8, 0, 0, 0, time.UTC)
+	evening := time.Date(2024, 3, 5, 20, 0, 0, 0, time.UTC)
+	nextDay := time.Date(2024, 3, 6, 9, 0, 0, 0, time.UTC)
+
+	u.Record("commutes", morning, 100, 0)
+	u.Record("commutes", evening, 200, 10485760)
+	u.Record("heatmap", evening, 50, 0)
+	u.Record("commutes", nextDay, 300, 0)
+
+	recs := u.Snapshot()
+	if len(recs) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(recs))
+	}
+
+	first := recs[0]
+	if first.Page != "commutes" || first.Queries != 2 || first.BytesProcessed != 300 || first.BytesBilled != 10485760 {
+		t.Errorf("recs[0] = %+v, want commutes/2 queries/300 processed/10485760 billed", first)
+	}
+	if recs[1].Page != "heatmap" {
+		t.Errorf("recs[1].Page = %q, want heatmap (same day, sorted by page)", recs[1].Page)
+	}
+	if !recs[2].Day.Equal(time.Date(2024, 3, 6, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("recs[2].Day = %v, want 2024-03-06", recs[2].Day)
+	}
+}
@@ -0,0 +1,223 @@
+// Package calendar tracks the days on which no commuting is expected: UK bank
+// holidays and personal leave.
+package calendar
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// bankHolidaysJSON lists the bank holidays from 2019 to 2027 in the format of
+// https://www.gov.uk/bank-holidays.json. Newer lists can be downloaded from
+// there and loaded with BankHolidays.
+//
+//go:embed bank-holidays.json
+var bankHolidaysJSON []byte
+
+// Kinds of DayOff.
+const (
+	KindBankHoliday = "bank-holiday"
+	KindLeave       = "leave"
+)
+
+// Divisions of the UK with their own bank holidays, as named by gov.uk.
+const (
+	DivisionEnglandAndWales = "england-and-wales"
+	DivisionScotland        = "scotland"
+	DivisionNorthernIreland = "northern-ireland"
+)
+
+// maxLeaveDays bounds a single leave entry so that a typo in a year cannot
+// expand into decades of days.
+const maxLeaveDays = 366
+
+// DayOff is a day on which no commuting is expected.
+type DayOff struct {
+	Date time.Time
+	Kind string // KindBankHoliday or KindLeave
+	Name string // e.g. "Christmas Day"; may be empty for leave
+}
+
+// Calendar looks up days off by date. A nil Calendar has no days off.
+type Calendar struct {
+	days map[string]DayOff
+}
+
+// New returns a Calendar of the given days. When several entries fall on the
+// same date the first one wins, so bank holidays should precede leave.
+func New(days ...[]DayOff) *Calendar {
+	c := &Calendar{days: make(map[string]DayOff)}
+	for _, list := range days {
+		for _, d := range list {
+			key := d.Date.Format("2006-01-02")
+			if _, ok := c.days[key]; !ok {
+				c.days[key] = d
+			}
+		}
+	}
+	return c
+}
+
+// Lookup returns the day off on the date of t, if any.
+func (c *Calendar) Lookup(t time.Time) (DayOff, bool) {
+	if c == nil {
+		return DayOff{}, false
+	}
+	d, ok := c.days[t.Format("2006-01-02")]
+	return d, ok
+}
+
+// Len returns the number of days off in c.
+func (c *Calendar) Len() int {
+	if c == nil {
+		return 0
+	}
+	return len(c.days)
+}
+
+// EmbeddedBankHolidays returns the bank holidays of division from the list
+// built into Pearl.
+func EmbeddedBankHolidays(division string) ([]DayOff, error) {
+	return BankHolidays(bytes.NewReader(bankHolidaysJSON), division)
+}
+
+// BankHolidays parses the bank holidays of division from r, which holds JSON
+// in the format served by https://www.gov.uk/bank-holidays.json.
+func BankHolidays(r io.Reader, division string) ([]DayOff, error) {
+	var doc map[string]struct {
+		Events []struct {
+			Title string `json:"title"`
+			Date  string `json:"date"`
+		} `json:"events"`
+	}
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("parsing bank holidays: %w", err)
+	}
+	div, ok := doc[division]
+	if !ok {
+		return nil, fmt.Errorf("no bank holidays for division %q", division)
+	}
+
+	days := make([]DayOff, 0, len(div.Events))
+	for _, e := range div.Events {
+		t, err := time.Parse("2006-01-02", e.Date)
+		if err != nil {
+			return nil, fmt.Errorf("parsing bank holiday %q: %w", e.Title, err)
+		}
+		days = append(days, DayOff{Date: t, Kind: KindBankHoliday, Name: e.Title})
+	}
+	return days, nil
+}
+
+// Leave returns a leave day for every date from from to to inclusive.
+func Leave(from, to time.Time, name string) ([]DayOff, error) {
+	if to.Before(from) {
+		return nil, fmt.Errorf("leave ends on %s before it starts on %s", to.Format("2006-01-02"), from.Format("2006-01-02"))
+	}
+	if to.Sub(from) >= maxLeaveDays*24*time.Hour {
+		return nil, fmt.Errorf("leave from %s is longer than %d days", from.Format("2006-01-02"), maxLeaveDays)
+	}
+	var days []DayOff
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		days = append(days, DayOff{Date: d, Kind: KindLeave, Name: name})
+	}
+	return days, nil
+}
+
+// ParseICal returns a leave day for every day covered by the events of an
+// iCalendar (RFC 5545) file, such as an export of an annual leave calendar.
+// An all-day DTEND is exclusive, as in the specification, while a timed
+// DTEND covers its day unless it falls at midnight; events without one cover
+// a single day. Times of day are otherwise ignored.
+func ParseICal(r io.Reader) ([]DayOff, error) {
+	var (
+		days        []DayOff
+		inEvent     bool
+		start, last time.Time
+		summary     string
+	)
+	lines, err := unfoldICal(r)
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range lines {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		// Drop parameters, as in "DTSTART;VALUE=DATE".
+		name, _, _ = strings.Cut(strings.ToUpper(name), ";")
+
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			inEvent, start, last, summary = true, time.Time{}, time.Time{}, ""
+		case !inEvent:
+		case name == "DTSTART":
+			if start, err = parseICalDate(value); err != nil {
+				return nil, err
+			}
+		case name == "DTEND":
+			if last, err = parseICalDate(value); err != nil {
+				return nil, err
+			}
+			// The end is exclusive for dates and for times at midnight.
+			if _, clock, timed := strings.Cut(value, "T"); !timed || strings.HasPrefix(clock, "000000") {
+				last = last.AddDate(0, 0, -1)
+			}
+		case name == "SUMMARY":
+			summary = strings.ReplaceAll(value, `\,`, ",")
+		case name == "END" && value == "VEVENT":
+			inEvent = false
+			if start.IsZero() {
+				return nil, fmt.Errorf("event %q has no DTSTART", summary)
+			}
+			if last.Before(start) {
+				last = start
+			}
+			leave, err := Leave(start, last, summary)
+			if err != nil {
+				return nil, err
+			}
+			days = append(days, leave...)
+		}
+	}
+	return days, nil
+}
+
+// unfoldICal splits r into content lines, joining lines folded onto
+// continuation lines that start with a space or tab.
+func unfoldICal(r io.Reader) ([]string, error) {
+	var lines []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("reading ical: %w", err)
+	}
+	return lines, nil
+}
+
+// parseICalDate parses the date of an iCalendar DATE or DATE-TIME value, such
+// as "20240805" or "20240805T090000Z".
+func parseICalDate(s string) (time.Time, error) {
+	if len(s) < 8 {
+		return time.Time{}, fmt.Errorf("invalid ical date %q", s)
+	}
+	t, err := time.Parse("20060102", s[:8])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid ical date %q", s)
+	}
+	return t, nil
+}
@@ -0,0 +1,157 @@
+package calendar
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestEmbeddedBankHolidays(t *testing.T) {
+	tests := []struct {
+		division string
+		date     string
+		want     string
+	}{
+		{DivisionEnglandAndWales, "2024-12-25", "Christmas Day"},
+		{DivisionEnglandAndWales, "2024-04-01", "Easter Monday"},
+		{DivisionEnglandAndWales, "2022-12-27", "Christmas Day"}, // substitute day
+		{DivisionScotland, "2024-01-02", "2nd January"},
+		{DivisionNorthernIreland, "2024-03-18", "St Patrick’s Day"},
+	}
+	for _, tt := range tests {
+		days, err := EmbeddedBankHolidays(tt.division)
+		if err != nil {
+			t.Fatalf("EmbeddedBankHolidays(%q) unexpected error: %v", tt.division, err)
+		}
+		d, ok := New(days).Lookup(date(tt.date))
+		if !ok || d.Name != tt.want || d.Kind != KindBankHoliday {
+			t.Errorf("%s %s = %+v, %v, want %q", tt.division, tt.date, d, ok, tt.want)
+		}
+	}
+
+	// Scotland has no Easter Monday.
+	days, _ := EmbeddedBankHolidays(DivisionScotland)
+	if _, ok := New(days).Lookup(date("2024-04-01")); ok {
+		t.Error("Scotland: unexpected bank holiday on Easter Monday")
+	}
+	if _, err := EmbeddedBankHolidays("wales"); err == nil {
+		t.Error("EmbeddedBankHolidays(\"wales\") expected an error, got nil")
+	}
+}
+
+func TestBankHolidays(t *testing.T) {
+	doc := `{"england-and-wales": {"division": "england-and-wales", "events": [
+		{"title": "Extra bank holiday", "date": "2030-06-03", "notes": "", "bunting": true}
+	]}}`
+	days, err := BankHolidays(strings.NewReader(doc), DivisionEnglandAndWales)
+	if err != nil {
+		t.Fatalf("BankHolidays() unexpected error: %v", err)
+	}
+	if len(days) != 1 || !days[0].Date.Equal(date("2030-06-03")) || days[0].Name != "Extra bank holiday" {
+		t.Errorf("BankHolidays() = %+v", days)
+	}
+	if _, err := BankHolidays(strings.NewReader("not json"), DivisionEnglandAndWales); err == nil {
+		t.Error("BankHolidays() expected an error for invalid JSON, got nil")
+	}
+}
+
+func TestLeave(t *testing.T) {
+	days, err := Leave(date("2024-08-05"), date("2024-08-09"), "Summer holiday")
+	if err != nil {
+		t.Fatalf("Leave() unexpected error: %v", err)
+	}
+	if len(days) != 5 || days[4].Kind != KindLeave {
+		t.Errorf("Leave() = %+v, want 5 leave days", days)
+	}
+	if _, err := Leave(date("2024-08-09"), date("2024-08-05"), ""); err == nil {
+		t.Error("Leave() expected an error when to is before from, got nil")
+	}
+	if _, err := Leave(date("2024-08-05"), date("2034-08-05"), ""); err == nil {
+		t.Error("Leave() expected an error for a decade of leave, got nil")
+	}
+}
+
+func TestParseICal(t *testing.T) {
+	ics := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"BEGIN:VEVENT",
+		"DTSTART;VALUE=DATE:20240805",
+		"DTEND;VALUE=DATE:20240808",
+		"SUMMARY:Summer holiday\\, Cornwall",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"DTSTART:20240913T090000Z",
+		"SUMMARY:Half",
+		"  day",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"DTSTART:20241021T090000Z",
+		"DTEND:20241023T170000Z",
+		"SUMMARY:Conference",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"DTSTART:20241104T000000",
+		"DTEND:20241106T000000",
+		"SUMMARY:Course",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	days, err := ParseICal(strings.NewReader(ics))
+	if err != nil {
+		t.Fatalf("ParseICal() unexpected error: %v", err)
+	}
+	// An all-day DTEND is exclusive: 5–7 Aug, then 13 Sep. A timed one covers
+	// its day, 21–23 Oct, unless it is at midnight, 4–5 Nov.
+	want := []string{
+		"2024-08-05", "2024-08-06", "2024-08-07", "2024-09-13",
+		"2024-10-21", "2024-10-22", "2024-10-23", "2024-11-04", "2024-11-05",
+	}
+	if len(days) != len(want) {
+		t.Fatalf("ParseICal() returned %d days, want %d", len(days), len(want))
+	}
+	for i, w := range want {
+		if got := days[i].Date.Format("2006-01-02"); got != w {
+			t.Errorf("days[%d] = %s, want %s", i, got, w)
+		}
+	}
+	if days[0].Name != "Summer holiday, Cornwall" {
+		t.Errorf("days[0].Name = %q, want %q", days[0].Name, "Summer holiday, Cornwall")
+	}
+	if days[3].Name != "Half day" {
+		t.Errorf("days[3].Name = %q, want %q", days[3].Name, "Half day")
+	}
+
+	if _, err := ParseICal(strings.NewReader("BEGIN:VEVENT\nDTSTART:2024\nEND:VEVENT")); err == nil {
+		t.Error("ParseICal() expected an error for an invalid date, got nil")
+	}
+}
+
+func TestCalendarLookup(t *testing.T) {
+	c := New(
+		[]DayOff{{Date: date("2024-12-25"), Kind: KindBankHoliday, Name: "Christmas Day"}},
+		[]DayOff{{Date: date("2024-12-25"), Kind: KindLeave}, {Date: date("2024-12-24"), Kind: KindLeave}},
+	)
+	if d, _ := c.Lookup(date("2024-12-25")); d.Kind != KindBankHoliday {
+		t.Errorf("25 Dec kind = %q, want the bank holiday to win", d.Kind)
+	}
+	if d, ok := c.Lookup(date("2024-12-24")); !ok || d.Kind != KindLeave {
+		t.Errorf("24 Dec = %+v, %v, want leave", d, ok)
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", c.Len())
+	}
+
+	var none *Calendar
+	if _, ok := none.Lookup(date("2024-12-25")); ok {
+		t.Error("nil Calendar: unexpected day off")
+	}
+}
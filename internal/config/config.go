@@ -3,8 +3,13 @@ package config
 import (
 	"fmt"
 	"os"
+	"slices"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/its-the-vibe/pearl/internal/tags"
 )
 
 // Config holds all application configuration.
@@ -16,7 +21,244 @@ type Config struct {
 		ProjectID      string `yaml:"project_id"`
 		Dataset        string `yaml:"dataset"`
 		RatingsDataset string `yaml:"ratings_dataset"`
+		// MaxBytesBilled caps the bytes billed per query. 0 means no limit.
+		MaxBytesBilled int64 `yaml:"max_bytes_billed"`
+		// DryRun logs a dry-run byte estimate before every query.
+		DryRun bool `yaml:"dry_run"`
 	} `yaml:"bigquery"`
+	Summary struct {
+		// Enabled makes per-day views read from the daily summary table.
+		Enabled bool `yaml:"enabled"`
+		// RefreshInterval is how often the server refreshes the summary table
+		// in the background. 0 disables the background job.
+		RefreshInterval time.Duration `yaml:"refresh_interval"`
+	} `yaml:"summary"`
+	Auth Auth `yaml:"auth"`
+	// Cards lists the Oyster cards Pearl can display. When empty a single
+	// card is derived from the bigquery dataset settings.
+	Cards       []Card      `yaml:"cards"`
+	Attendance  Attendance  `yaml:"attendance"`
+	Calendar    Calendar    `yaml:"calendar"`
+	Stations    Stations    `yaml:"stations"`
+	Annotations Annotations `yaml:"annotations"`
+	// Tags lists rules assigning tags such as "work" or "airport" to
+	// journeys. A rule tagged "commute" replaces the default definition of
+	// commutes.
+	Tags []TagRule `yaml:"tags"`
+	// Digest configures summary emails sent on a schedule.
+	Digest Digest `yaml:"digest"`
+}
+
+// Digest periods accepted in the digest.periods setting.
+const (
+	DigestWeek  = "week"
+	DigestMonth = "month"
+)
+
+// SMTP TLS modes accepted in the digest.smtp.tls setting.
+const (
+	SMTPStartTLS = "starttls"
+	SMTPTLS      = "tls"
+	SMTPNoTLS    = "none"
+)
+
+// Digest configures the weekly and monthly summary emails of journeys,
+// spend, commutes, cap hits and incomplete journeys.
+type Digest struct {
+	// Periods lists the digests to send: "week" on Mondays for the previous
+	// week and "month" on the 1st for the previous month. Empty disables
+	// digests.
+	Periods []string `yaml:"periods"`
+	// SendAt is the time of day in UTC that digests are sent, e.g. "08:00"
+	// (the default).
+	SendAt string   `yaml:"send_at"`
+	From   string   `yaml:"from"`
+	To     []string `yaml:"to"`
+	// Cards lists the IDs of the cards to send digests for. Empty sends one
+	// for every card.
+	Cards []string `yaml:"cards"`
+	// BaseURL is the public URL of the dashboard, linked from the digests.
+	BaseURL string `yaml:"base_url"`
+	SMTP    SMTP   `yaml:"smtp"`
+}
+
+// SMTP configures the mail server digests are sent through.
+type SMTP struct {
+	Host string `yaml:"host"`
+	// Port defaults to 587, or 465 with TLS.
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	// TLS is "starttls" (the default), "tls" for implicit TLS or "none",
+	// e.g. for a local test server such as MailHog.
+	TLS string `yaml:"tls"`
+}
+
+// SendTime returns SendAt as a time of day from midnight. It assumes the
+// digest settings have been validated.
+func (d Digest) SendTime() time.Duration {
+	t, _ := time.Parse("15:04", d.SendAt)
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+}
+
+// TagRule assigns Tag to the journeys meeting all of its conditions; omitted
+// conditions match every journey.
+type TagRule struct {
+	Tag string `yaml:"tag"`
+	// Stations matches journeys starting or ending at any of the stations.
+	Stations []string `yaml:"stations"`
+	// Weekdays matches journeys on any of the days, e.g. "mon" or "saturday".
+	Weekdays []string `yaml:"weekdays"`
+	// From and To bound the start time, e.g. "07:00" and "10:30".
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+	// Note matches journeys whose note contains the text, ignoring case.
+	Note string `yaml:"note"`
+}
+
+// Annotation backends accepted in the annotations.backend setting.
+const (
+	AnnotationsNone     = "none"
+	AnnotationsFile     = "file"
+	AnnotationsBigQuery = "bigquery"
+)
+
+// Annotations configures where personal notes and tags on days and journeys
+// are stored.
+type Annotations struct {
+	// Backend is "none" (the default, which disables annotations), "file"
+	// or "bigquery", which keeps them in the bigquery dataset.
+	Backend string `yaml:"backend"`
+	// File is the JSON file of the file backend. Its directory must be
+	// writable.
+	File string `yaml:"file"`
+}
+
+// Stations configures the station reference data.
+type Stations struct {
+	// File is an optional station data CSV replacing the built-in list. It
+	// has the columns name, aliases, zones, lines, lat and lon.
+	File string `yaml:"file"`
+}
+
+// Calendar configures the bank holidays and leave days that are marked on the
+// heatmap and excluded from commute and attendance statistics.
+type Calendar struct {
+	// Division selects the bank holidays of "england-and-wales" (the
+	// default), "scotland" or "northern-ireland".
+	Division string `yaml:"division"`
+	// BankHolidaysFile is an optional copy of
+	// https://www.gov.uk/bank-holidays.json replacing the built-in list.
+	BankHolidaysFile string `yaml:"bank_holidays_file"`
+	// Leave lists periods of annual leave.
+	Leave []Leave `yaml:"leave"`
+	// LeaveICal is an optional iCalendar file whose events are leave days.
+	LeaveICal string `yaml:"leave_ical"`
+}
+
+// Leave is an inclusive period of annual leave.
+type Leave struct {
+	From string `yaml:"from"` // e.g. "2024-08-05"
+	To   string `yaml:"to"`   // defaults to From
+	Note string `yaml:"note"`
+}
+
+// Attendance configures the office attendance tracker, which infers office
+// days from journeys to or from a work station.
+type Attendance struct {
+	// WorkStations lists the stations whose journeys mark a day in the office.
+	WorkStations []string `yaml:"work_stations"`
+	// WeeklyTarget is the required number of office days per week.
+	WeeklyTarget int `yaml:"weekly_target"`
+	// MonthlyTarget is the required number of office days per calendar
+	// month. 0 means no monthly target.
+	MonthlyTarget int `yaml:"monthly_target"`
+	// Overrides set the attendance of individual days regardless of
+	// journeys, such as days we drove or cycled to the office.
+	Overrides []AttendanceOverride `yaml:"overrides"`
+}
+
+// AttendanceOverride manually records whether a day was spent in the office.
+type AttendanceOverride struct {
+	Date string `yaml:"date"` // e.g. "2024-03-05"
+	// Office defaults to true; set it to false to discount a day with
+	// journeys to a work station.
+	Office *bool  `yaml:"office"`
+	Note   string `yaml:"note"`
+}
+
+// InOffice reports whether the override marks the day as an office day.
+func (o AttendanceOverride) InOffice() bool {
+	return o.Office == nil || *o.Office
+}
+
+// Card configures one Oyster card (or household member's profile) whose
+// journeys live in their own BigQuery dataset.
+type Card struct {
+	ID             string `yaml:"id"`
+	Name           string `yaml:"name"`
+	Dataset        string `yaml:"dataset"`
+	RatingsDataset string `yaml:"ratings_dataset"`
+	// Users lists the usernames or emails allowed to view the card. An empty
+	// list allows every authenticated user.
+	Users []string `yaml:"users"`
+}
+
+// Auth modes accepted in the auth.mode setting.
+const (
+	AuthModeNone  = "none"
+	AuthModeBasic = "basic"
+	AuthModeProxy = "proxy"
+	AuthModeOIDC  = "oidc"
+)
+
+// Access levels for endpoints that may be exempted from authentication.
+const (
+	AccessPublic    = "public"
+	AccessProtected = "protected"
+)
+
+// Auth configures how dashboard users are authenticated.
+type Auth struct {
+	// Mode is one of "none" (default), "basic", "proxy" or "oidc".
+	Mode string `yaml:"mode"`
+	// Health controls access to /health: "public" (default) or "protected".
+	Health string `yaml:"health"`
+	// Metrics controls access to /admin/usage: "protected" (default) or "public".
+	Metrics string    `yaml:"metrics"`
+	Basic   BasicAuth `yaml:"basic"`
+	Proxy   ProxyAuth `yaml:"proxy"`
+	OIDC    OIDCAuth  `yaml:"oidc"`
+}
+
+// BasicAuth configures HTTP basic authentication.
+type BasicAuth struct {
+	Realm string `yaml:"realm"`
+	// Users maps usernames to bcrypt password hashes.
+	Users map[string]string `yaml:"users"`
+}
+
+// ProxyAuth configures authentication by a trusted reverse proxy that passes
+// the authenticated user in a request header.
+type ProxyAuth struct {
+	UserHeader  string `yaml:"user_header"`
+	EmailHeader string `yaml:"email_header"`
+	// TrustedProxies lists the CIDR ranges whose requests may set the headers.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+// OIDCAuth configures OpenID Connect login with session cookies.
+type OIDCAuth struct {
+	IssuerURL    string   `yaml:"issuer_url"`
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret"`
+	RedirectURL  string   `yaml:"redirect_url"`
+	Scopes       []string `yaml:"scopes"`
+	// AllowedEmails restricts login to these addresses; empty allows anyone
+	// the identity provider authenticates.
+	AllowedEmails []string      `yaml:"allowed_emails"`
+	SessionSecret string        `yaml:"session_secret"`
+	SessionTTL    time.Duration `yaml:"session_ttl"`
 }
 
 // Load reads and parses a YAML config file at the given path.
@@ -37,6 +279,269 @@ func Load(path string) (*Config, error) {
 	if cfg.Server.Port == 0 {
 		cfg.Server.Port = 8080
 	}
+	if cfg.BigQuery.MaxBytesBilled < 0 {
+		return nil, fmt.Errorf("bigquery.max_bytes_billed must not be negative")
+	}
+	if cfg.Summary.RefreshInterval < 0 {
+		return nil, fmt.Errorf("summary.refresh_interval must not be negative")
+	}
+	if err := cfg.Auth.applyDefaults(); err != nil {
+		return nil, err
+	}
+	if err := cfg.applyCardDefaults(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Attendance.validate(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Calendar.applyDefaults(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Annotations.applyDefaults(); err != nil {
+		return nil, err
+	}
+	for i, r := range cfg.Tags {
+		if err := r.validate(); err != nil {
+			return nil, fmt.Errorf("tags[%d]: %w", i, err)
+		}
+	}
+	if err := cfg.applyDigestDefaults(); err != nil {
+		return nil, err
+	}
 
 	return &cfg, nil
 }
+
+// applyDefaults fills in default auth settings and validates the settings
+// required by the selected mode.
+func (a *Auth) applyDefaults() error {
+	if a.Mode == "" {
+		a.Mode = AuthModeNone
+	}
+	if a.Health == "" {
+		a.Health = AccessPublic
+	}
+	if a.Metrics == "" {
+		a.Metrics = AccessProtected
+	}
+	for name, v := range map[string]string{"auth.health": a.Health, "auth.metrics": a.Metrics} {
+		if v != AccessPublic && v != AccessProtected {
+			return fmt.Errorf("%s must be %q or %q, got %q", name, AccessPublic, AccessProtected, v)
+		}
+	}
+
+	switch a.Mode {
+	case AuthModeNone:
+	case AuthModeBasic:
+		if a.Basic.Realm == "" {
+			a.Basic.Realm = "Pearl"
+		}
+		if len(a.Basic.Users) == 0 {
+			return fmt.Errorf("auth.basic.users must list at least one user")
+		}
+		for user, hash := range a.Basic.Users {
+			if !strings.HasPrefix(hash, "$2a$") && !strings.HasPrefix(hash, "$2b$") && !strings.HasPrefix(hash, "$2y$") {
+				return fmt.Errorf("auth.basic.users.%s must be a bcrypt hash", user)
+			}
+		}
+	case AuthModeProxy:
+		if a.Proxy.UserHeader == "" {
+			a.Proxy.UserHeader = "X-Forwarded-User"
+		}
+		if len(a.Proxy.TrustedProxies) == 0 {
+			return fmt.Errorf("auth.proxy.trusted_proxies must list at least one CIDR range")
+		}
+	case AuthModeOIDC:
+		if a.OIDC.IssuerURL == "" || a.OIDC.ClientID == "" || a.OIDC.RedirectURL == "" {
+			return fmt.Errorf("auth.oidc requires issuer_url, client_id and redirect_url")
+		}
+		if len(a.OIDC.SessionSecret) < 32 {
+			return fmt.Errorf("auth.oidc.session_secret must be at least 32 characters")
+		}
+		if len(a.OIDC.Scopes) == 0 {
+			a.OIDC.Scopes = []string{"openid", "email", "profile"}
+		}
+		if a.OIDC.SessionTTL == 0 {
+			a.OIDC.SessionTTL = 12 * time.Hour
+		}
+	default:
+		return fmt.Errorf("auth.mode must be one of none, basic, proxy or oidc, got %q", a.Mode)
+	}
+	return nil
+}
+
+// applyCardDefaults derives the default card from the bigquery settings when
+// no cards are configured, and validates configured cards.
+func (c *Config) applyCardDefaults() error {
+	if len(c.Cards) == 0 {
+		c.Cards = []Card{{
+			ID:             "default",
+			Name:           "My card",
+			Dataset:        c.BigQuery.Dataset,
+			RatingsDataset: c.BigQuery.RatingsDataset,
+		}}
+		return nil
+	}
+
+	seen := make(map[string]bool, len(c.Cards))
+	for i := range c.Cards {
+		card := &c.Cards[i]
+		if card.ID == "" || card.Dataset == "" {
+			return fmt.Errorf("cards[%d] requires id and dataset", i)
+		}
+		if seen[card.ID] {
+			return fmt.Errorf("cards[%d]: duplicate id %q", i, card.ID)
+		}
+		seen[card.ID] = true
+		if card.Name == "" {
+			card.Name = card.ID
+		}
+	}
+	return nil
+}
+
+// validate checks the attendance targets and override dates.
+func (a *Attendance) validate() error {
+	if a.WeeklyTarget < 0 || a.WeeklyTarget > 7 {
+		return fmt.Errorf("attendance.weekly_target must be between 0 and 7")
+	}
+	if a.MonthlyTarget < 0 || a.MonthlyTarget > 31 {
+		return fmt.Errorf("attendance.monthly_target must be between 0 and 31")
+	}
+	seen := make(map[string]bool, len(a.Overrides))
+	for i, o := range a.Overrides {
+		if _, err := time.Parse("2006-01-02", o.Date); err != nil {
+			return fmt.Errorf("attendance.overrides[%d].date must be a YYYY-MM-DD date, got %q", i, o.Date)
+		}
+		if seen[o.Date] {
+			return fmt.Errorf("attendance.overrides[%d]: duplicate date %s", i, o.Date)
+		}
+		seen[o.Date] = true
+	}
+	return nil
+}
+
+// applyDefaults fills in the default bank holiday division and leave end
+// dates, and validates the leave dates.
+func (c *Calendar) applyDefaults() error {
+	switch c.Division {
+	case "":
+		c.Division = "england-and-wales"
+	case "england-and-wales", "scotland", "northern-ireland":
+	default:
+		return fmt.Errorf("calendar.division must be england-and-wales, scotland or northern-ireland, got %q", c.Division)
+	}
+	for i := range c.Leave {
+		l := &c.Leave[i]
+		if l.To == "" {
+			l.To = l.From
+		}
+		from, err := time.Parse("2006-01-02", l.From)
+		if err != nil {
+			return fmt.Errorf("calendar.leave[%d].from must be a YYYY-MM-DD date, got %q", i, l.From)
+		}
+		to, err := time.Parse("2006-01-02", l.To)
+		if err != nil {
+			return fmt.Errorf("calendar.leave[%d].to must be a YYYY-MM-DD date, got %q", i, l.To)
+		}
+		if to.Before(from) {
+			return fmt.Errorf("calendar.leave[%d].to must not be before from", i)
+		}
+	}
+	return nil
+}
+
+// applyDefaults selects the default annotations backend and validates the
+// settings required by the selected one.
+func (a *Annotations) applyDefaults() error {
+	switch a.Backend {
+	case "":
+		a.Backend = AnnotationsNone
+	case AnnotationsNone, AnnotationsBigQuery:
+	case AnnotationsFile:
+		if a.File == "" {
+			return fmt.Errorf("annotations.file is required by the file backend")
+		}
+	default:
+		return fmt.Errorf("annotations.backend must be one of none, file or bigquery, got %q", a.Backend)
+	}
+	return nil
+}
+
+// validate reports whether the rule has a tag, known weekdays and a complete
+// HH:MM start time window.
+func (r TagRule) validate() error {
+	if strings.TrimSpace(r.Tag) == "" {
+		return fmt.Errorf("tag is required")
+	}
+	for _, d := range r.Weekdays {
+		if _, ok := tags.ParseWeekday(d); !ok {
+			return fmt.Errorf("unknown weekday %q", d)
+		}
+	}
+	if (r.From == "") != (r.To == "") {
+		return fmt.Errorf("from and to must be set together")
+	}
+	for _, t := range []string{r.From, r.To} {
+		if _, err := time.Parse("15:04", t); t != "" && err != nil {
+			return fmt.Errorf("time %q must be HH:MM", t)
+		}
+	}
+	return nil
+}
+
+// Rule returns the tag rule r configures. It assumes r has been validated.
+func (r TagRule) Rule() tags.Rule {
+	rule := tags.Rule{Tag: r.Tag, Stations: r.Stations, From: r.From, To: r.To, Note: r.Note}
+	for _, d := range r.Weekdays {
+		wd, _ := tags.ParseWeekday(d)
+		rule.Weekdays = append(rule.Weekdays, wd)
+	}
+	return rule
+}
+
+// applyDigestDefaults fills in default digest settings and, when digests are
+// enabled, validates the periods, recipients, cards and mail server.
+func (c *Config) applyDigestDefaults() error {
+	d := &c.Digest
+	if d.SendAt == "" {
+		d.SendAt = "08:00"
+	}
+	if _, err := time.Parse("15:04", d.SendAt); err != nil {
+		return fmt.Errorf("digest.send_at %q must be HH:MM", d.SendAt)
+	}
+	switch d.SMTP.TLS {
+	case "":
+		d.SMTP.TLS = SMTPStartTLS
+	case SMTPStartTLS, SMTPTLS, SMTPNoTLS:
+	default:
+		return fmt.Errorf("digest.smtp.tls must be one of starttls, tls or none, got %q", d.SMTP.TLS)
+	}
+	if d.SMTP.Port == 0 {
+		d.SMTP.Port = 587
+		if d.SMTP.TLS == SMTPTLS {
+			d.SMTP.Port = 465
+		}
+	}
+	if len(d.Periods) == 0 {
+		return nil
+	}
+
+	for _, p := range d.Periods {
+		if p != DigestWeek && p != DigestMonth {
+			return fmt.Errorf("digest.periods: unknown period %q, use week or month", p)
+		}
+	}
+	if d.SMTP.Host == "" {
+		return fmt.Errorf("digest.smtp.host is required to send digests")
+	}
+	if d.From == "" || len(d.To) == 0 {
+		return fmt.Errorf("digest.from and digest.to are required to send digests")
+	}
+	for _, id := range d.Cards {
+		if !slices.ContainsFunc(c.Cards, func(card Card) bool { return card.ID == id }) {
+			return fmt.Errorf("digest.cards: unknown card %q", id)
+		}
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func writeConfig(t *testing.T, content string) string {
@@ -95,3 +96,497 @@ unknown_field: "oops"
 		t.Fatal("Load() expected an error for unknown field, got nil")
 	}
 }
+
+func TestLoad_QueryGuardrails(t *testing.T) {
+	path := writeConfig(t, `
+bigquery:
+  project_id: "proj"
+  dataset: "ds"
+  max_bytes_billed: 1073741824
+  dry_run: true
+`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+	if cfg.BigQuery.MaxBytesBilled != 1<<30 {
+		t.Errorf("BigQuery.MaxBytesBilled = %d, want %d", cfg.BigQuery.MaxBytesBilled, 1<<30)
+	}
+	if !cfg.BigQuery.DryRun {
+		t.Error("BigQuery.DryRun = false, want true")
+	}
+}
+
+func TestLoad_NegativeMaxBytesBilled(t *testing.T) {
+	path := writeConfig(t, `
+bigquery:
+  project_id: "proj"
+  dataset: "ds"
+  max_bytes_billed: -1
+`)
+
+	_, err := Load(path)
+	if err == nil {
+		t.Fatal("Load() expected an error for negative max_bytes_billed, got nil")
+	}
+}
+
+func TestLoad_Summary(t *testing.T) {
+	path := writeConfig(t, `
+bigquery:
+  project_id: "proj"
+  dataset: "ds"
+summary:
+  enabled: true
+  refresh_interval: 30m
+`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+	if !cfg.Summary.Enabled {
+		t.Error("Summary.Enabled = false, want true")
+	}
+	if cfg.Summary.RefreshInterval != 30*time.Minute {
+		t.Errorf("Summary.RefreshInterval = %v, want 30m", cfg.Summary.RefreshInterval)
+	}
+}
+
+func TestLoad_AuthDefaults(t *testing.T) {
+	path := writeConfig(t, `
+bigquery:
+  project_id: "proj"
+  dataset: "ds"
+`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+	if cfg.Auth.Mode != AuthModeNone {
+		t.Errorf("Auth.Mode = %q, want %q", cfg.Auth.Mode, AuthModeNone)
+	}
+	if cfg.Auth.Health != AccessPublic {
+		t.Errorf("Auth.Health = %q, want %q", cfg.Auth.Health, AccessPublic)
+	}
+	if cfg.Auth.Metrics != AccessProtected {
+		t.Errorf("Auth.Metrics = %q, want %q", cfg.Auth.Metrics, AccessProtected)
+	}
+}
+
+func TestLoad_AuthBasic(t *testing.T) {
+	path := writeConfig(t, `
+auth:
+  mode: basic
+  metrics: public
+  basic:
+    users:
+      alice: "$2a$10$7EqJtq98hPqEX7fNZaFWoOhi5BWX4Z5y5JdZ1J5Zp5m5p5m5p5m5p"
+`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+	if cfg.Auth.Basic.Realm != "Pearl" {
+		t.Errorf("Auth.Basic.Realm = %q, want default %q", cfg.Auth.Basic.Realm, "Pearl")
+	}
+	if cfg.Auth.Metrics != AccessPublic {
+		t.Errorf("Auth.Metrics = %q, want %q", cfg.Auth.Metrics, AccessPublic)
+	}
+}
+
+func TestLoad_AuthInvalid(t *testing.T) {
+	tests := map[string]string{
+		"unknown mode": `
+auth:
+  mode: magic
+`,
+		"plaintext password": `
+auth:
+  mode: basic
+  basic:
+    users:
+      alice: "hunter2"
+`,
+		"proxy without trusted ranges": `
+auth:
+  mode: proxy
+`,
+		"short session secret": `
+auth:
+  mode: oidc
+  oidc:
+    issuer_url: "https://accounts.example.com"
+    client_id: "pearl"
+    redirect_url: "https://pearl.example.com/auth/callback"
+    session_secret: "too-short"
+`,
+		"bad health access": `
+auth:
+  health: sometimes
+`,
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Load(writeConfig(t, content)); err == nil {
+				t.Fatal("Load() expected an error, got nil")
+			}
+		})
+	}
+}
+
+func TestLoad_DefaultCard(t *testing.T) {
+	path := writeConfig(t, `
+bigquery:
+  project_id: "proj"
+  dataset: "ds"
+  ratings_dataset: "ratings"
+`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+	if len(cfg.Cards) != 1 {
+		t.Fatalf("expected 1 default card, got %d", len(cfg.Cards))
+	}
+	card := cfg.Cards[0]
+	if card.ID != "default" || card.Dataset != "ds" || card.RatingsDataset != "ratings" {
+		t.Errorf("default card = %+v, want id default using the bigquery datasets", card)
+	}
+}
+
+func TestLoad_Cards(t *testing.T) {
+	path := writeConfig(t, `
+bigquery:
+  project_id: "proj"
+cards:
+  - id: alice
+    name: "Alice"
+    dataset: "alice_ds"
+    users: ["alice"]
+  - id: bob
+    dataset: "bob_ds"
+`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+	if len(cfg.Cards) != 2 {
+		t.Fatalf("expected 2 cards, got %d", len(cfg.Cards))
+	}
+	if cfg.Cards[0].Name != "Alice" || len(cfg.Cards[0].Users) != 1 {
+		t.Errorf("cards[0] = %+v", cfg.Cards[0])
+	}
+	// Name defaults to the id.
+	if cfg.Cards[1].Name != "bob" {
+		t.Errorf("cards[1].Name = %q, want %q", cfg.Cards[1].Name, "bob")
+	}
+}
+
+func TestLoad_InvalidCards(t *testing.T) {
+	tests := map[string]string{
+		"missing dataset": `
+cards:
+  - id: alice
+`,
+		"duplicate id": `
+cards:
+  - id: alice
+    dataset: a
+  - id: alice
+    dataset: b
+`,
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Load(writeConfig(t, content)); err == nil {
+				t.Fatal("Load() expected an error, got nil")
+			}
+		})
+	}
+}
+
+func TestLoad_Attendance(t *testing.T) {
+	path := writeConfig(t, `
+attendance:
+  work_stations: ["Bank", "Liverpool Street"]
+  weekly_target: 3
+  monthly_target: 12
+  overrides:
+    - date: "2024-03-05"
+      note: "cycled"
+    - date: "2024-03-06"
+      office: false
+`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+	a := cfg.Attendance
+	if len(a.WorkStations) != 2 || a.WeeklyTarget != 3 || a.MonthlyTarget != 12 {
+		t.Errorf("Attendance = %+v", a)
+	}
+	if len(a.Overrides) != 2 {
+		t.Fatalf("expected 2 overrides, got %d", len(a.Overrides))
+	}
+	// Office defaults to true.
+	if !a.Overrides[0].InOffice() {
+		t.Error("Overrides[0].InOffice() = false, want true")
+	}
+	if a.Overrides[1].InOffice() {
+		t.Error("Overrides[1].InOffice() = true, want false")
+	}
+}
+
+func TestLoad_InvalidAttendance(t *testing.T) {
+	tests := map[string]string{
+		"weekly target too high": `
+attendance:
+  weekly_target: 8
+`,
+		"negative monthly target": `
+attendance:
+  monthly_target: -1
+`,
+		"bad override date": `
+attendance:
+  overrides:
+    - date: "05/03/2024"
+`,
+		"duplicate override date": `
+attendance:
+  overrides:
+    - date: "2024-03-05"
+    - date: "2024-03-05"
+`,
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Load(writeConfig(t, content)); err == nil {
+				t.Fatal("Load() expected an error, got nil")
+			}
+		})
+	}
+}
+
+func TestLoad_Calendar(t *testing.T) {
+	cfg, err := Load(writeConfig(t, `
+calendar:
+  leave:
+    - from: "2024-08-05"
+      to: "2024-08-09"
+      note: "Summer holiday"
+    - from: "2024-10-25"
+`))
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+	c := cfg.Calendar
+	if c.Division != "england-and-wales" {
+		t.Errorf("Calendar.Division = %q, want the default england-and-wales", c.Division)
+	}
+	// To defaults to From for single days.
+	if len(c.Leave) != 2 || c.Leave[1].To != "2024-10-25" {
+		t.Errorf("Calendar.Leave = %+v", c.Leave)
+	}
+}
+
+func TestLoad_InvalidCalendar(t *testing.T) {
+	tests := map[string]string{
+		"unknown division": `
+calendar:
+  division: wales
+`,
+		"bad leave date": `
+calendar:
+  leave:
+    - from: "5 Aug 2024"
+`,
+		"leave ends before it starts": `
+calendar:
+  leave:
+    - from: "2024-08-09"
+      to: "2024-08-05"
+`,
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Load(writeConfig(t, content)); err == nil {
+				t.Fatal("Load() expected an error, got nil")
+			}
+		})
+	}
+}
+
+func TestLoad_Annotations(t *testing.T) {
+	cfg, err := Load(writeConfig(t, `
+bigquery:
+  dataset: "ds"
+`))
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+	if cfg.Annotations.Backend != AnnotationsNone {
+		t.Errorf("Annotations.Backend = %q, want the default %q", cfg.Annotations.Backend, AnnotationsNone)
+	}
+
+	cfg, err = Load(writeConfig(t, `
+annotations:
+  backend: file
+  file: "/var/lib/pearl/annotations.json"
+`))
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+	if cfg.Annotations.File != "/var/lib/pearl/annotations.json" {
+		t.Errorf("Annotations.File = %q", cfg.Annotations.File)
+	}
+}
+
+func TestLoad_InvalidAnnotations(t *testing.T) {
+	tests := map[string]string{
+		"unknown backend": `
+annotations:
+  backend: postgres
+`,
+		"file backend without a file": `
+annotations:
+  backend: file
+`,
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Load(writeConfig(t, content)); err == nil {
+				t.Fatal("Load() expected an error, got nil")
+			}
+		})
+	}
+}
+
+func TestLoad_Tags(t *testing.T) {
+	cfg, err := Load(writeConfig(t, `
+tags:
+  - tag: commute
+    stations: ["Clapham Common", "Bank"]
+    weekdays: [mon, Tuesday]
+    from: "07:30"
+    to: "09:30"
+  - tag: late
+    note: "late"
+`))
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+	if len(cfg.Tags) != 2 {
+		t.Fatalf("Tags = %+v, want 2 rules", cfg.Tags)
+	}
+	r := cfg.Tags[0].Rule()
+	if r.Tag != "commute" || len(r.Stations) != 2 || r.From != "07:30" || r.To != "09:30" {
+		t.Errorf("Rule() = %+v", r)
+	}
+	if len(r.Weekdays) != 2 || r.Weekdays[0] != time.Monday || r.Weekdays[1] != time.Tuesday {
+		t.Errorf("Rule().Weekdays = %v, want [Monday Tuesday]", r.Weekdays)
+	}
+}
+
+func TestLoad_InvalidTags(t *testing.T) {
+	tests := map[string]string{
+		"missing tag": `
+tags:
+  - stations: ["Bank"]
+`,
+		"unknown weekday": `
+tags:
+  - tag: work
+    weekdays: [someday]
+`,
+		"from without to": `
+tags:
+  - tag: early
+    from: "06:00"
+`,
+		"invalid time": `
+tags:
+  - tag: early
+    from: "6am"
+    to: "7am"
+`,
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Load(writeConfig(t, content)); err == nil {
+				t.Fatal("Load() expected an error, got nil")
+			}
+		})
+	}
+}
+
+func TestLoad_Digest(t *testing.T) {
+	cfg, err := Load(writeConfig(t, `
+digest:
+  periods: [week, month]
+  send_at: "07:30"
+  from: "pearl@example.com"
+  to: ["me@example.com"]
+  cards: [default]
+  smtp:
+    host: mailhog
+    port: 1025
+    tls: none
+`))
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+	d := cfg.Digest
+	if len(d.Periods) != 2 || d.SMTP.Host != "mailhog" || d.SMTP.Port != 1025 || d.SMTP.TLS != SMTPNoTLS {
+		t.Errorf("Digest = %+v", d)
+	}
+	if got, want := d.SendTime(), 7*time.Hour+30*time.Minute; got != want {
+		t.Errorf("SendTime() = %v, want %v", got, want)
+	}
+}
+
+func TestLoad_DigestDefaults(t *testing.T) {
+	cfg, err := Load(writeConfig(t, `
+digest:
+  smtp:
+    tls: tls
+`))
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+	d := cfg.Digest
+	if len(d.Periods) != 0 || d.SendAt != "08:00" || d.SMTP.Port != 465 {
+		t.Errorf("Digest = %+v, want disabled, sent at 08:00 on port 465", d)
+	}
+}
+
+func TestLoad_InvalidDigest(t *testing.T) {
+	valid := `
+  from: "pearl@example.com"
+  to: ["me@example.com"]
+  smtp:
+    host: mailhog
+`
+	tests := map[string]string{
+		"unknown period":   "digest:\n  periods: [day]" + valid,
+		"invalid send_at":  "digest:\n  periods: [week]\n  send_at: 8am" + valid,
+		"unknown card":     "digest:\n  periods: [week]\n  cards: [bob]" + valid,
+		"missing host":     "digest:\n  periods: [week]\n  from: pearl@example.com\n  to: [me@example.com]\n",
+		"missing to":       "digest:\n  periods: [week]\n  from: pearl@example.com\n  smtp:\n    host: mailhog\n",
+		"unknown tls mode": "digest:\n  smtp:\n    tls: ssl\n",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Load(writeConfig(t, content)); err == nil {
+				t.Fatal("Load() expected an error, got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+// Package digest schedules the weekly and monthly summary emails.
+package digest
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/its-the-vibe/pearl/internal/config"
+)
+
+// Schedule sends digests for Periods at a time of day in UTC: weekly
+// digests on Mondays and monthly digests on the 1st.
+type Schedule struct {
+	Periods []string      // config.DigestWeek or config.DigestMonth
+	At      time.Duration // time of day from midnight UTC
+}
+
+// Next returns the first send time after now.
+func (s Schedule) Next(now time.Time) time.Time {
+	day := now.UTC().Truncate(24 * time.Hour)
+	if t := day.Add(s.At); t.After(now) {
+		return t
+	}
+	return day.AddDate(0, 0, 1).Add(s.At)
+}
+
+// Due returns the periods whose digests are sent on day.
+func (s Schedule) Due(day time.Time) []string {
+	var due []string
+	if day.Weekday() == time.Monday && slices.Contains(s.Periods, config.DigestWeek) {
+		due = append(due, config.DigestWeek)
+	}
+	if day.Day() == 1 && slices.Contains(s.Periods, config.DigestMonth) {
+		due = append(due, config.DigestMonth)
+	}
+	return due
+}
+
+// Run calls send with each period due at every send time, and the day it is
+// due, until ctx is cancelled. Digests due while Pearl was not running are
+// not sent.
+func (s Schedule) Run(ctx context.Context, send func(ctx context.Context, period string, day time.Time)) {
+	for {
+		next := s.Next(time.Now())
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		day := next.Truncate(24 * time.Hour)
+		for _, period := range s.Due(day) {
+			send(ctx, period, day)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package digest
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/its-the-vibe/pearl/internal/config"
+)
+
+func TestScheduleNext(t *testing.T) {
+	s := Schedule{At: 8 * time.Hour}
+	tests := []struct {
+		now, want time.Time
+	}{
+		{time.Date(2024, 3, 11, 7, 59, 0, 0, time.UTC), time.Date(2024, 3, 11, 8, 0, 0, 0, time.UTC)},
+		{time.Date(2024, 3, 11, 8, 0, 0, 0, time.UTC), time.Date(2024, 3, 12, 8, 0, 0, 0, time.UTC)},
+		{time.Date(2024, 3, 31, 23, 0, 0, 0, time.UTC), time.Date(2024, 4, 1, 8, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := s.Next(tt.now); !got.Equal(tt.want) {
+			t.Errorf("Next(%v) = %v, want %v", tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestScheduleDue(t *testing.T) {
+	both := Schedule{Periods: []string{config.DigestWeek, config.DigestMonth}}
+	tests := []struct {
+		day  string
+		s    Schedule
+		want []string
+	}{
+		{"2024-03-11", both, []string{config.DigestWeek}}, // a Monday
+		{"2024-03-12", both, nil},
+		{"2024-04-01", both, []string{config.DigestWeek, config.DigestMonth}}, // a Monday and the 1st
+		{"2024-04-01", Schedule{Periods: []string{config.DigestMonth}}, []string{config.DigestMonth}},
+		{"2024-05-01", Schedule{Periods: []string{config.DigestWeek}}, nil},
+	}
+	for _, tt := range tests {
+		day, _ := time.Parse("2006-01-02", tt.day)
+		if got := tt.s.Due(day); !slices.Equal(got, tt.want) {
+			t.Errorf("Due(%s) with %v = %q, want %q", tt.day, tt.s.Periods, got, tt.want)
+		}
+	}
+}
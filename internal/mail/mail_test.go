@@ -0,0 +1,111 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"mime/quotedprintable"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/its-the-vibe/pearl/internal/config"
+)
+
+func TestMessage(t *testing.T) {
+	date := time.Date(2024, 3, 11, 8, 0, 0, 0, time.UTC)
+	msg, err := message("pearl@example.com", []string{"a@example.com", "b@example.com"}, "Weekly digest – 4–10 Mar 2024", []byte("<p>£12.40</p>"), date)
+	if err != nil {
+		t.Fatalf("message: %v", err)
+	}
+	head, body, ok := strings.Cut(string(msg), "\r\n\r\n")
+	if !ok {
+		t.Fatalf("message has no header separator: %q", msg)
+	}
+	for _, want := range []string{
+		"From: pearl@example.com\r\n",
+		"To: a@example.com, b@example.com\r\n",
+		"Subject: =?utf-8?q?Weekly_digest_=E2=80=93_4=E2=80=9310_Mar_2024?=\r\n",
+		"Date: Mon, 11 Mar 2024 08:00:00 +0000\r\n",
+		"Content-Type: text/html; charset=utf-8\r\n",
+	} {
+		if !strings.Contains(head+"\r\n", want) {
+			t.Errorf("headers missing %q:\n%s", want, head)
+		}
+	}
+	decoded, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(body)))
+	if err != nil || string(decoded) != "<p>£12.40</p>" {
+		t.Errorf("body = %q (%v), want <p>£12.40</p>", decoded, err)
+	}
+}
+
+// fakeServer accepts one SMTP session on a local port and returns the
+// commands and message it received.
+func fakeServer(t *testing.T) (port int, received <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	ch := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var log strings.Builder
+		r := bufio.NewReader(conn)
+		reply := func(s string) { io.WriteString(conn, s+"\r\n") }
+		reply("220 fake ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				break
+			}
+			log.WriteString(line)
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"):
+				reply("250 fake")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					log.WriteString(line)
+				}
+				reply("250 queued")
+			case cmd == "QUIT":
+				reply("221 bye")
+				ch <- log.String()
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+		ch <- log.String()
+	}()
+	return ln.Addr().(*net.TCPAddr).Port, ch
+}
+
+func TestSend(t *testing.T) {
+	port, received := fakeServer(t)
+	s := New(config.SMTP{Host: "127.0.0.1", Port: port, TLS: config.SMTPNoTLS}, "pearl@example.com")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Send(ctx, []string{"me@example.com"}, "Weekly digest", []byte("<p>Hello</p>")); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	session := <-received
+	for _, want := range []string{"MAIL FROM:<pearl@example.com>", "RCPT TO:<me@example.com>", "Subject: Weekly digest", "<p>Hello</p>"} {
+		if !strings.Contains(session, want) {
+			t.Errorf("session missing %q:\n%s", want, session)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+// Package stations provides reference data for London stations: zones,
+// lines and coordinates, matched against the station names that appear in
+// Oyster journey histories.
+package stations
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// embeddedCSV covers the stations of central London and common commuter
+// destinations. A fuller file in the same format can be loaded with Load.
+//
+//go:embed stations.csv
+var embeddedCSV []byte
+
+// csvHeader lists the columns of a station data file. List columns hold
+// values separated by ";"; zones on a boundary are written as "2/3".
+var csvHeader = []string{"name", "aliases", "zones", "lines", "lat", "lon"}
+
+// Station is a station with its reference data.
+type Station struct {
+	Name    string
+	Aliases []string // other spellings, as in Oyster journey histories
+	Zones   []int    // more than one for stations on a zone boundary
+	Lines   []string
+	Lat     float64
+	Lon     float64
+}
+
+// Zone formats the station's zones, such as "1" or "2/3".
+func (s *Station) Zone() string {
+	parts := make([]string, len(s.Zones))
+	for i, z := range s.Zones {
+		parts[i] = strconv.Itoa(z)
+	}
+	return strings.Join(parts, "/")
+}
+
+// Directory looks up stations by name. A nil Directory matches nothing.
+type Directory struct {
+	stations []*Station
+	byKey    map[string]*Station // normalised names and aliases
+}
+
+// Embedded returns the directory of stations built into Pearl.
+func Embedded() (*Directory, error) {
+	return Load(bytes.NewReader(embeddedCSV))
+}
+
+// Load reads a station data file in CSV format with the columns name,
+// aliases, zones, lines, lat and lon.
+func Load(r io.Reader) (*Directory, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = len(csvHeader)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading station header: %w", err)
+	}
+	for i, col := range csvHeader {
+		if strings.TrimSpace(header[i]) != col {
+			return nil, fmt.Errorf("station data column %d is %q, want %q", i+1, header[i], col)
+		}
+	}
+
+	d := &Directory{byKey: make(map[string]*Station)}
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading station data: %w", err)
+		}
+		s, err := parseStation(rec)
+		if err != nil {
+			line, _ := cr.FieldPos(0)
+			return nil, fmt.Errorf("station data line %d: %w", line, err)
+		}
+		for _, name := range append([]string{s.Name}, s.Aliases...) {
+			key := normalize(name)
+			if other, ok := d.byKey[key]; ok && other != s {
+				return nil, fmt.Errorf("station name %q is used by both %s and %s", name, other.Name, s.Name)
+			}
+			d.byKey[key] = s
+		}
+		d.stations = append(d.stations, s)
+	}
+	return d, nil
+}
+
+// parseStation parses a single CSV record.
+func parseStation(rec []string) (*Station, error) {
+	s := &Station{Name: strings.TrimSpace(rec[0])}
+	if s.Name == "" {
+		return nil, fmt.Errorf("missing name")
+	}
+	s.Aliases = splitList(rec[1], ";")
+	s.Lines = splitList(rec[3], ";")
+	for _, z := range splitList(rec[2], "/") {
+		n, err := strconv.Atoi(z)
+		if err != nil || n < 1 || n > 9 {
+			return nil, fmt.Errorf("%s: invalid zone %q", s.Name, z)
+		}
+		s.Zones = append(s.Zones, n)
+	}
+	if len(s.Zones) == 0 {
+		return nil, fmt.Errorf("%s: missing zone", s.Name)
+	}
+	var err error
+	if s.Lat, err = strconv.ParseFloat(strings.TrimSpace(rec[4]), 64); err != nil || s.Lat < -90 || s.Lat > 90 {
+		return nil, fmt.Errorf("%s: invalid latitude %q", s.Name, rec[4])
+	}
+	if s.Lon, err = strconv.ParseFloat(strings.TrimSpace(rec[5]), 64); err != nil || s.Lon < -180 || s.Lon > 180 {
+		return nil, fmt.Errorf("%s: invalid longitude %q", s.Name, rec[5])
+	}
+	return s, nil
+}
+
+func splitList(s, sep string) []string {
+	var out []string
+	for _, part := range strings.Split(s, sep) {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// Len returns the number of stations in d.
+func (d *Directory) Len() int {
+	if d == nil {
+		return 0
+	}
+	return len(d.stations)
+}
+
+// Stations returns every station in d, in file order.
+func (d *Directory) Stations() []*Station {
+	if d == nil {
+		return nil
+	}
+	return d.stations
+}
+
+// Match finds the station called name. Names are compared ignoring case,
+// punctuation, "&" versus "and", and bracketed suffixes such as
+// "[London Underground]". Failing an exact match, a name that is a prefix of
+// exactly one station's name or within a small edit distance of exactly one
+// station matches; fuzzy reports whether such an inexact match was used.
+func (d *Directory) Match(name string) (s *Station, fuzzy, ok bool) {
+	if d == nil {
+		return nil, false, false
+	}
+	key := normalize(name)
+	if key == "" {
+		return nil, false, false
+	}
+	if s, ok := d.byKey[key]; ok {
+		return s, false, true
+	}
+
+	// Truncated names, such as "Heathrow Terminals".
+	var prefixed *Station
+	for k, st := range d.byKey {
+		if len(key) >= 5 && strings.HasPrefix(k, key) {
+			if prefixed != nil && prefixed != st {
+				prefixed = nil
+				break
+			}
+			prefixed = st
+		}
+	}
+	if prefixed != nil {
+		return prefixed, true, true
+	}
+
+	// Misspellings: the closest name within a quarter of its length.
+	var best *Station
+	bestDist, tie := -1, false
+	for k, st := range d.byKey {
+		dist := levenshtein(key, k)
+		if dist > max(1, len(k)/4) {
+			continue
+		}
+		switch {
+		case bestDist < 0 || dist < bestDist:
+			best, bestDist, tie = st, dist, false
+		case dist == bestDist && st != best:
+			tie = true
+		}
+	}
+	if best != nil && !tie {
+		return best, true, true
+	}
+	return nil, false, false
+}
+
+// normalize reduces a station name to a comparison key: lower case, without
+// bracketed suffixes or punctuation, with "&" spelled "and" and "station"
+// dropped.
+func normalize(name string) string {
+	for _, open := range []string{"[", "("} {
+		if i := strings.Index(name, open); i >= 0 {
+			name = name[:i]
+		}
+	}
+	name = strings.ReplaceAll(strings.ToLower(name), "&", " and ")
+
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		case r == '\'' || r == '’' || r == '.':
+			// Dropped: "King's" and "Kings", "St." and "St" match.
+		default:
+			b.WriteRune(' ')
+		}
+	}
+	words := strings.Fields(b.String())
+	if n := len(words); n > 1 && (words[n-1] == "station" || words[n-1] == "stn") {
+		words = words[:n-1]
+	}
+	return strings.Join(words, " ")
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
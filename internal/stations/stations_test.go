@@ -0,0 +1,106 @@
+package stations
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEmbedded(t *testing.T) {
+	d, err := Embedded()
+	if err != nil {
+		t.Fatalf("Embedded() unexpected error: %v", err)
+	}
+	if d.Len() < 50 {
+		t.Errorf("Len() = %d, want the embedded stations", d.Len())
+	}
+	for _, s := range d.Stations() {
+		// Every station should lie within Greater London's bounding box.
+		if s.Lat < 51.2 || s.Lat > 51.8 || s.Lon < -0.6 || s.Lon > 0.4 {
+			t.Errorf("%s at %f, %f is outside London", s.Name, s.Lat, s.Lon)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	d, err := Embedded()
+	if err != nil {
+		t.Fatalf("Embedded() unexpected error: %v", err)
+	}
+	tests := []struct {
+		name      string
+		want      string
+		wantFuzzy bool
+	}{
+		{"Bank", "Bank", false},
+		{"bank", "Bank", false},
+		{"Kings Cross St Pancras", "King's Cross St. Pancras", false},
+		{"Shepherd's Bush [London Underground]", "Shepherd's Bush", false},
+		{"Elephant and Castle", "Elephant & Castle", false},
+		{"Waterloo (Jubilee line entrance)", "Waterloo", false},
+		{"Liverpool Street Station", "Liverpool Street", false},
+		{"Heathrow Terminals", "Heathrow Terminals 2 & 3", true},
+		{"Tottenham Court Rd", "Tottenham Court Road", true},
+		{"Picadilly Circus", "Piccadilly Circus", true},
+	}
+	for _, tt := range tests {
+		s, fuzzy, ok := d.Match(tt.name)
+		if !ok {
+			t.Errorf("Match(%q) found nothing, want %s", tt.name, tt.want)
+			continue
+		}
+		if s.Name != tt.want || fuzzy != tt.wantFuzzy {
+			t.Errorf("Match(%q) = %s (fuzzy %v), want %s (fuzzy %v)", tt.name, s.Name, fuzzy, tt.want, tt.wantFuzzy)
+		}
+	}
+
+	for _, name := range []string{"", "Bus journey, route 88", "Clapham", "Gatwick Airport"} {
+		if s, _, ok := d.Match(name); ok {
+			t.Errorf("Match(%q) = %s, want no match", name, s.Name)
+		}
+	}
+
+	var none *Directory
+	if _, _, ok := none.Match("Bank"); ok {
+		t.Error("nil Directory: unexpected match")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	d, err := Load(strings.NewReader("name,aliases,zones,lines,lat,lon\n" +
+		"Clapham South,Clapham Sth,2/3,Northern,51.4527,-0.1480\n"))
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+	s, _, ok := d.Match("Clapham Sth")
+	if !ok || s.Zone() != "2/3" || len(s.Lines) != 1 {
+		t.Errorf("Match(alias) = %+v, %v", s, ok)
+	}
+
+	invalid := map[string]string{
+		"wrong header":   "station,aliases,zones,lines,lat,lon\n",
+		"missing column": "name,aliases,zones,lines,lat,lon\nBank,,1,Central,51.5\n",
+		"bad zone":       "name,aliases,zones,lines,lat,lon\nBank,,one,Central,51.5,-0.08\n",
+		"bad latitude":   "name,aliases,zones,lines,lat,lon\nBank,,1,Central,north,-0.08\n",
+		"duplicate name": "name,aliases,zones,lines,lat,lon\nBank,,1,,51.5,-0.08\nMonument,bank,1,,51.5,-0.08\n",
+	}
+	for name, content := range invalid {
+		if _, err := Load(strings.NewReader(content)); err == nil {
+			t.Errorf("%s: Load() expected an error, got nil", name)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"King's Cross St. Pancras", "kings cross st pancras"},
+		{"Highbury & Islington", "highbury and islington"},
+		{"Bromley-by-Bow", "bromley by bow"},
+		{"Euston [London Underground]", "euston"},
+		{"Station", "station"},
+	}
+	for _, tt := range tests {
+		if got := normalize(tt.in); got != tt.want {
+			t.Errorf("normalize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
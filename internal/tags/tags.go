@@ -0,0 +1,182 @@
+// Package tags assigns tags such as "work", "leisure" or "airport" to
+// journeys by rules over their stations, start time, weekday and note.
+package tags
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Commute is the tag of the journeys shown on the commutes page.
+const Commute = "commute"
+
+// DefaultCommute assigns the commute tag when no configured rule does:
+// journeys starting between 07:00 and 10:30 on Tuesdays to Thursdays.
+var DefaultCommute = Rule{
+	Tag:      Commute,
+	Weekdays: []time.Weekday{time.Tuesday, time.Wednesday, time.Thursday},
+	From:     "07:00",
+	To:       "10:30",
+}
+
+// Rule assigns Tag to the journeys meeting all of its conditions. Empty
+// conditions match every journey.
+type Rule struct {
+	Tag      string
+	Stations []string       // journeys starting or ending at any of them, ignoring case
+	Weekdays []time.Weekday // journeys on any of these days of the week
+	// From and To bound the start time, e.g. "07:00" and "10:30", inclusive.
+	// A window ending before it starts spans midnight. Journeys without a
+	// start time do not match a window.
+	From, To string
+	Note     string // found in the journey note, ignoring case
+}
+
+// Journey holds the fields of a journey that rules match.
+type Journey struct {
+	Date      time.Time
+	StartTime string // e.g. "08:02"; empty when not recorded
+	From, To  string // stations; empty when not known
+	Note      string
+}
+
+// Validate reports whether r can be matched.
+func (r Rule) Validate() error {
+	if NormalizeTag(r.Tag) == "" {
+		return errors.New("rule has no tag")
+	}
+	if (r.From == "") != (r.To == "") {
+		return fmt.Errorf("rule %q needs both from and to", r.Tag)
+	}
+	for _, s := range []string{r.From, r.To} {
+		if _, ok := parseTime(s); s != "" && !ok {
+			return fmt.Errorf("rule %q: time %q must be HH:MM", r.Tag, s)
+		}
+	}
+	return nil
+}
+
+// Window returns the start-time window of r in minutes from midnight, and
+// false when r has none.
+func (r Rule) Window() (from, to int, ok bool) {
+	from, ok1 := parseTime(r.From)
+	to, ok2 := parseTime(r.To)
+	return from, to, ok1 && ok2
+}
+
+// Matches reports whether j meets every condition of r.
+func (r Rule) Matches(j Journey) bool {
+	if len(r.Weekdays) > 0 && !slices.Contains(r.Weekdays, j.Date.Weekday()) {
+		return false
+	}
+	if len(r.Stations) > 0 && !slices.ContainsFunc(r.Stations, func(s string) bool {
+		return (j.From != "" && strings.EqualFold(j.From, s)) || (j.To != "" && strings.EqualFold(j.To, s))
+	}) {
+		return false
+	}
+	if from, to, ok := r.Window(); ok {
+		start, valid := parseTime(j.StartTime)
+		if !valid {
+			return false
+		}
+		if from <= to && (start < from || start > to) {
+			return false
+		}
+		if from > to && start < from && start > to {
+			return false
+		}
+	}
+	if r.Note != "" && !strings.Contains(strings.ToLower(j.Note), strings.ToLower(r.Note)) {
+		return false
+	}
+	return true
+}
+
+// Rules is the set of configured rules. A nil Rules still assigns the
+// commute tag by DefaultCommute.
+type Rules []Rule
+
+// New validates rules and normalises their tags.
+func New(rules []Rule) (Rules, error) {
+	rs := make(Rules, len(rules))
+	for i, r := range rules {
+		if err := r.Validate(); err != nil {
+			return nil, err
+		}
+		r.Tag = NormalizeTag(r.Tag)
+		rs[i] = r
+	}
+	return rs, nil
+}
+
+// all returns rs with DefaultCommute added when no rule assigns the commute
+// tag.
+func (rs Rules) all() Rules {
+	if slices.ContainsFunc(rs, func(r Rule) bool { return r.Tag == Commute }) {
+		return rs
+	}
+	return append(slices.Clip(rs), DefaultCommute)
+}
+
+// Tags lists the tags the rules assign, in the order they are configured.
+func (rs Rules) Tags() []string {
+	var tags []string
+	for _, r := range rs.all() {
+		if !slices.Contains(tags, r.Tag) {
+			tags = append(tags, r.Tag)
+		}
+	}
+	return tags
+}
+
+// For returns the rules assigning tag.
+func (rs Rules) For(tag string) Rules {
+	tag = NormalizeTag(tag)
+	var out Rules
+	for _, r := range rs.all() {
+		if r.Tag == tag {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// Match returns the tags the rules assign to j, in the order of Tags.
+func (rs Rules) Match(j Journey) []string {
+	var tags []string
+	for _, tag := range rs.Tags() {
+		if slices.ContainsFunc(rs.For(tag), func(r Rule) bool { return r.Matches(j) }) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// NormalizeTag trims and lower-cases a tag so that "Work" and " work" match.
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// ParseWeekday parses a day of the week such as "tue" or "Tuesday".
+func ParseWeekday(s string) (time.Weekday, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		if s == name || s == name[:3] {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// parseTime parses an "HH:MM" time into minutes from midnight.
+func parseTime(s string) (int, bool) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
@@ -0,0 +1,116 @@
+package tags
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestRuleMatches(t *testing.T) {
+	// 2024-03-05 is a Tuesday.
+	tue, sat := date("2024-03-05"), date("2024-03-09")
+	tests := []struct {
+		name string
+		rule Rule
+		j    Journey
+		want bool
+	}{
+		{"no conditions", Rule{Tag: "all"}, Journey{Date: tue}, true},
+		{"station from", Rule{Stations: []string{"bank"}}, Journey{Date: tue, From: "Bank", To: "Oval"}, true},
+		{"station to", Rule{Stations: []string{"Oval", "Bank"}}, Journey{Date: tue, From: "Clapham Common", To: "Bank"}, true},
+		{"other station", Rule{Stations: []string{"Bank"}}, Journey{Date: tue, From: "Clapham Common", To: "Oval"}, false},
+		{"weekday", Rule{Weekdays: []time.Weekday{time.Saturday}}, Journey{Date: sat}, true},
+		{"other weekday", Rule{Weekdays: []time.Weekday{time.Saturday}}, Journey{Date: tue}, false},
+		{"window start", Rule{From: "07:00", To: "10:30"}, Journey{Date: tue, StartTime: "07:00"}, true},
+		{"window end", Rule{From: "07:00", To: "10:30"}, Journey{Date: tue, StartTime: "10:30"}, true},
+		{"after window", Rule{From: "07:00", To: "10:30"}, Journey{Date: tue, StartTime: "10:31"}, false},
+		{"no start time", Rule{From: "07:00", To: "10:30"}, Journey{Date: tue}, false},
+		{"overnight window", Rule{From: "22:00", To: "02:00"}, Journey{Date: tue, StartTime: "00:15"}, true},
+		{"outside overnight window", Rule{From: "22:00", To: "02:00"}, Journey{Date: tue, StartTime: "12:00"}, false},
+		{"note", Rule{Note: "Late"}, Journey{Date: tue, Note: "late again"}, true},
+		{"other note", Rule{Note: "late"}, Journey{Date: tue}, false},
+		{"all conditions", Rule{Stations: []string{"Bank"}, Weekdays: []time.Weekday{time.Tuesday}, From: "08:00", To: "09:00"}, Journey{Date: tue, StartTime: "08:15", To: "Bank"}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.rule.Matches(tt.j); got != tt.want {
+			t.Errorf("%s: Matches = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRuleValidate(t *testing.T) {
+	if err := (Rule{Tag: "commute", From: "7:00", To: "10:30"}).Validate(); err != nil {
+		t.Errorf("Validate() = %v, want nil", err)
+	}
+	for name, r := range map[string]Rule{
+		"no tag":       {Tag: " ", Note: "late"},
+		"only from":    {Tag: "early", From: "07:00"},
+		"invalid time": {Tag: "early", From: "7am", To: "9am"},
+	} {
+		if err := r.Validate(); err == nil {
+			t.Errorf("%s: Validate() = nil, want an error", name)
+		}
+	}
+}
+
+func TestRulesDefaultCommute(t *testing.T) {
+	var none Rules
+	if got := none.For(Commute); len(got) != 1 || got[0].Tag != Commute {
+		t.Errorf("For(commute) = %+v, want DefaultCommute", got)
+	}
+	// 2024-03-04 is a Monday.
+	for _, tt := range []struct {
+		day, start string
+		want       bool
+	}{
+		{"2024-03-05", "08:00", true},
+		{"2024-03-04", "08:00", false},
+		{"2024-03-09", "08:00", false},
+		{"2024-03-06", "06:59", false},
+	} {
+		got := slices.Contains(none.Match(Journey{Date: date(tt.day), StartTime: tt.start}), Commute)
+		if got != tt.want {
+			t.Errorf("commute on %s at %s = %v, want %v", tt.day, tt.start, got, tt.want)
+		}
+	}
+
+	rs, err := New([]Rule{
+		{Tag: "Work", Stations: []string{"Bank"}},
+		{Tag: "commute", Weekdays: []time.Weekday{time.Monday}},
+		{Tag: "work", Stations: []string{"Liverpool Street"}},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if got, want := rs.Tags(), []string{"work", "commute"}; !slices.Equal(got, want) {
+		t.Errorf("Tags() = %q, want %q", got, want)
+	}
+	if got := rs.For("WORK"); len(got) != 2 {
+		t.Errorf("For(WORK) = %+v, want both work rules", got)
+	}
+	if got := rs.For(Commute); len(got) != 1 || got[0].Weekdays[0] != time.Monday {
+		t.Errorf("For(commute) = %+v, want the configured rule only", got)
+	}
+	if got, want := rs.Match(Journey{Date: date("2024-03-04"), To: "Liverpool Street"}), []string{"work", "commute"}; !slices.Equal(got, want) {
+		t.Errorf("Match = %q, want %q", got, want)
+	}
+}
+
+func TestParseWeekday(t *testing.T) {
+	for in, want := range map[string]time.Weekday{"tue": time.Tuesday, "Saturday": time.Saturday, " SUN ": time.Sunday} {
+		if got, ok := ParseWeekday(in); !ok || got != want {
+			t.Errorf("ParseWeekday(%q) = %v, %v, want %v", in, got, ok, want)
+		}
+	}
+	if _, ok := ParseWeekday("tues"); ok {
+		t.Errorf("ParseWeekday(tues) succeeded, want failure")
+	}
+}
@@ -0,0 +1,188 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/its-the-vibe/pearl/internal/annotations"
+	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+)
+
+// loadAnnotations returns the annotations of card dated from from to to, or
+// none when annotations are disabled. Annotations are optional, so failures
+// are logged rather than returned.
+func (h *Handler) loadAnnotations(ctx context.Context, card Card, from, to time.Time) []annotations.Annotation {
+	if h.opts.Annotations == nil {
+		return nil
+	}
+	list, err := h.opts.Annotations.List(ctx, card.ID, from, to)
+	if err != nil {
+		slog.Warn("loading annotations", "card", card.ID, "error", err)
+		return nil
+	}
+	return list
+}
+
+// cardNotes returns the annotations of card, one of the cards of sel, reusing
+// those loaded with the selection for the selected card.
+func (h *Handler) cardNotes(ctx context.Context, sel cardSelection, card Card) []annotations.Annotation {
+	if card.ID == sel.Card.ID {
+		return sel.Notes
+	}
+	return h.loadAnnotations(ctx, card, time.Time{}, time.Time{})
+}
+
+// annotationIndex looks up annotations by ISO date.
+type annotationIndex map[string][]annotations.Annotation
+
+func newAnnotationIndex(list []annotations.Annotation) annotationIndex {
+	ix := make(annotationIndex)
+	for _, a := range list {
+		ix[a.Day()] = append(ix[a.Day()], a)
+	}
+	return ix
+}
+
+// summary describes the annotations of the day and its journeys, such as
+// "strike: walked to Bank; client visit", or returns "" without any.
+func (ix annotationIndex) summary(date string) string {
+	var parts []string
+	for _, a := range ix[date] {
+		s := strings.Join(a.Tags, ", ")
+		switch {
+		case s == "":
+			s = a.Note
+		case a.Note != "":
+			s += ": " + a.Note
+		}
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// annotationTags lists the tags used in list in alphabetical order, together
+// with selected when it is not among them.
+func annotationTags(list []annotations.Annotation, selected string) []string {
+	var tags []string
+	for _, a := range list {
+		tags = append(tags, a.Tags...)
+	}
+	if selected != "" {
+		tags = append(tags, selected)
+	}
+	slices.Sort(tags)
+	return slices.Compact(tags)
+}
+
+// tagSelection returns the days and journeys annotated with tag in list, or
+// nil when none is.
+func tagSelection(list []annotations.Annotation, tag string) *bq.JourneySelection {
+	var sel *bq.JourneySelection
+	for _, a := range list {
+		if !a.HasTag(tag) {
+			continue
+		}
+		if sel == nil {
+			sel = &bq.JourneySelection{}
+		}
+		if a.Journey() {
+			sel.Journeys = append(sel.Journeys, bq.JourneyKey{Date: a.Date, StartTime: a.StartTime, Action: a.Action})
+		} else {
+			sel.Days = append(sel.Days, a.Date)
+		}
+	}
+	return sel
+}
+
+// markHeatmapAnnotations adds the annotations in ix to the labels of the
+// heatmap cells and flags the annotated days.
+func markHeatmapAnnotations(data *HeatmapData, ix annotationIndex) {
+	for _, week := range data.Weeks {
+		for i := range week {
+			if s := ix.summary(week[i].Date); s != "" {
+				week[i].Annotation = s
+				week[i].Label += " – " + s
+				data.AnnotatedDays++
+			}
+		}
+	}
+}
+
+// markCommuteAnnotations flags the commutes on annotated days.
+func markCommuteAnnotations(commutes []CommutePoint, ix annotationIndex) {
+	for i := range commutes {
+		commutes[i].Annotation = ix.summary(commutes[i].ISODate)
+	}
+}
+
+// handleAddAnnotation stores an annotation of the day, or of one of its
+// journeys, posted from the day page.
+func (h *Handler) handleAddAnnotation(w http.ResponseWriter, r *http.Request) {
+	day, ok := parseDayPath(w, r)
+	if !ok {
+		return
+	}
+	if h.opts.Annotations == nil {
+		http.Error(w, "annotations are not enabled", http.StatusNotFound)
+		return
+	}
+	sel, ok := h.selectCards(w, r, "day", false)
+	if !ok {
+		return
+	}
+
+	a := annotations.Annotation{
+		Card: sel.Card.ID,
+		Date: day,
+		Note: strings.TrimSpace(r.PostFormValue("note")),
+		Tags: annotations.ParseTags(r.PostFormValue("tags")),
+	}
+	if journey := r.PostFormValue("journey"); journey != "" {
+		a.StartTime, a.Action, _ = strings.Cut(journey, "|")
+	}
+	if err := a.Validate(); err != nil {
+		h.renderDay(w, r, sel, day, err.Error())
+		return
+	}
+	ctx := bq.WithPage(r.Context(), "day")
+	if _, err := h.opts.Annotations.Add(ctx, a); err != nil {
+		slog.Error("adding annotation", "card", sel.Card.ID, "error", err)
+		http.Error(w, "failed to save annotation", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/day/"+day.Format("2006-01-02"), http.StatusSeeOther)
+}
+
+// handleDeleteAnnotation removes an annotation listed on the day page.
+func (h *Handler) handleDeleteAnnotation(w http.ResponseWriter, r *http.Request) {
+	day, ok := parseDayPath(w, r)
+	if !ok {
+		return
+	}
+	if h.opts.Annotations == nil {
+		http.Error(w, "annotations are not enabled", http.StatusNotFound)
+		return
+	}
+	sel, ok := h.selectCards(w, r, "day", false)
+	if !ok {
+		return
+	}
+
+	ctx := bq.WithPage(r.Context(), "day")
+	err := h.opts.Annotations.Delete(ctx, sel.Card.ID, r.PathValue("id"))
+	if errors.Is(err, annotations.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		slog.Error("deleting annotation", "card", sel.Card.ID, "error", err)
+		http.Error(w, "failed to delete annotation", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/day/"+day.Format("2006-01-02"), http.StatusSeeOther)
+}
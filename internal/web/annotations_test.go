@@ -0,0 +1,73 @@
+package web
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/its-the-vibe/pearl/internal/annotations"
+)
+
+func TestAnnotationIndexSummary(t *testing.T) {
+	ix := newAnnotationIndex([]annotations.Annotation{
+		{Date: mustDate("2024-03-05"), Tags: []string{"strike", "wfh"}, Note: "walked to Bank"},
+		{Date: mustDate("2024-03-05"), StartTime: "18:00", Action: "Bank to Clapham Common", Note: "client visit"},
+		{Date: mustDate("2024-03-06"), Tags: []string{"wfh"}},
+	})
+	tests := map[string]string{
+		"2024-03-05": "strike, wfh: walked to Bank; client visit",
+		"2024-03-06": "wfh",
+		"2024-03-07": "",
+	}
+	for date, want := range tests {
+		if got := ix.summary(date); got != want {
+			t.Errorf("summary(%s) = %q, want %q", date, got, want)
+		}
+	}
+}
+
+func TestAnnotationTags(t *testing.T) {
+	list := []annotations.Annotation{
+		{Tags: []string{"wfh", "strike"}},
+		{Tags: []string{"strike"}},
+	}
+	if got, want := annotationTags(list, ""), []string{"strike", "wfh"}; !slices.Equal(got, want) {
+		t.Errorf("annotationTags = %q, want %q", got, want)
+	}
+	if got, want := annotationTags(list, "client"), []string{"client", "strike", "wfh"}; !slices.Equal(got, want) {
+		t.Errorf("annotationTags(client) = %q, want %q", got, want)
+	}
+}
+
+func TestTagSelection(t *testing.T) {
+	list := []annotations.Annotation{
+		{Date: mustDate("2024-03-05"), Tags: []string{"strike"}},
+		{Date: mustDate("2024-03-06"), StartTime: "18:00", Action: "Bank to Clapham Common", Tags: []string{"strike"}},
+		{Date: mustDate("2024-03-07"), Tags: []string{"wfh"}},
+	}
+	sel := tagSelection(list, "Strike")
+	if len(sel.Days) != 1 || len(sel.Journeys) != 1 || sel.Journeys[0].String() != "2024-03-06 18:00 Bank to Clapham Common" {
+		t.Errorf("tagSelection = %+v, want one day and one journey", sel)
+	}
+	if sel := tagSelection(list, "client"); sel != nil {
+		t.Errorf("tagSelection(client) = %+v, want nil", sel)
+	}
+}
+
+func TestMarkHeatmapAnnotations(t *testing.T) {
+	data := HeatmapData{Weeks: [][]Cell{{
+		{Date: "2024-03-05", Label: "2 journeys on Tue 05 Mar 2024"},
+		{Date: "2024-03-06", Label: "No journeys on Wed 06 Mar 2024"},
+	}}}
+	markHeatmapAnnotations(&data, newAnnotationIndex([]annotations.Annotation{
+		{Date: mustDate("2024-03-05"), Tags: []string{"strike"}},
+	}))
+	if data.AnnotatedDays != 1 {
+		t.Errorf("AnnotatedDays = %d, want 1", data.AnnotatedDays)
+	}
+	if c := data.Weeks[0][0]; c.Annotation != "strike" || c.Label != "2 journeys on Tue 05 Mar 2024 – strike" {
+		t.Errorf("annotated cell = %q, %q, want strike", c.Annotation, c.Label)
+	}
+	if c := data.Weeks[0][1]; c.Annotation != "" {
+		t.Errorf("other cell annotation = %q, want none", c.Annotation)
+	}
+}
@@ -0,0 +1,121 @@
+package web
+
+import (
+	"math"
+	"slices"
+	"sort"
+)
+
+const (
+	// anomalyThreshold is the robust z-score above which a commute counts as
+	// unusually long. 3.5 is the cut-off recommended by Iglewicz and Hoaglin.
+	anomalyThreshold = 3.5
+	// anomalyMinSamples is the number of commutes a route needs before its
+	// typical duration is trusted.
+	anomalyMinSamples = 5
+	// refundDelayMinutes is the delay from which TfL considers service delay
+	// refunds.
+	refundDelayMinutes = 15
+)
+
+// CommuteAnomaly is a commute whose duration is unusually long compared with
+// the typical duration of the same route.
+type CommuteAnomaly struct {
+	Date           string  `json:"date"` // e.g. "2024-03-05"
+	Route          string  `json:"route"`
+	Start          string  `json:"start"`
+	Minutes        int     `json:"duration_minutes"`
+	TypicalMinutes float64 `json:"typical_minutes"`
+	DelayMinutes   float64 `json:"delay_minutes"`
+	Score          float64 `json:"score"`      // robust z-score
+	Refundable     bool    `json:"refundable"` // delayed by at least refundDelayMinutes
+	Label          string  `json:"-"`          // e.g. "Tue 05 Mar"
+	Duration       string  `json:"-"`
+	Typical        string  `json:"-"`
+	Delay          string  `json:"-"` // e.g. "+25m"
+}
+
+// detectAnomalies flags commutes whose duration has a robust z-score above
+// anomalyThreshold among commutes on the same route. The z-score uses the
+// median and the median absolute deviation so that the outliers being looked
+// for do not distort the baseline. Flagged points have Anomaly set; the
+// anomalies are returned most recent first.
+func detectAnomalies(points []CommutePoint) []CommuteAnomaly {
+	byRoute := make(map[string][]int) // route → indexes into points
+	for i, p := range points {
+		byRoute[p.Route] = append(byRoute[p.Route], i)
+	}
+
+	anomalies := []CommuteAnomaly{}
+	for route, idx := range byRoute {
+		if len(idx) < anomalyMinSamples {
+			continue
+		}
+		durations := make([]int, len(idx))
+		for i, pi := range idx {
+			durations[i] = points[pi].Minutes
+		}
+		median, spread := robustSpread(durations)
+		if spread == 0 {
+			continue
+		}
+
+		for _, pi := range idx {
+			p := &points[pi]
+			score := (float64(p.Minutes) - median) / spread
+			if score <= anomalyThreshold {
+				continue
+			}
+			p.Anomaly = true
+			delay := float64(p.Minutes) - median
+			anomalies = append(anomalies, CommuteAnomaly{
+				Date:           p.ISODate,
+				Route:          route,
+				Start:          p.Start,
+				Minutes:        p.Minutes,
+				TypicalMinutes: median,
+				DelayMinutes:   delay,
+				Score:          math.Round(score*10) / 10,
+				Refundable:     delay >= refundDelayMinutes,
+				Label:          p.Date,
+				Duration:       p.Duration,
+				Typical:        formatMinutes(median),
+				Delay:          "+" + formatMinutes(delay),
+			})
+		}
+	}
+
+	sort.Slice(anomalies, func(i, j int) bool {
+		if anomalies[i].Date != anomalies[j].Date {
+			return anomalies[i].Date > anomalies[j].Date
+		}
+		return anomalies[i].Start > anomalies[j].Start
+	})
+	return anomalies
+}
+
+// robustSpread returns the median of values and the scale used for robust
+// z-scores: the median absolute deviation divided by 0.6745, which makes it
+// comparable to a standard deviation for normally distributed data. When more
+// than half the values are identical the MAD is zero, so the mean absolute
+// deviation scaled by 1.2533 is used instead.
+func robustSpread(values []int) (median, spread float64) {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	median = percentile(sorted, 50)
+
+	deviations := make([]int, len(sorted))
+	var sumDev float64
+	for i, v := range sorted {
+		d := math.Abs(float64(v) - median)
+		sumDev += d
+		// Durations are whole minutes and the median is a whole or half
+		// minute, so doubling keeps the deviations exact as integers.
+		deviations[i] = int(math.Round(d * 2))
+	}
+	slices.Sort(deviations)
+	if mad := percentile(deviations, 50) / 2; mad > 0 {
+		return median, mad / 0.6745
+	}
+	return median, sumDev / float64(len(sorted)) * 1.2533
+}
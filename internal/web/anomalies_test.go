@@ -0,0 +1,103 @@
+package web
+
+import (
+	"testing"
+
+	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+)
+
+func routePoints(route string, durations ...int) []CommutePoint {
+	points := trendPoints(durations...)
+	for i := range points {
+		points[i].Route = route
+		points[i].Duration = formatDuration(points[i].Minutes)
+	}
+	return points
+}
+
+func TestDetectAnomalies(t *testing.T) {
+	points := routePoints("Clapham Common to Bank", 40, 42, 38, 41, 39, 40, 75)
+
+	anomalies := detectAnomalies(points)
+	if len(anomalies) != 1 {
+		t.Fatalf("expected 1 anomaly, got %d: %+v", len(anomalies), anomalies)
+	}
+	a := anomalies[0]
+	if a.Minutes != 75 || a.TypicalMinutes != 40 || a.Delay != "+35m" || !a.Refundable {
+		t.Errorf("unexpected anomaly %+v", a)
+	}
+	for i, p := range points {
+		if p.Anomaly != (i == 6) {
+			t.Errorf("point %d: Anomaly = %v", i, p.Anomaly)
+		}
+	}
+}
+
+func TestDetectAnomalies_PerRoute(t *testing.T) {
+	// 70 minutes is normal for the long route but not for the short one.
+	points := append(
+		routePoints("Clapham Common to Bank", 30, 31, 29, 30, 32, 30),
+		routePoints("Brixton to Stratford", 68, 70, 72, 69, 71, 70)...,
+	)
+	points[5].Minutes = 70
+
+	anomalies := detectAnomalies(points)
+	if len(anomalies) != 1 || anomalies[0].Route != "Clapham Common to Bank" {
+		t.Errorf("anomalies = %+v, want one on Clapham Common to Bank", anomalies)
+	}
+}
+
+func TestDetectAnomalies_IdenticalDurations(t *testing.T) {
+	// The MAD is zero when most durations are equal; the mean absolute
+	// deviation fallback still flags the outlier.
+	points := routePoints("A to B", 40, 40, 40, 40, 90)
+	if got := detectAnomalies(points); len(got) != 1 {
+		t.Errorf("expected 1 anomaly, got %d", len(got))
+	}
+	if got := detectAnomalies(routePoints("A to B", 40, 40, 40, 40, 40)); len(got) != 0 {
+		t.Errorf("expected no anomalies for identical durations, got %d", len(got))
+	}
+}
+
+func TestDetectAnomalies_TooFewSamples(t *testing.T) {
+	points := routePoints("A to B", 30, 30, 31, 120)
+	if got := detectAnomalies(points); len(got) != 0 {
+		t.Errorf("expected no anomalies with %d samples, got %d", len(points), len(got))
+	}
+}
+
+func TestDetectAnomalies_NotRefundable(t *testing.T) {
+	points := routePoints("A to B", 20, 21, 20, 19, 20, 20, 30)
+	anomalies := detectAnomalies(points)
+	if len(anomalies) != 1 {
+		t.Fatalf("expected 1 anomaly, got %d", len(anomalies))
+	}
+	if anomalies[0].Refundable {
+		t.Errorf("a 10 minute delay should not be marked refundable")
+	}
+}
+
+func TestBuildCommuteData_Anomalies(t *testing.T) {
+	tue, wed, thu := commuteWeekDates()
+	var journeys []bq.CommuteJourney
+	for week := 0; week < 3; week++ {
+		for _, d := range []string{tue.AddDate(0, 0, -7*week).Format("2006-01-02"), wed.AddDate(0, 0, -7*week).Format("2006-01-02"), thu.AddDate(0, 0, -7*week).Format("2006-01-02")} {
+			journeys = append(journeys, bq.CommuteJourney{Date: d, StartTime: "08:00", EndTime: "08:40", JourneyAction: "Clapham Common to Bank"})
+		}
+	}
+	journeys[0].EndTime = "09:30"
+
+	data := buildCommuteData(journeys, nil, DateRange{})
+	if len(data.Anomalies) != 1 || data.Anomalies[0].Route != "Clapham Common to Bank" {
+		t.Fatalf("anomalies = %+v, want one", data.Anomalies)
+	}
+	flagged := 0
+	for _, p := range data.Commutes {
+		if p.Anomaly {
+			flagged++
+		}
+	}
+	if flagged != 1 {
+		t.Errorf("expected 1 flagged commute bar, got %d", flagged)
+	}
+}
@@ -0,0 +1,109 @@
+package web
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+)
+
+// CommuteRecord is a single commute in the commutes JSON response.
+type CommuteRecord struct {
+	Date            string `json:"date"` // e.g. "2024-03-05"
+	Route           string `json:"route"`
+	Start           string `json:"start"`
+	End             string `json:"end"`
+	DurationMinutes int    `json:"duration_minutes"`
+	Anomaly         bool   `json:"anomaly"`
+}
+
+// CommutesResponse is the JSON body served by /api/commutes.
+type CommutesResponse struct {
+	Card      string           `json:"card"`
+	Days      *int             `json:"days,omitempty"` // preset range; 0 = all available
+	From      string           `json:"from,omitempty"` // explicit range, e.g. "2024-03-01"
+	To        string           `json:"to,omitempty"`
+	Tag       string           `json:"tag,omitempty"` // commutes also carrying this tag
+	Commutes  []CommuteRecord  `json:"commutes"`
+	Durations DurationStats    `json:"durations"`
+	Anomalies []CommuteAnomaly `json:"anomalies"`
+}
+
+// handleCommutesAPI serves the commute data shown on the commutes page as
+// JSON. It accepts the same "card", "days" and "tag" parameters as the
+// page.
+func (h *Handler) handleCommutesAPI(w http.ResponseWriter, r *http.Request) {
+	sel, cerr := h.resolveCards(w, r, "commutes", false)
+	if cerr != nil {
+		writeJSONError(w, cerr.msg, cerr.status)
+		return
+	}
+
+	ctx := bq.WithPage(r.Context(), "api")
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	rng, err := parseDateRange(r.URL.Query(), today)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	journeys, err := sel.Card.Client.CommuteJourneys(ctx, h.tagged(sel.Notes, sel.Tag, h.commuteFilter(sel.Notes, rng, today)))
+	if err != nil {
+		slog.Error("querying bigquery for commutes", "card", sel.Card.ID, "error", err)
+		writeJSONError(w, "failed to load commute data", http.StatusInternalServerError)
+		return
+	}
+
+	data := buildCommuteData(withoutDaysOff(journeys, h.opts.Calendar), nil, rng)
+	resp := buildCommutesResponse(sel.Card.ID, rng, data)
+	resp.Tag = sel.Tag
+	writeJSON(w, resp)
+}
+
+// buildCommutesResponse converts commute chart data into its JSON form.
+func buildCommutesResponse(cardID string, rng DateRange, data CommuteData) CommutesResponse {
+	records := make([]CommuteRecord, 0, len(data.Commutes))
+	for _, p := range data.Commutes {
+		records = append(records, CommuteRecord{
+			Date:            p.ISODate,
+			Route:           p.Route,
+			Start:           p.Start,
+			End:             p.End,
+			DurationMinutes: p.Minutes,
+			Anomaly:         p.Anomaly,
+		})
+	}
+	resp := CommutesResponse{
+		Card:      cardID,
+		Commutes:  records,
+		Durations: data.Durations,
+		Anomalies: data.Anomalies,
+	}
+	if rng.Custom() {
+		form := rng.Form()
+		resp.From, resp.To = form.From, form.To
+	} else {
+		days := rng.Days
+		resp.Days = &days
+	}
+	return resp
+}
+
+// writeJSON writes v as an indented JSON response.
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		slog.Error("encoding json response", "error", err)
+	}
+}
+
+// writeJSONError writes an error response with a JSON body of the form
+// {"error": msg}.
+func writeJSONError(w http.ResponseWriter, msg string, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
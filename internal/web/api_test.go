@@ -0,0 +1,59 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+)
+
+func TestBuildCommutesResponse(t *testing.T) {
+	tue, _, _ := commuteWeekDates()
+	journeys := []bq.CommuteJourney{
+		{Date: tue.Format("02-Jan-06"), StartTime: "08:00", EndTime: "08:45"},
+	}
+	resp := buildCommutesResponse("alice", DateRange{Days: 30}, buildCommuteData(journeys, nil, DateRange{}))
+
+	if len(resp.Commutes) != 1 {
+		t.Fatalf("expected 1 commute, got %d", len(resp.Commutes))
+	}
+	c := resp.Commutes[0]
+	if c.Date != tue.Format("2006-01-02") || c.DurationMinutes != 45 {
+		t.Errorf("commute = %+v, want ISO date and 45 minutes", c)
+	}
+
+	b, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatalf("marshalling response: %v", err)
+	}
+	out := string(b)
+	for _, key := range []string{`"p95_minutes"`, `"histogram"`, `"weekdays"`, `"duration_minutes":45`} {
+		if !strings.Contains(out, key) {
+			t.Errorf("JSON missing %s: %s", key, out)
+		}
+	}
+	// Template-only fields are not exposed.
+	if strings.Contains(out, "SVGWidth") || strings.Contains(out, `"P50"`) {
+		t.Errorf("JSON contains template fields: %s", out)
+	}
+}
+
+func TestCommutesAPI_UnknownCardIsJSON(t *testing.T) {
+	h := &Handler{cards: testCards()}
+	rec := httptest.NewRecorder()
+	h.handleCommutesAPI(rec, httptest.NewRequest(http.MethodGet, "/api/commutes?card=nope", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body["error"] != "unknown card" {
+		t.Errorf("body = %q (%v), want {\"error\": \"unknown card\"}", rec.Body.String(), err)
+	}
+}
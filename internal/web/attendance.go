@@ -0,0 +1,357 @@
+package web
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+	"github.com/its-the-vibe/pearl/internal/calendar"
+)
+
+// attendanceDefaultDays is the preset date range of the attendance page when
+// none is requested; a few months shows enough weeks to spot a pattern.
+const attendanceDefaultDays = 90
+
+// AttendanceOptions configures the office attendance tracker.
+type AttendanceOptions struct {
+	WorkStations  []string                      // a journey to or from any of these marks an office day
+	WeeklyTarget  int                           // 0 = no weekly target
+	MonthlyTarget int                           // 0 = no monthly target
+	Overrides     map[string]AttendanceOverride // keyed by date, e.g. "2024-03-05"
+}
+
+// AttendanceOverride manually records whether a day was spent in the office,
+// such as a day we drove or cycled in.
+type AttendanceOverride struct {
+	Office bool
+	Note   string
+}
+
+// AttendanceDay is a single day in the weekly attendance grid.
+type AttendanceDay struct {
+	Label    string // e.g. "Tue 05 Mar" – used in tooltip
+	Office   bool
+	Override bool   // set manually rather than inferred from journeys
+	Note     string // override note; empty when not set
+	DayOff   string // calendar.KindBankHoliday or calendar.KindLeave; empty on other days
+	Outside  bool   // outside the selected range or in the future
+}
+
+// AttendanceWeek summarises the office days of a week starting on Monday.
+type AttendanceWeek struct {
+	Start       string          // e.g. "2024-03-04"
+	Label       string          // e.g. "04 Mar 2024"
+	Days        []AttendanceDay // Monday to Sunday
+	OfficeDays  int
+	DaysOff     int  // bank holidays and leave on weekdays
+	Target      int  // the weekly target less DaysOff
+	Complete    bool // the whole week is within the range and has ended
+	UnderTarget bool // complete and below a non-zero target
+}
+
+// AttendanceMonth summarises the office days of a calendar month.
+type AttendanceMonth struct {
+	Start       string // e.g. "2024-03-01"
+	Label       string // e.g. "Mar 2024"
+	OfficeDays  int
+	DaysOff     int
+	Target      int
+	Complete    bool
+	UnderTarget bool
+}
+
+// AttendanceData is passed to the attendance template.
+type AttendanceData struct {
+	Configured       bool   // work stations or overrides have been configured
+	WorkStations     string // e.g. "Bank, Liverpool Street"
+	WeeklyTarget     int
+	MonthlyTarget    int
+	OfficeDays       int
+	WeeksCounted     int               // complete weeks compared with the weekly target
+	WeeksMet         int               // complete weeks meeting the weekly target
+	WeeklyAverage    string            // office days per complete week, e.g. "2.6"
+	Weeks            []AttendanceWeek  // most recent first
+	Months           []AttendanceMonth // most recent first
+	DateRangeOptions []DateRangeOption
+	Range            DateRange
+	RangeForm        DateRangeForm
+	Nav              Nav
+}
+
+func (h *Handler) handleAttendance(w http.ResponseWriter, r *http.Request) {
+	sel, ok := h.selectCards(w, r, "attendance", false)
+	if !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	rng, err := parseAttendanceRange(q, today)
+	if err != nil {
+		data := buildAttendanceData(nil, h.opts.Attendance, h.opts.Calendar, DateRange{Days: customRangeDays}, today)
+		data.RangeForm = DateRangeForm{From: q.Get("from"), To: q.Get("to"), Error: err.Error()}
+		data.Nav = sel.Nav
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusBadRequest)
+		if err := h.tmpl.ExecuteTemplate(w, "attendance.html", data); err != nil {
+			slog.Error("rendering attendance template", "error", err)
+		}
+		return
+	}
+
+	ctx := bq.WithPage(r.Context(), "attendance")
+	journeys, err := sel.Card.Client.Journeys(ctx, h.tagged(sel.Notes, sel.Tag, rng.journeyFilter(today)))
+	if err != nil {
+		slog.Error("querying bigquery for attendance", "card", sel.Card.ID, "error", err)
+		http.Error(w, "failed to load attendance data", http.StatusInternalServerError)
+		return
+	}
+
+	data := buildAttendanceData(journeys, h.opts.Attendance, h.opts.Calendar, rng, today)
+	data.RangeForm = rng.Form()
+	data.Nav = sel.Nav
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.tmpl.ExecuteTemplate(w, "attendance.html", data); err != nil {
+		slog.Error("rendering attendance template", "error", err)
+	}
+}
+
+// handleAttendanceCSV exports the weekly and monthly attendance summary shown
+// on the attendance page as CSV. It accepts the same parameters as the page.
+func (h *Handler) handleAttendanceCSV(w http.ResponseWriter, r *http.Request) {
+	sel, ok := h.selectCards(w, r, "attendance", false)
+	if !ok {
+		return
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	rng, err := parseAttendanceRange(r.URL.Query(), today)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := bq.WithPage(r.Context(), "attendance")
+	journeys, err := sel.Card.Client.Journeys(ctx, h.tagged(sel.Notes, sel.Tag, rng.journeyFilter(today)))
+	if err != nil {
+		slog.Error("querying bigquery for attendance", "card", sel.Card.ID, "error", err)
+		http.Error(w, "failed to load attendance data", http.StatusInternalServerError)
+		return
+	}
+
+	data := buildAttendanceData(journeys, h.opts.Attendance, h.opts.Calendar, rng, today)
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="attendance-%s.csv"`, sel.Card.ID))
+	if err := csv.NewWriter(w).WriteAll(attendanceRecords(data)); err != nil {
+		slog.Error("writing attendance csv", "error", err)
+	}
+}
+
+// parseAttendanceRange parses the date range of the attendance page, which
+// defaults to the last attendanceDefaultDays days rather than the usual 30.
+func parseAttendanceRange(q url.Values, today time.Time) (DateRange, error) {
+	return parseDateRangeDefault(q, today, attendanceDefaultDays)
+}
+
+// journeyStations returns the stations a journey action starts and ends at,
+// such as "Clapham Common" and "Bank" for "Clapham Common to Bank". Bracketed
+// annotations like "[No touch-out]" or "(Northern line)" are dropped. Actions
+// without " to ", such as "Entered Bank", return the last station only.
+func journeyStations(action string) (from, to string) {
+	clean := func(s string) string {
+		for _, open := range []string{"[", "("} {
+			if i := strings.Index(s, open); i >= 0 {
+				s = s[:i]
+			}
+		}
+		return strings.TrimSpace(s)
+	}
+	if f, t, ok := strings.Cut(action, " to "); ok {
+		return clean(f), clean(t)
+	}
+	for _, prefix := range []string{"Entered ", "Exited "} {
+		if rest, ok := strings.CutPrefix(action, prefix); ok {
+			return "", clean(rest)
+		}
+	}
+	return "", ""
+}
+
+// isWorkJourney reports whether a journey starts or ends at one of stations,
+// ignoring case.
+func isWorkJourney(action string, stations []string) bool {
+	from, to := journeyStations(action)
+	for _, s := range stations {
+		if (from != "" && strings.EqualFold(from, s)) || (to != "" && strings.EqualFold(to, s)) {
+			return true
+		}
+	}
+	return false
+}
+
+// buildAttendanceData infers office days from journeys to or from the work
+// stations in opts, applies the manual overrides, and summarises the days per
+// week and per month within rng. Only weeks and months that lie wholly within
+// the range and have ended are compared with the targets. Journeys on bank
+// holidays and leave days in cal are ignored, and each such weekday lowers the
+// target of its week and month by one.
+func buildAttendanceData(journeys []bq.Journey, opts AttendanceOptions, cal *calendar.Calendar, rng DateRange, today time.Time) AttendanceData {
+	data := AttendanceData{
+		Configured:       len(opts.WorkStations) > 0 || len(opts.Overrides) > 0,
+		WorkStations:     strings.Join(opts.WorkStations, ", "),
+		WeeklyTarget:     opts.WeeklyTarget,
+		MonthlyTarget:    opts.MonthlyTarget,
+		DateRangeOptions: buildDateRangeOptions(rng.Days),
+		Range:            rng,
+	}
+
+	from, to := rng.bounds(today)
+	if to.IsZero() || to.After(today) {
+		to = today
+	}
+	inRange := func(t time.Time) bool {
+		return (from.IsZero() || !t.Before(from)) && !t.After(to)
+	}
+
+	days := make(map[string]AttendanceDay)
+	var earliest time.Time
+	mark := func(t time.Time, day AttendanceDay) {
+		days[t.Format("2006-01-02")] = day
+		if earliest.IsZero() || t.Before(earliest) {
+			earliest = t
+		}
+	}
+	for _, j := range journeys {
+		t, err := parseJourneyDate(j.Date)
+		if err != nil || !inRange(t) || !isWorkJourney(j.JourneyAction, opts.WorkStations) {
+			continue
+		}
+		if _, off := cal.Lookup(t); off {
+			continue
+		}
+		mark(t, AttendanceDay{Office: true})
+	}
+	for date, o := range opts.Overrides {
+		t, err := time.Parse("2006-01-02", date)
+		if err != nil || !inRange(t) {
+			continue
+		}
+		mark(t, AttendanceDay{Office: o.Office, Override: true, Note: o.Note})
+	}
+
+	// weekdayOff reports whether d is a bank holiday or leave day on which
+	// we would otherwise be expected in the office.
+	weekdayOff := func(d time.Time) bool {
+		_, off := cal.Lookup(d)
+		return off && d.Weekday() != time.Saturday && d.Weekday() != time.Sunday
+	}
+
+	if from.IsZero() {
+		// "All available" starts at the first recorded office day.
+		if earliest.IsZero() {
+			return data
+		}
+		from = earliest
+	}
+
+	var sumWeekly int
+	monday := from.AddDate(0, 0, -((int(from.Weekday()) + 6) % 7))
+	for ; !monday.After(to); monday = monday.AddDate(0, 0, 7) {
+		sunday := monday.AddDate(0, 0, 6)
+		week := AttendanceWeek{
+			Start:    monday.Format("2006-01-02"),
+			Label:    monday.Format("02 Jan 2006"),
+			Target:   opts.WeeklyTarget,
+			Complete: !monday.Before(from) && !sunday.After(to) && sunday.Before(today),
+		}
+		for d := monday; !d.After(sunday); d = d.AddDate(0, 0, 1) {
+			day := days[d.Format("2006-01-02")]
+			day.Label = d.Format("Mon 02 Jan")
+			day.Outside = d.Before(from) || d.After(to)
+			if off, ok := cal.Lookup(d); ok {
+				day.DayOff = off.Kind
+				day.Label += " – " + dayOffName(off)
+			}
+			if day.Office && !day.Outside {
+				week.OfficeDays++
+			}
+			if weekdayOff(d) && !day.Outside {
+				week.DaysOff++
+			}
+			week.Days = append(week.Days, day)
+		}
+		week.Target = max(0, week.Target-week.DaysOff)
+		data.OfficeDays += week.OfficeDays
+		if week.Complete {
+			week.UnderTarget = week.OfficeDays < week.Target
+			data.WeeksCounted++
+			sumWeekly += week.OfficeDays
+			if !week.UnderTarget {
+				data.WeeksMet++
+			}
+		}
+		data.Weeks = append(data.Weeks, week)
+	}
+	slices.Reverse(data.Weeks)
+	if data.WeeksCounted > 0 {
+		data.WeeklyAverage = fmt.Sprintf("%.1f", float64(sumWeekly)/float64(data.WeeksCounted))
+	}
+
+	first := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for ; !first.After(to); first = first.AddDate(0, 1, 0) {
+		last := first.AddDate(0, 1, -1)
+		month := AttendanceMonth{
+			Start:    first.Format("2006-01-02"),
+			Label:    first.Format("Jan 2006"),
+			Target:   opts.MonthlyTarget,
+			Complete: !first.Before(from) && !last.After(to) && last.Before(today),
+		}
+		for d := first; !d.After(last); d = d.AddDate(0, 0, 1) {
+			if !inRange(d) || d.Before(from) {
+				continue
+			}
+			if days[d.Format("2006-01-02")].Office {
+				month.OfficeDays++
+			}
+			if weekdayOff(d) {
+				month.DaysOff++
+			}
+		}
+		month.Target = max(0, month.Target-month.DaysOff)
+		month.UnderTarget = month.Complete && month.OfficeDays < month.Target
+		data.Months = append(data.Months, month)
+	}
+	slices.Reverse(data.Months)
+	return data
+}
+
+// attendanceRecords returns the weekly and monthly attendance summary as CSV
+// records, starting with a header row. met is empty for periods that are
+// incomplete or have no target.
+func attendanceRecords(data AttendanceData) [][]string {
+	records := [][]string{{"period", "start", "office_days", "target", "complete", "met"}}
+	add := func(period, start string, officeDays, target int, complete, under bool) {
+		met := ""
+		if complete && target > 0 {
+			met = strconv.FormatBool(!under)
+		}
+		records = append(records, []string{
+			period, start, strconv.Itoa(officeDays), strconv.Itoa(target), strconv.FormatBool(complete), met,
+		})
+	}
+	for _, w := range data.Weeks {
+		add("week", w.Start, w.OfficeDays, w.Target, w.Complete, w.UnderTarget)
+	}
+	for _, m := range data.Months {
+		add("month", m.Start, m.OfficeDays, m.Target, m.Complete, m.UnderTarget)
+	}
+	return records
+}
@@ -0,0 +1,215 @@
+package web
+
+import (
+	"net/url"
+	"testing"
+
+	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+	"github.com/its-the-vibe/pearl/internal/calendar"
+)
+
+func TestJourneyStations(t *testing.T) {
+	tests := []struct {
+		action   string
+		from, to string
+	}{
+		{"Clapham Common to Bank", "Clapham Common", "Bank"},
+		{"Bank [London Underground] to Clapham Common", "Bank", "Clapham Common"},
+		{"Clapham Common to [No touch-out]", "Clapham Common", ""},
+		{"Entered Bank", "", "Bank"},
+		{"Bus journey, route 88", "", ""},
+	}
+	for _, tt := range tests {
+		from, to := journeyStations(tt.action)
+		if from != tt.from || to != tt.to {
+			t.Errorf("journeyStations(%q) = %q, %q, want %q, %q", tt.action, from, to, tt.from, tt.to)
+		}
+	}
+}
+
+func TestIsWorkJourney(t *testing.T) {
+	stations := []string{"Bank", "Liverpool Street"}
+	tests := []struct {
+		action string
+		want   bool
+	}{
+		{"Clapham Common to Bank", true},
+		{"liverpool street to Stratford", true},
+		{"Clapham Common to Bankside", false},
+		{"Bus journey, route 88", false},
+	}
+	for _, tt := range tests {
+		if got := isWorkJourney(tt.action, stations); got != tt.want {
+			t.Errorf("isWorkJourney(%q) = %v, want %v", tt.action, got, tt.want)
+		}
+	}
+}
+
+func TestParseAttendanceRange(t *testing.T) {
+	today := mustDate("2024-03-18")
+	rng, err := parseAttendanceRange(url.Values{}, today)
+	if err != nil || rng.Days != attendanceDefaultDays {
+		t.Errorf("default range = %+v, %v, want %d days", rng, err, attendanceDefaultDays)
+	}
+	rng, err = parseAttendanceRange(url.Values{"days": {"30"}}, today)
+	if err != nil || rng.Days != 30 {
+		t.Errorf("days=30 range = %+v, %v", rng, err)
+	}
+	if _, err := parseAttendanceRange(url.Values{"days": {"12"}}, today); err == nil {
+		t.Error("days=12: expected an error")
+	}
+}
+
+func TestBuildAttendanceData(t *testing.T) {
+	journeys := []bq.Journey{
+		{Date: "2024-03-04", JourneyAction: "Clapham Common to Bank"},
+		{Date: "2024-03-04", JourneyAction: "Bank to Clapham Common"},
+		{Date: "2024-03-05", JourneyAction: "Clapham Common to Bank"},
+		{Date: "2024-03-06", JourneyAction: "Clapham Common to Bank"},
+		{Date: "2024-03-09", JourneyAction: "Clapham Common to Oxford Circus"},
+		{Date: "2024-03-12", JourneyAction: "Clapham Common to Bank"},
+		{Date: "2024-03-19", JourneyAction: "Clapham Common to Bank"},
+	}
+	opts := AttendanceOptions{
+		WorkStations:  []string{"Bank"},
+		WeeklyTarget:  3,
+		MonthlyTarget: 8,
+		Overrides: map[string]AttendanceOverride{
+			"2024-03-06": {Office: false, Note: "client site"},
+			"2024-03-07": {Office: true, Note: "cycled"},
+		},
+	}
+	rng := DateRange{Days: customRangeDays, From: mustDate("2024-03-04"), To: mustDate("2024-03-31")}
+	data := buildAttendanceData(journeys, opts, nil, rng, mustDate("2024-03-20"))
+
+	if data.OfficeDays != 5 {
+		t.Errorf("OfficeDays = %d, want 5", data.OfficeDays)
+	}
+	// Weeks commencing 4, 11 and 18 Mar, most recent first.
+	if len(data.Weeks) != 3 {
+		t.Fatalf("expected 3 weeks, got %d", len(data.Weeks))
+	}
+	first := data.Weeks[2]
+	if first.Start != "2024-03-04" || first.OfficeDays != 3 || !first.Complete || first.UnderTarget {
+		t.Errorf("week of 4 Mar = %+v, want 3 office days meeting the target", first)
+	}
+	if d := first.Days[2]; d.Office || !d.Override || d.Note != "client site" {
+		t.Errorf("6 Mar = %+v, want an override out of the office", d)
+	}
+	if d := first.Days[3]; !d.Office || !d.Override {
+		t.Errorf("7 Mar = %+v, want an override in the office", d)
+	}
+	if w := data.Weeks[1]; w.OfficeDays != 1 || !w.UnderTarget {
+		t.Errorf("week of 11 Mar = %+v, want 1 office day under target", w)
+	}
+	// The current week is not yet complete, so it is not flagged.
+	if w := data.Weeks[0]; w.Complete || w.UnderTarget {
+		t.Errorf("week of 18 Mar = %+v, want incomplete", w)
+	}
+	if data.WeeksCounted != 2 || data.WeeksMet != 1 || data.WeeklyAverage != "2.0" {
+		t.Errorf("weeks counted/met/average = %d/%d/%s, want 2/1/2.0", data.WeeksCounted, data.WeeksMet, data.WeeklyAverage)
+	}
+
+	if len(data.Months) != 1 {
+		t.Fatalf("expected 1 month, got %d", len(data.Months))
+	}
+	if m := data.Months[0]; m.OfficeDays != 5 || m.Complete || m.UnderTarget {
+		t.Errorf("March = %+v, want 5 office days, incomplete", m)
+	}
+}
+
+func TestBuildAttendanceDataAllAvailable(t *testing.T) {
+	journeys := []bq.Journey{
+		{Date: "2024-02-28", JourneyAction: "Clapham Common to Bank"},
+		{Date: "2024-03-01", JourneyAction: "Clapham Common to Bank"},
+	}
+	opts := AttendanceOptions{WorkStations: []string{"Bank"}, MonthlyTarget: 2}
+	data := buildAttendanceData(journeys, opts, nil, DateRange{Days: 0}, mustDate("2024-04-02"))
+
+	// The range starts at the first office day, so February is partial.
+	if len(data.Months) != 3 {
+		t.Fatalf("expected 3 months, got %d", len(data.Months))
+	}
+	if feb := data.Months[2]; feb.Label != "Feb 2024" || feb.Complete {
+		t.Errorf("February = %+v, want incomplete", feb)
+	}
+	if mar := data.Months[1]; !mar.Complete || !mar.UnderTarget {
+		t.Errorf("March = %+v, want complete and under target", mar)
+	}
+
+	if got := buildAttendanceData(nil, opts, nil, DateRange{Days: 0}, mustDate("2024-04-02")); len(got.Weeks) != 0 {
+		t.Errorf("expected no weeks without office days, got %d", len(got.Weeks))
+	}
+}
+
+func TestBuildAttendanceDataMissingTouchOut(t *testing.T) {
+	// The only journey of the day was never touched out, so it has no end time.
+	journeys := []bq.Journey{
+		{Date: "05-Mar-24", StartTime: "08:10", JourneyAction: "Clapham Common to Bank [No touch-out]", Charge: 8.6},
+	}
+	opts := AttendanceOptions{WorkStations: []string{"Bank"}, WeeklyTarget: 1}
+	rng := DateRange{Days: customRangeDays, From: mustDate("2024-03-04"), To: mustDate("2024-03-10")}
+	data := buildAttendanceData(journeys, opts, nil, rng, mustDate("2024-03-20"))
+
+	if data.OfficeDays != 1 || len(data.Weeks) != 1 || data.Weeks[0].UnderTarget {
+		t.Errorf("OfficeDays = %d, weeks = %+v; want the day counted and the target met", data.OfficeDays, data.Weeks)
+	}
+}
+
+func TestBuildAttendanceDataDaysOff(t *testing.T) {
+	journeys := []bq.Journey{
+		{Date: "2024-03-26", JourneyAction: "Clapham Common to Bank"},
+		{Date: "2024-03-27", JourneyAction: "Clapham Common to Bank"},
+		{Date: "2024-04-01", JourneyAction: "Clapham Common to Bank"}, // travelled on Easter Monday
+	}
+	cal := calendar.New([]calendar.DayOff{
+		{Date: mustDate("2024-03-29"), Kind: calendar.KindBankHoliday, Name: "Good Friday"},
+		{Date: mustDate("2024-04-01"), Kind: calendar.KindBankHoliday, Name: "Easter Monday"},
+	})
+	opts := AttendanceOptions{WorkStations: []string{"Bank"}, WeeklyTarget: 3}
+	rng := DateRange{Days: customRangeDays, From: mustDate("2024-03-25"), To: mustDate("2024-04-07")}
+	data := buildAttendanceData(journeys, opts, cal, rng, mustDate("2024-04-10"))
+
+	if len(data.Weeks) != 2 {
+		t.Fatalf("expected 2 weeks, got %d", len(data.Weeks))
+	}
+	// Good Friday lowers the target to 2, which two office days meet.
+	if w := data.Weeks[1]; w.DaysOff != 1 || w.Target != 2 || w.UnderTarget {
+		t.Errorf("week of 25 Mar = %+v, want target 2 met", w)
+	}
+	if d := data.Weeks[1].Days[4]; d.DayOff != calendar.KindBankHoliday {
+		t.Errorf("29 Mar = %+v, want a bank holiday", d)
+	}
+	// The journey on Easter Monday does not count as an office day.
+	if w := data.Weeks[0]; w.OfficeDays != 0 || w.Target != 2 || !w.UnderTarget {
+		t.Errorf("week of 1 Apr = %+v, want 0 office days against a target of 2", w)
+	}
+}
+
+func TestAttendanceRecords(t *testing.T) {
+	data := AttendanceData{
+		Weeks: []AttendanceWeek{
+			{Start: "2024-03-11", OfficeDays: 1, Target: 3, Complete: true, UnderTarget: true},
+			{Start: "2024-03-18", OfficeDays: 2, Target: 3},
+		},
+		Months: []AttendanceMonth{{Start: "2024-03-01", OfficeDays: 3}},
+	}
+	records := attendanceRecords(data)
+	want := [][]string{
+		{"period", "start", "office_days", "target", "complete", "met"},
+		{"week", "2024-03-11", "1", "3", "true", "false"},
+		{"week", "2024-03-18", "2", "3", "false", ""},
+		{"month", "2024-03-01", "3", "0", "false", ""},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("expected %d records, got %d", len(want), len(records))
+	}
+	for i := range want {
+		for j := range want[i] {
+			if records[i][j] != want[i][j] {
+				t.Errorf("records[%d] = %v, want %v", i, records[i], want[i])
+				break
+			}
+		}
+	}
+}
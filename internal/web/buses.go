@@ -0,0 +1,207 @@
+package web
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+)
+
+// hopperMinutes is how long after touching in on a paid bus journey further
+// bus journeys are free under the Hopper fare.
+const hopperMinutes = 60
+
+// BusRoute summarises the journeys on one bus route.
+type BusRoute struct {
+	Route    string // e.g. "88"
+	Journeys int
+	PerWeek  string // e.g. "2.5"
+	Spend    string
+	Hoppers  int    // journeys charged nothing under the Hopper fare
+	LastUsed string // e.g. "Tue 05 Mar 2024"
+	Percent  int    // relative to the most used route, for bar widths
+}
+
+// BusData is passed to the buses template.
+type BusData struct {
+	Journeys         int
+	Spend            string
+	Hoppers          int
+	HopperSavings    string // Hoppers at the most common fare paid; empty without hoppers
+	Routes           []BusRoute
+	DateRangeOptions []DateRangeOption
+	Range            DateRange
+	RangeForm        DateRangeForm
+	Nav              Nav
+}
+
+func (h *Handler) handleBuses(w http.ResponseWriter, r *http.Request) {
+	sel, ok := h.selectCards(w, r, "buses", false)
+	if !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	rng, err := parseDateRange(q, today)
+	if err != nil {
+		data := buildBusData(nil, DateRange{Days: customRangeDays}, today)
+		data.RangeForm = DateRangeForm{From: q.Get("from"), To: q.Get("to"), Error: err.Error()}
+		data.Nav = sel.Nav
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusBadRequest)
+		if err := h.tmpl.ExecuteTemplate(w, "buses.html", data); err != nil {
+			slog.Error("rendering buses template", "error", err)
+		}
+		return
+	}
+
+	ctx := bq.WithPage(r.Context(), "buses")
+	journeys, err := sel.Card.Client.BusJourneys(ctx, h.tagged(sel.Notes, sel.Tag, rng.journeyFilter(today)))
+	if err != nil {
+		slog.Error("querying bigquery for bus journeys", "card", sel.Card.ID, "error", err)
+		http.Error(w, "failed to load bus journeys", http.StatusInternalServerError)
+		return
+	}
+
+	data := buildBusData(journeys, rng, today)
+	data.RangeForm = rng.Form()
+	data.Nav = sel.Nav
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.tmpl.ExecuteTemplate(w, "buses.html", data); err != nil {
+		slog.Error("rendering buses template", "error", err)
+	}
+}
+
+// busRoute extracts the route from a bus journey action such as
+// "Bus journey, route 88". ok is false for other journeys.
+func busRoute(action string) (route string, ok bool) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(action), "Bus journey")
+	if !ok {
+		return "", false
+	}
+	rest = strings.TrimSpace(strings.TrimPrefix(rest, ","))
+	rest = strings.TrimSpace(strings.TrimPrefix(rest, "route"))
+	if rest == "" {
+		return "unknown", true
+	}
+	return rest, true
+}
+
+// busHoppers reports which journeys were free under the Hopper fare: charged
+// nothing and started within hopperMinutes of the last paid bus journey on
+// the same day. journeys must be ordered by date and start time.
+func busHoppers(journeys []bq.BusJourney) []bool {
+	hoppers := make([]bool, len(journeys))
+	paidDate, paidStart := "", -1
+	for i, j := range journeys {
+		start, err := parseTimeToMinutes(j.StartTime)
+		if err != nil {
+			continue
+		}
+		switch {
+		case j.Charge > 0:
+			paidDate, paidStart = j.Date, start
+		case j.Date == paidDate && paidStart >= 0 && start >= paidStart && start-paidStart <= hopperMinutes:
+			hoppers[i] = true
+		}
+	}
+	return hoppers
+}
+
+// buildBusData summarises bus journeys by route. Journeys per week are
+// averaged over rng, or from the first bus journey when rng is unbounded.
+func buildBusData(journeys []bq.BusJourney, rng DateRange, today time.Time) BusData {
+	data := BusData{
+		Routes:           []BusRoute{},
+		DateRangeOptions: buildDateRangeOptions(rng.Days),
+		Range:            rng,
+	}
+
+	hoppers := busHoppers(journeys)
+	routes := make(map[string]*BusRoute)
+	spend := make(map[string]float64)
+	last := make(map[string]time.Time)
+	fares := make(map[float64]int)
+	var total float64
+	var first time.Time
+	for i, j := range journeys {
+		route, ok := busRoute(j.JourneyAction)
+		if !ok {
+			continue
+		}
+		t, err := parseJourneyDate(j.Date)
+		if err != nil {
+			continue
+		}
+		if first.IsZero() || t.Before(first) {
+			first = t
+		}
+		br, ok := routes[route]
+		if !ok {
+			br = &BusRoute{Route: route}
+			routes[route] = br
+		}
+		br.Journeys++
+		spend[route] += j.Charge
+		total += j.Charge
+		if t.After(last[route]) {
+			last[route] = t
+		}
+		if hoppers[i] {
+			br.Hoppers++
+			data.Hoppers++
+		}
+		if j.Charge > 0 {
+			fares[math.Round(j.Charge*100)/100]++
+		}
+		data.Journeys++
+	}
+	data.Spend = formatPounds(total)
+	if data.Journeys == 0 {
+		return data
+	}
+
+	if data.Hoppers > 0 && len(fares) > 0 {
+		var fare float64
+		for f, n := range fares {
+			if n > fares[fare] || (n == fares[fare] && f > fare) {
+				fare = f
+			}
+		}
+		data.HopperSavings = formatPounds(fare * float64(data.Hoppers))
+	}
+
+	from, to := rng.bounds(today)
+	if from.IsZero() {
+		from = first
+	}
+	if to.IsZero() || to.After(today) {
+		to = today
+	}
+	weeks := max(1, to.Sub(from).Hours()/24/7)
+
+	for route, br := range routes {
+		br.Spend = formatPounds(spend[route])
+		br.PerWeek = fmt.Sprintf("%.1f", float64(br.Journeys)/weeks)
+		br.LastUsed = last[route].Format("Mon 02 Jan 2006")
+		data.Routes = append(data.Routes, *br)
+	}
+	sort.Slice(data.Routes, func(i, j int) bool {
+		a, b := data.Routes[i], data.Routes[j]
+		if a.Journeys != b.Journeys {
+			return a.Journeys > b.Journeys
+		}
+		return a.Route < b.Route
+	})
+	for i := range data.Routes {
+		data.Routes[i].Percent = data.Routes[i].Journeys * 100 / data.Routes[0].Journeys
+	}
+	return data
+}
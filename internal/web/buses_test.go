@@ -0,0 +1,83 @@
+package web
+
+import (
+	"testing"
+
+	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+)
+
+func TestBusRoute(t *testing.T) {
+	tests := []struct {
+		action string
+		want   string
+		wantOK bool
+	}{
+		{"Bus journey, route 88", "88", true},
+		{"Bus journey, route N155", "N155", true},
+		{"Bus journey", "unknown", true},
+		{"Clapham Common to Bank", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := busRoute(tt.action)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("busRoute(%q) = %q, %v, want %q, %v", tt.action, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestBusHoppers(t *testing.T) {
+	journeys := []bq.BusJourney{
+		{Date: "2024-03-04", StartTime: "08:00", Charge: 1.75},
+		{Date: "2024-03-04", StartTime: "08:40"}, // hopper
+		{Date: "2024-03-04", StartTime: "09:00"}, // hopper: exactly an hour later
+		{Date: "2024-03-04", StartTime: "09:30"}, // too late, e.g. capped
+		{Date: "2024-03-05", StartTime: "00:10"}, // a new day
+		{Date: "2024-03-05", StartTime: "08:00", Charge: 1.75},
+		{Date: "2024-03-05", StartTime: "08:30", Charge: 1.75}, // paid, starts a new hour
+		{Date: "2024-03-05", StartTime: "09:20"},               // hopper from the second fare
+		{Date: "2024-03-05", StartTime: ""},                    // no start time
+	}
+	want := []bool{false, true, true, false, false, false, false, true, false}
+	got := busHoppers(journeys)
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("journey %d (%s %s): hopper = %v, want %v", i, journeys[i].Date, journeys[i].StartTime, got[i], want[i])
+		}
+	}
+}
+
+func TestBuildBusData(t *testing.T) {
+	today := mustDate("2024-03-31")
+	data := buildBusData([]bq.BusJourney{
+		{Date: "2024-03-04", StartTime: "08:00", JourneyAction: "Bus journey, route 88", Charge: 1.75},
+		{Date: "2024-03-04", StartTime: "08:30", JourneyAction: "Bus journey, route 155"},
+		{Date: "2024-03-05", StartTime: "08:00", JourneyAction: "Bus journey, route 88", Charge: 1.75},
+		{Date: "2024-03-12", StartTime: "18:00", JourneyAction: "Bus journey, route 88", Charge: 1.65},
+		{Date: "2024-03-12", StartTime: "18:00", JourneyAction: "Clapham Common to Bank", Charge: 2.80},
+	}, DateRange{Days: 28}, today)
+
+	if data.Journeys != 4 {
+		t.Errorf("Journeys = %d, want 4", data.Journeys)
+	}
+	if data.Spend != "£5.15" {
+		t.Errorf("Spend = %q, want %q", data.Spend, "£5.15")
+	}
+	if data.Hoppers != 1 || data.HopperSavings != "£1.75" {
+		t.Errorf("Hoppers = %d saving %q, want 1 saving £1.75", data.Hoppers, data.HopperSavings)
+	}
+	if len(data.Routes) != 2 {
+		t.Fatalf("Routes = %+v, want 88 and 155", data.Routes)
+	}
+	r88 := data.Routes[0]
+	if r88.Route != "88" || r88.Journeys != 3 || r88.Spend != "£5.15" || r88.PerWeek != "0.8" || r88.LastUsed != "Tue 12 Mar 2024" || r88.Percent != 100 {
+		t.Errorf("Routes[0] = %+v, want route 88 with 3 journeys", r88)
+	}
+	if r155 := data.Routes[1]; r155.Route != "155" || r155.Hoppers != 1 || r155.Percent != 33 {
+		t.Errorf("Routes[1] = %+v, want route 155 with 1 hopper", r155)
+	}
+
+	empty := buildBusData(nil, DateRange{Days: 30}, today)
+	if empty.Journeys != 0 || len(empty.Routes) != 0 || empty.Spend != "£0.00" {
+		t.Errorf("empty = %+v", empty)
+	}
+}
@@ -0,0 +1,170 @@
+package web
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+	"github.com/its-the-vibe/pearl/internal/calendar"
+)
+
+// calendarWeekdays heads the columns of the month grid; weeks start on Monday.
+var calendarWeekdays = []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+
+// CalendarDay is a single day in the month grid.
+type CalendarDay struct {
+	Empty    bool   // padding before the first or after the last day of the month
+	Date     string // ISO date of the day, e.g. "2024-03-05", linking to its day page
+	Day      int    // day of the month
+	Journeys int
+	Spend    string // empty when nothing was charged
+	First    string // first tap-in, e.g. "07:52"; empty without one
+	Rating   string // e.g. "4"; empty without a rating
+	Level    int    // intensity, as on the heatmap
+	DayOff   string // calendar.KindBankHoliday or calendar.KindLeave; empty on other days
+	Label    string // tooltip, e.g. "Tue 05 Mar 2024: 4 journeys, £5.60"
+	Today    bool
+	Future   bool // after today, so without data yet
+}
+
+// CalendarData is passed to the calendar template.
+type CalendarData struct {
+	Month      string // e.g. "March 2024"
+	Weekdays   []string
+	Weeks      [][]CalendarDay
+	Journeys   int
+	ActiveDays int
+	Spend      string
+	PrevMonth  string // e.g. "2024-02"
+	NextMonth  string // empty for the current month
+	Nav        Nav
+}
+
+// handleCalendarRedirect sends /calendar to the calendar of the current month.
+func (h *Handler) handleCalendarRedirect(w http.ResponseWriter, r *http.Request) {
+	target := "/calendar/" + time.Now().UTC().Format("2006-01")
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+func (h *Handler) handleCalendar(w http.ResponseWriter, r *http.Request) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	month, err := time.Parse("2006-01", r.PathValue("month"))
+	if err != nil || month.After(today) {
+		http.NotFound(w, r)
+		return
+	}
+
+	sel, ok := h.selectCards(w, r, "calendar", false)
+	if !ok {
+		return
+	}
+
+	to := month.AddDate(0, 1, -1)
+	if to.After(today) {
+		to = today
+	}
+	ctx := bq.WithPage(r.Context(), "calendar")
+	filter := h.tagged(sel.Notes, sel.Tag, bq.JourneyFilter{From: month, To: to})
+	counts, err := sel.Card.Client.JourneyCountsByDay(ctx, filter)
+	if err != nil {
+		slog.Error("querying bigquery", "card", sel.Card.ID, "error", err)
+		http.Error(w, "failed to load journey data", http.StatusInternalServerError)
+		return
+	}
+	ratings, err := sel.Card.Client.Ratings(ctx, filter)
+	if err != nil {
+		// Ratings are optional; log and continue without them.
+		slog.Warn("querying bigquery for ratings", "card", sel.Card.ID, "error", err)
+	}
+
+	data := buildCalendarData(month, today, counts, ratings, h.opts.Calendar)
+	data.Nav = sel.Nav
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.tmpl.ExecuteTemplate(w, "calendar.html", data); err != nil {
+		slog.Error("rendering calendar template", "error", err)
+	}
+}
+
+// buildCalendarData lays out the month starting on month as a grid of weeks
+// from Monday to Sunday, with the journeys, spend and first tap-in of each day
+// from counts, the same day aggregates as the heatmap, and its rating. Days
+// off in cal are marked. today bounds the month in progress.
+func buildCalendarData(month, today time.Time, counts []bq.DayCount, ratings []bq.DailyRating, cal *calendar.Calendar) CalendarData {
+	data := CalendarData{
+		Month:     month.Format("January 2006"),
+		Weekdays:  calendarWeekdays,
+		PrevMonth: month.AddDate(0, -1, 0).Format("2006-01"),
+	}
+	next := month.AddDate(0, 1, 0)
+	if !next.After(today) {
+		data.NextMonth = next.Format("2006-01")
+	}
+
+	lookup, maxCount := dayLookup(counts)
+	rated := make(map[string]float64, len(ratings))
+	for _, r := range ratings {
+		rated[r.Date.Format("2006-01-02")] = r.Rating
+	}
+
+	var spend float64
+	var week []CalendarDay
+	for range (int(month.Weekday()) + 6) % 7 {
+		week = append(week, CalendarDay{Empty: true})
+	}
+	for day := month; day.Before(next); day = day.AddDate(0, 0, 1) {
+		key := day.Format("2006-01-02")
+		dc := lookup[key]
+		cd := CalendarDay{
+			Date:     key,
+			Day:      day.Day(),
+			Journeys: dc.Count,
+			First:    dc.FirstTapIn,
+			Level:    intensityLevel(dc.Count, maxCount),
+			Today:    day.Equal(today),
+			Future:   day.After(today),
+		}
+		label := fmt.Sprintf("%s: %d journeys", day.Format("Mon 02 Jan 2006"), dc.Count)
+		if dc.Spend > 0 {
+			cd.Spend = formatPounds(dc.Spend)
+			label += ", " + cd.Spend
+		}
+		if cd.First != "" {
+			label += ", first at " + cd.First
+		}
+		if r, ok := rated[key]; ok {
+			cd.Rating = strconv.FormatFloat(r, 'f', -1, 64)
+			label += ", rated " + cd.Rating
+		}
+		if off, ok := cal.Lookup(day); ok {
+			cd.DayOff = off.Kind
+			label += " – " + dayOffName(off)
+		}
+		cd.Label = label
+		if dc.Count > 0 {
+			data.Journeys += dc.Count
+			data.ActiveDays++
+		}
+		spend += dc.Spend
+
+		week = append(week, cd)
+		if len(week) == 7 {
+			data.Weeks = append(data.Weeks, week)
+			week = nil
+		}
+	}
+	if len(week) > 0 {
+		for len(week) < 7 {
+			week = append(week, CalendarDay{Empty: true})
+		}
+		data.Weeks = append(data.Weeks, week)
+	}
+	data.Spend = formatPounds(spend)
+	return data
+}
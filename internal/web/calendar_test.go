@@ -0,0 +1,69 @@
+package web
+
+import (
+	"testing"
+
+	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+	"github.com/its-the-vibe/pearl/internal/calendar"
+)
+
+func TestBuildCalendarData(t *testing.T) {
+	// March 2024 starts on a Friday and has 31 days.
+	month := mustDate("2024-03-01")
+	data := buildCalendarData(month, mustDate("2024-03-20"), []bq.DayCount{
+		{Date: mustDate("2024-03-05"), Count: 4, Spend: 5.6, FirstTapIn: "07:52"},
+		{Date: mustDate("2024-03-06"), Count: 1},
+	}, []bq.DailyRating{
+		{Date: mustDate("2024-03-05"), Rating: 4},
+	}, calendar.New([]calendar.DayOff{{Date: mustDate("2024-03-29"), Kind: calendar.KindBankHoliday, Name: "Good Friday"}}))
+
+	if data.Month != "March 2024" || data.PrevMonth != "2024-02" || data.NextMonth != "" {
+		t.Errorf("Month, PrevMonth, NextMonth = %q, %q, %q, want March 2024, 2024-02 and none", data.Month, data.PrevMonth, data.NextMonth)
+	}
+	if data.Journeys != 5 || data.ActiveDays != 2 || data.Spend != "£5.60" {
+		t.Errorf("Journeys, ActiveDays, Spend = %d, %d, %q, want 5, 2, £5.60", data.Journeys, data.ActiveDays, data.Spend)
+	}
+
+	// Mon 26 Feb to Sun 31 Mar: five weeks, padded before the 1st.
+	if len(data.Weeks) != 5 {
+		t.Fatalf("expected 5 weeks, got %d", len(data.Weeks))
+	}
+	for i, cd := range data.Weeks[0][:4] {
+		if !cd.Empty {
+			t.Errorf("week 0 day %d = %+v, want padding", i, cd)
+		}
+	}
+	if first := data.Weeks[0][4]; first.Date != "2024-03-01" || first.Day != 1 {
+		t.Errorf("first day = %+v, want Friday 1 March", first)
+	}
+
+	tue := data.Weeks[1][1]
+	if tue.Date != "2024-03-05" || tue.Journeys != 4 || tue.Spend != "£5.60" || tue.First != "07:52" || tue.Rating != "4" || tue.Level != 4 {
+		t.Errorf("5 March = %+v, want 4 journeys, £5.60, first at 07:52, rated 4", tue)
+	}
+	if want := "Tue 05 Mar 2024: 4 journeys, £5.60, first at 07:52, rated 4"; tue.Label != want {
+		t.Errorf("label = %q, want %q", tue.Label, want)
+	}
+	if wed := data.Weeks[1][2]; wed.Spend != "" || wed.First != "" || wed.Level != 1 {
+		t.Errorf("6 March = %+v, want no spend or first tap-in at level 1", wed)
+	}
+	if today := data.Weeks[3][2]; !today.Today || today.Future {
+		t.Errorf("20 March = %+v, want today", today)
+	}
+	if future := data.Weeks[3][3]; !future.Future {
+		t.Errorf("21 March = %+v, want future", future)
+	}
+	if gf := data.Weeks[4][4]; gf.DayOff != calendar.KindBankHoliday {
+		t.Errorf("29 March DayOff = %q, want bank holiday", gf.DayOff)
+	}
+
+	// Earlier months link to the next one and pad the last week.
+	feb := buildCalendarData(mustDate("2024-02-01"), mustDate("2024-03-20"), nil, nil, nil)
+	if feb.NextMonth != "2024-03" {
+		t.Errorf("February NextMonth = %q, want 2024-03", feb.NextMonth)
+	}
+	last := feb.Weeks[len(feb.Weeks)-1]
+	if last[3].Date != "2024-02-29" || !last[4].Empty || !last[6].Empty {
+		t.Errorf("last week of February = %+v, want Thursday 29th then padding", last)
+	}
+}
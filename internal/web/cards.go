@@ -0,0 +1,170 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/its-the-vibe/pearl/internal/annotations"
+	"github.com/its-the-vibe/pearl/internal/auth"
+	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+	"github.com/its-the-vibe/pearl/internal/tags"
+)
+
+// cardCookie remembers the card last selected with the switcher.
+const cardCookie = "pearl_card"
+
+// Card is an Oyster card whose journeys Pearl can display.
+type Card struct {
+	ID     string
+	Name   string
+	Client *bq.Client
+	// Users lists the usernames or emails allowed to view the card; empty
+	// allows everyone.
+	Users []string
+}
+
+// CardOption is an entry in the card switcher or comparison selector.
+type CardOption struct {
+	ID       string
+	Name     string
+	Selected bool
+}
+
+// Nav is passed to the shared "nav" template rendered at the top of every page.
+type Nav struct {
+	Active  string       // tab to highlight, e.g. "overview"
+	Cards   []CardOption // cards the user may switch between
+	Compare []CardOption // cards available for comparison; nil when the page has no comparison view
+	User    string       // authenticated user, empty when auth is disabled
+	Tag     string       // tag the journeys are filtered by; empty for all journeys
+	Tags    []string     // tags offered by the tag filter
+}
+
+// cardSelection holds the cards resolved for a request.
+type cardSelection struct {
+	Card    Card
+	Compare *Card  // nil unless a comparison card was requested
+	Tag     string // tag selected by the "tag" parameter; empty for all journeys
+	// Notes holds the annotations of Card, loaded once for the request.
+	Notes []annotations.Annotation
+	Nav   Nav
+}
+
+// canView reports whether the authenticated user u may view card. When
+// authentication is disabled (hasUser false) every card is visible.
+func canView(card Card, u auth.User, hasUser bool) bool {
+	if !hasUser || len(card.Users) == 0 {
+		return true
+	}
+	for _, allowed := range card.Users {
+		if strings.EqualFold(allowed, u.Name) || (u.Email != "" && strings.EqualFold(allowed, u.Email)) {
+			return true
+		}
+	}
+	return false
+}
+
+// visibleCards returns the cards u may view, preserving configuration order.
+func visibleCards(cards []Card, u auth.User, hasUser bool) []Card {
+	var out []Card
+	for _, c := range cards {
+		if canView(c, u, hasUser) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// findCard returns the card with the given id from cards.
+func findCard(cards []Card, id string) (Card, bool) {
+	for _, c := range cards {
+		if c.ID == id {
+			return c, true
+		}
+	}
+	return Card{}, false
+}
+
+// cardOptions builds switcher options marking selectedID as selected and
+// omitting excludeID.
+func cardOptions(cards []Card, selectedID, excludeID string) []CardOption {
+	opts := make([]CardOption, 0, len(cards))
+	for _, c := range cards {
+		if c.ID == excludeID {
+			continue
+		}
+		opts = append(opts, CardOption{ID: c.ID, Name: c.Name, Selected: c.ID == selectedID})
+	}
+	return opts
+}
+
+// cardError is a card selection failure to report with its status code.
+type cardError struct {
+	status int
+	msg    string
+}
+
+// selectCards resolves the card a request shows from the "card" query
+// parameter, falling back to the remembered card and then the first card the
+// user may view. When comparable is true the "compare" parameter selects a
+// second card. The "tag" parameter selects the tag journeys are filtered by.
+// On failure it writes an error response and returns false.
+func (h *Handler) selectCards(w http.ResponseWriter, r *http.Request, active string, comparable bool) (cardSelection, bool) {
+	sel, cerr := h.resolveCards(w, r, active, comparable)
+	if cerr != nil {
+		http.Error(w, cerr.msg, cerr.status)
+		return cardSelection{}, false
+	}
+	sel.Nav.Tags = h.tagOptions(sel.Notes, sel.Tag)
+	return sel, true
+}
+
+// resolveCards resolves the cards of a request like selectCards and loads the
+// annotations of the selected card, leaving the error response to the caller
+// and the tag filter options unset.
+func (h *Handler) resolveCards(w http.ResponseWriter, r *http.Request, active string, comparable bool) (cardSelection, *cardError) {
+	u, hasUser := auth.UserFromContext(r.Context())
+	visible := visibleCards(h.cards, u, hasUser)
+	if len(visible) == 0 {
+		return cardSelection{}, &cardError{http.StatusForbidden, "you do not have access to any cards"}
+	}
+
+	card := visible[0]
+	if id := r.URL.Query().Get("card"); id != "" {
+		c, ok := findCard(visible, id)
+		if !ok {
+			return cardSelection{}, &cardError{http.StatusNotFound, "unknown card"}
+		}
+		card = c
+		http.SetCookie(w, &http.Cookie{Name: cardCookie, Value: c.ID, Path: "/", HttpOnly: true, SameSite: http.SameSiteLaxMode})
+	} else if cookie, err := r.Cookie(cardCookie); err == nil {
+		if c, ok := findCard(visible, cookie.Value); ok {
+			card = c
+		}
+	}
+
+	sel := cardSelection{
+		Card: card,
+		Nav: Nav{
+			Active: active,
+			Cards:  cardOptions(visible, card.ID, ""),
+			User:   u.Name,
+		},
+	}
+	if comparable {
+		compareID := r.URL.Query().Get("compare")
+		if compareID != "" && compareID != card.ID {
+			c, ok := findCard(visible, compareID)
+			if !ok {
+				return cardSelection{}, &cardError{http.StatusNotFound, "unknown comparison card"}
+			}
+			sel.Compare = &c
+		}
+		sel.Nav.Compare = cardOptions(visible, compareID, card.ID)
+	}
+	sel.Tag = tags.NormalizeTag(r.URL.Query().Get("tag"))
+	sel.Nav.Tag = sel.Tag
+	sel.Notes = h.loadAnnotations(bq.WithPage(r.Context(), active), card, time.Time{}, time.Time{})
+	return sel, nil
+}
@@ -0,0 +1,124 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/its-the-vibe/pearl/internal/auth"
+)
+
+func testCards() []Card {
+	return []Card{
+		{ID: "alice", Name: "Alice", Users: []string{"alice", "alice@example.com"}},
+		{ID: "bob", Name: "Bob", Users: []string{"bob"}},
+		{ID: "shared", Name: "Household"},
+	}
+}
+
+func TestCanView(t *testing.T) {
+	cards := testCards()
+	tests := []struct {
+		name    string
+		card    Card
+		user    auth.User
+		hasUser bool
+		want    bool
+	}{
+		{"auth disabled", cards[0], auth.User{}, false, true},
+		{"listed username", cards[0], auth.User{Name: "alice"}, true, true},
+		{"listed email, case-insensitive", cards[0], auth.User{Name: "a", Email: "Alice@Example.com"}, true, true},
+		{"unlisted user", cards[0], auth.User{Name: "bob"}, true, false},
+		{"card without restrictions", cards[2], auth.User{Name: "bob"}, true, true},
+	}
+	for _, tt := range tests {
+		if got := canView(tt.card, tt.user, tt.hasUser); got != tt.want {
+			t.Errorf("%s: canView = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestVisibleCards(t *testing.T) {
+	got := visibleCards(testCards(), auth.User{Name: "bob"}, true)
+	if len(got) != 2 || got[0].ID != "bob" || got[1].ID != "shared" {
+		t.Errorf("visibleCards(bob) = %v, want [bob shared]", got)
+	}
+}
+
+func TestCardOptions(t *testing.T) {
+	opts := cardOptions(testCards(), "bob", "alice")
+	if len(opts) != 2 {
+		t.Fatalf("expected 2 options (alice excluded), got %d", len(opts))
+	}
+	if opts[0].ID != "bob" || !opts[0].Selected || opts[1].Selected {
+		t.Errorf("options = %+v, want bob selected", opts)
+	}
+}
+
+func TestSelectCards(t *testing.T) {
+	h := &Handler{cards: testCards()}
+
+	serve := func(target string, u *auth.User, cookie *http.Cookie) (cardSelection, *httptest.ResponseRecorder, bool) {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if u != nil {
+			req = req.WithContext(auth.WithUser(req.Context(), *u))
+		}
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		sel, ok := h.selectCards(rec, req, "overview", true)
+		return sel, rec, ok
+	}
+
+	// Defaults to the first visible card.
+	sel, _, ok := serve("/", &auth.User{Name: "bob"}, nil)
+	if !ok || sel.Card.ID != "bob" {
+		t.Errorf("default card = %q (ok %v), want bob", sel.Card.ID, ok)
+	}
+	if sel.Nav.User != "bob" || len(sel.Nav.Cards) != 2 {
+		t.Errorf("nav = %+v, want user bob with 2 cards", sel.Nav)
+	}
+
+	// The card parameter selects a card and remembers it in a cookie.
+	sel, rec, ok := serve("/?card=shared", &auth.User{Name: "bob"}, nil)
+	if !ok || sel.Card.ID != "shared" {
+		t.Errorf("?card=shared selected %q (ok %v)", sel.Card.ID, ok)
+	}
+	if len(rec.Result().Cookies()) != 1 || rec.Result().Cookies()[0].Value != "shared" {
+		t.Errorf("expected the selection to be stored in a cookie, got %v", rec.Result().Cookies())
+	}
+
+	// The cookie is used when no parameter is given.
+	sel, _, _ = serve("/", &auth.User{Name: "bob"}, &http.Cookie{Name: cardCookie, Value: "shared"})
+	if sel.Card.ID != "shared" {
+		t.Errorf("cookie selection = %q, want shared", sel.Card.ID)
+	}
+
+	// Cards the user may not view are not found.
+	if _, rec, ok := serve("/?card=alice", &auth.User{Name: "bob"}, nil); ok || rec.Code != http.StatusNotFound {
+		t.Errorf("?card=alice for bob: ok %v, status %d; want 404", ok, rec.Code)
+	}
+
+	// Comparison card.
+	sel, _, ok = serve("/?card=bob&compare=shared", &auth.User{Name: "bob"}, nil)
+	if !ok || sel.Compare == nil || sel.Compare.ID != "shared" {
+		t.Errorf("compare = %+v (ok %v), want shared", sel.Compare, ok)
+	}
+	if len(sel.Nav.Compare) != 1 || !sel.Nav.Compare[0].Selected {
+		t.Errorf("compare options = %+v, want shared selected", sel.Nav.Compare)
+	}
+
+	// The tag parameter is normalised and offered in the tag filter.
+	sel, _, _ = serve("/?tag=+Commute", &auth.User{Name: "bob"}, nil)
+	if sel.Tag != "commute" || sel.Nav.Tag != "commute" || !slices.Contains(sel.Nav.Tags, "commute") {
+		t.Errorf("?tag=Commute: tag %q, nav %+v; want commute", sel.Tag, sel.Nav)
+	}
+
+	// A user without any visible cards is forbidden.
+	h.cards = testCards()[:2]
+	if _, rec, ok := serve("/", &auth.User{Name: "mallory"}, nil); ok || rec.Code != http.StatusForbidden {
+		t.Errorf("mallory: ok %v, status %d; want 403", ok, rec.Code)
+	}
+}
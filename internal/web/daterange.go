@@ -0,0 +1,157 @@
+package web
+
+import (
+	"fmt"
+	"html/template"
+	"net/url"
+	"time"
+
+	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+)
+
+// customRangeDays is the DateRange.Days value of an explicit from/to range.
+// No preset date range option matches it.
+const customRangeDays = -1
+
+// maxRangeDays bounds explicit ranges so that a typo in a year cannot expand
+// into a query over decades of dates.
+const maxRangeDays = 5 * 366
+
+// DateRange is the period a page shows: either one of the preset "last N
+// days" options or an explicit, inclusive range of dates.
+type DateRange struct {
+	Days int       // last N days; 0 = all available; customRangeDays for an explicit range
+	From time.Time // first day of an explicit range; zero when unbounded
+	To   time.Time // last day of an explicit range; zero means today
+}
+
+// DateRangeForm holds the values shown in the date picker, which echo the
+// request when it could not be parsed.
+type DateRangeForm struct {
+	From  string // e.g. "2024-03-01"
+	To    string
+	Error string // validation error shown to the user; empty when valid
+}
+
+// Custom reports whether r is an explicit from/to range.
+func (r DateRange) Custom() bool {
+	return r.Days == customRangeDays
+}
+
+// Query returns the query string selecting r, such as "days=30" or
+// "from=2024-03-01&to=2024-03-31", for building shareable links.
+func (r DateRange) Query() template.URL {
+	if !r.Custom() {
+		return template.URL(fmt.Sprintf("days=%d", r.Days))
+	}
+	v := url.Values{}
+	if !r.From.IsZero() {
+		v.Set("from", r.From.Format("2006-01-02"))
+	}
+	if !r.To.IsZero() {
+		v.Set("to", r.To.Format("2006-01-02"))
+	}
+	return template.URL(v.Encode())
+}
+
+// Form returns the date picker values for r.
+func (r DateRange) Form() DateRangeForm {
+	var f DateRangeForm
+	if !r.From.IsZero() {
+		f.From = r.From.Format("2006-01-02")
+	}
+	if !r.To.IsZero() {
+		f.To = r.To.Format("2006-01-02")
+	}
+	return f
+}
+
+// bounds returns the first and last days covered by r relative to today.
+// A zero from means the range is unbounded in the past; a zero to means it
+// runs to the latest data.
+func (r DateRange) bounds(today time.Time) (from, to time.Time) {
+	if r.Custom() {
+		return r.From, r.To
+	}
+	if r.Days > 0 {
+		return today.AddDate(0, 0, -r.Days), time.Time{}
+	}
+	return time.Time{}, time.Time{}
+}
+
+// contains reports whether the day t falls within r.
+func (r DateRange) contains(t, today time.Time) bool {
+	from, to := r.bounds(today)
+	return (from.IsZero() || !t.Before(from)) && (to.IsZero() || !t.After(to))
+}
+
+// journeyFilter returns a journey filter covering r on every day of the week.
+func (r DateRange) journeyFilter(today time.Time) bq.JourneyFilter {
+	from, to := r.bounds(today)
+	return bq.JourneyFilter{From: from, To: to}
+}
+
+// parseDateRange parses the date range selected by the "days" parameter or
+// by the "from" and "to" ISO date parameters. Either bound of an explicit
+// range may be omitted. Without any of them the default of the last 30 days
+// is used. Invalid input is reported as an error suitable for showing to the
+// user.
+func parseDateRange(q url.Values, today time.Time) (DateRange, error) {
+	return parseDateRangeDefault(q, today, 30)
+}
+
+// parseDateRangeDefault parses a date range like parseDateRange, but
+// defaults to the last days days when the request selects no range. days
+// should be one of the preset options.
+func parseDateRangeDefault(q url.Values, today time.Time, days int) (DateRange, error) {
+	if len(q["days"]) == 0 && len(q["from"]) == 0 && len(q["to"]) == 0 {
+		return DateRange{Days: days}, nil
+	}
+
+	fromStr, toStr := q.Get("from"), q.Get("to")
+	if fromStr == "" && toStr == "" {
+		days, err := parseDaysParam(q.Get("days"))
+		if err != nil {
+			return DateRange{}, err
+		}
+		return DateRange{Days: days}, nil
+	}
+	if q.Get("days") != "" {
+		return DateRange{}, fmt.Errorf("choose either a preset range or from/to dates, not both")
+	}
+
+	r := DateRange{Days: customRangeDays}
+	var err error
+	if fromStr != "" {
+		if r.From, err = time.Parse("2006-01-02", fromStr); err != nil {
+			return DateRange{}, fmt.Errorf("invalid from date %q: use YYYY-MM-DD", fromStr)
+		}
+	}
+	if toStr != "" {
+		if r.To, err = time.Parse("2006-01-02", toStr); err != nil {
+			return DateRange{}, fmt.Errorf("invalid to date %q: use YYYY-MM-DD", toStr)
+		}
+		// There is no data after today, so a later end reads nothing more.
+		if r.To.After(today) {
+			r.To = today
+		}
+	}
+	if !r.From.IsZero() && r.From.After(today) {
+		return DateRange{}, fmt.Errorf("from date %s is in the future", fromStr)
+	}
+	if !r.From.IsZero() && !r.To.IsZero() {
+		if r.From.After(r.To) {
+			return DateRange{}, fmt.Errorf("from date %s is after to date %s", fromStr, toStr)
+		}
+	}
+	if !r.From.IsZero() {
+		to := r.To
+		if to.IsZero() {
+			to = today
+		}
+		if to.Sub(r.From) > maxRangeDays*24*time.Hour {
+			return DateRange{}, fmt.Errorf("date range is longer than %d days", maxRangeDays)
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,117 @@
+package web
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+)
+
+func TestParseDateRange(t *testing.T) {
+	today := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	day := func(s string) time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return d
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		want    DateRange
+		wantErr string
+	}{
+		{"default", "", DateRange{Days: 30}, ""},
+		{"preset", "days=90", DateRange{Days: 90}, ""},
+		{"all available", "days=0", DateRange{Days: 0}, ""},
+		{"invalid preset", "days=15", DateRange{}, "invalid date range"},
+		{"explicit range", "from=2024-02-01&to=2024-02-29", DateRange{Days: customRangeDays, From: day("2024-02-01"), To: day("2024-02-29")}, ""},
+		{"from only", "from=2024-02-01", DateRange{Days: customRangeDays, From: day("2024-02-01")}, ""},
+		{"to only", "to=2023-12-31", DateRange{Days: customRangeDays, To: day("2023-12-31")}, ""},
+		{"future to", "from=2024-02-01&to=9999-12-31", DateRange{Days: customRangeDays, From: day("2024-02-01"), To: today}, ""},
+		{"future to only", "to=9999-12-31", DateRange{Days: customRangeDays, To: today}, ""},
+		{"bad from", "from=01/02/2024", DateRange{}, "invalid from date"},
+		{"bad to", "from=2024-02-01&to=2024-02-30", DateRange{}, "invalid to date"},
+		{"reversed", "from=2024-03-01&to=2024-02-01", DateRange{}, "is after to date"},
+		{"future", "from=2024-04-01", DateRange{}, "in the future"},
+		{"too long", "from=1990-01-01", DateRange{}, "longer than"},
+		{"both kinds", "days=30&from=2024-02-01", DateRange{}, "not both"},
+	}
+	for _, tt := range tests {
+		q, _ := url.ParseQuery(tt.query)
+		got, err := parseDateRange(q, today)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: error = %v, want it to contain %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if got.Days != tt.want.Days || !got.From.Equal(tt.want.From) || !got.To.Equal(tt.want.To) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDateRangeQuery(t *testing.T) {
+	from := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		rng  DateRange
+		want string
+	}{
+		{DateRange{Days: 30}, "days=30"},
+		{DateRange{Days: 0}, "days=0"},
+		{DateRange{Days: customRangeDays, From: from, To: to}, "from=2024-02-01&to=2024-02-29"},
+		{DateRange{Days: customRangeDays, From: from}, "from=2024-02-01"},
+	}
+	for _, tt := range tests {
+		if got := string(tt.rng.Query()); got != tt.want {
+			t.Errorf("Query() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestDateRangeJourneyFilter(t *testing.T) {
+	today := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+
+	f := DateRange{Days: 7}.journeyFilter(today)
+	if !f.From.Equal(today.AddDate(0, 0, -7)) || !f.To.IsZero() || len(f.Weekdays) != 0 {
+		t.Errorf("days=7: filter = %+v, want From 7 days ago and every weekday", f)
+	}
+
+	from := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)
+	f = DateRange{Days: customRangeDays, From: from, To: to}.journeyFilter(today)
+	if !f.From.Equal(from) || !f.To.Equal(to) {
+		t.Errorf("explicit range: filter = %+v, want %v–%v", f, from, to)
+	}
+
+	if f := (DateRange{}).journeyFilter(today); !f.From.IsZero() || !f.To.IsZero() {
+		t.Errorf("all available: filter = %+v, want unbounded", f)
+	}
+}
+
+func TestBuildCommuteData_ExplicitRange(t *testing.T) {
+	tue, wed, thu := commuteWeekDates()
+	journeys := []bq.CommuteJourney{
+		{Date: tue.Format("2006-01-02"), StartTime: "08:00", EndTime: "09:00"},
+		{Date: wed.Format("2006-01-02"), StartTime: "08:00", EndTime: "09:00"},
+		{Date: thu.Format("2006-01-02"), StartTime: "08:00", EndTime: "09:00"},
+	}
+
+	rng := DateRange{Days: customRangeDays, From: wed, To: wed}
+	data := buildCommuteData(journeys, nil, rng)
+	if data.TotalCommutes != 1 || data.Commutes[0].ISODate != wed.Format("2006-01-02") {
+		t.Errorf("expected only the Wednesday commute, got %+v", data.Commutes)
+	}
+	for _, opt := range data.DateRangeOptions {
+		if opt.Selected {
+			t.Errorf("no preset should be selected for an explicit range, got %q", opt.Label)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package web
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/its-the-vibe/pearl/internal/annotations"
+	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+	"github.com/its-the-vibe/pearl/internal/calendar"
+)
+
+// Geometry of the day timeline: a strip spanning the 24 hours of the day.
+const (
+	dayChartWidth  = 720 // two minutes per unit
+	dayChartHeight = 24
+)
+
+// DayJourney is a single journey in the day timeline.
+type DayJourney struct {
+	Start    string // tap-in time, e.g. "08:02"; empty when not recorded
+	End      string // tap-out time; empty when not recorded
+	Duration string // e.g. "43m"; empty without both times
+	Action   string
+	Type     string // one of bq.JourneyTypes
+	Charge   string // empty when nothing was charged
+	Credit   string // empty without a credit
+	Balance  string // balance after the journey
+	Note     string
+	Tags     []string // assigned by the tag rules
+	X, Width float64  // position in the timeline; Width is 0 without a start time
+	// Key identifies the journey in the annotation form, e.g.
+	// "08:00|Clapham Common to Bank".
+	Key         string
+	Annotations []DayAnnotation
+}
+
+// Label returns the tooltip text of the journey in the timeline.
+func (j DayJourney) Label() string {
+	label := j.Start
+	if j.End != "" {
+		label += "–" + j.End
+	}
+	if j.Duration != "" {
+		label += fmt.Sprintf(" (%s)", j.Duration)
+	}
+	return label + ": " + j.Action
+}
+
+// DayAnnotation is a personal note or tags attached to the day or one of its
+// journeys.
+type DayAnnotation struct {
+	ID      string
+	Date    string // ISO date of the day, for the delete form
+	Note    string
+	Tags    []string
+	Journey string // for journeys missing from the timeline, e.g. "08:00 Clapham Common to Bank"
+}
+
+// DayData is passed to the day template.
+type DayData struct {
+	Date        string // e.g. "Tuesday 05 March 2024"
+	ISODate     string // e.g. "2024-03-05"
+	Journeys    int
+	Spend       string
+	Rating      string // e.g. "4"; empty without a rating
+	Comment     string
+	Labels      []string        // bank holidays, leave and attendance overrides
+	Annotations []DayAnnotation // of the whole day
+	Annotating  bool            // annotations are enabled
+	FormError   string          // annotation validation error; empty when valid
+	Timeline    []DayJourney
+	ChartWidth  int
+	ChartHeight int
+	PrevDate    string // ISO date of the previous day
+	NextDate    string // ISO date of the next day; empty for today
+	Nav         Nav
+}
+
+func (h *Handler) handleDay(w http.ResponseWriter, r *http.Request) {
+	day, ok := parseDayPath(w, r)
+	if !ok {
+		return
+	}
+	sel, ok := h.selectCards(w, r, "day", false)
+	if !ok {
+		return
+	}
+	h.renderDay(w, r, sel, day, "")
+}
+
+// parseDayPath parses the date of a day page URL, responding with 404 Not
+// Found to invalid or future dates.
+func parseDayPath(w http.ResponseWriter, r *http.Request) (time.Time, bool) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	day, err := time.Parse("2006-01-02", r.PathValue("date"))
+	if err != nil || day.After(today) {
+		http.NotFound(w, r)
+		return time.Time{}, false
+	}
+	return day, true
+}
+
+// renderDay renders the day page of the selected card, with formError shown
+// next to the annotation form and a 400 status when it is not empty.
+func (h *Handler) renderDay(w http.ResponseWriter, r *http.Request, sel cardSelection, day time.Time, formError string) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	ctx := bq.WithPage(r.Context(), "day")
+	filter := h.tagged(sel.Notes, sel.Tag, bq.JourneyFilter{From: day, To: day})
+	journeys, _, err := sel.Card.Client.SearchJourneys(ctx, bq.JourneySearch{Filter: filter, Ascending: true})
+	if err != nil {
+		slog.Error("querying bigquery for journeys", "card", sel.Card.ID, "error", err)
+		http.Error(w, "failed to load journey data", http.StatusInternalServerError)
+		return
+	}
+	ratings, err := sel.Card.Client.Ratings(ctx, filter)
+	if err != nil {
+		// Ratings are optional; log and continue without them.
+		slog.Warn("querying bigquery for ratings", "card", sel.Card.ID, "error", err)
+	}
+
+	notes := newAnnotationIndex(sel.Notes)[day.Format("2006-01-02")]
+	data := buildDayData(day, today, journeys, ratings, notes, h.opts.Calendar, h.opts.Attendance.Overrides)
+	for i, j := range journeys {
+		data.Timeline[i].Tags = journeyTags(h.opts.Tags, j)
+	}
+	data.Annotating = h.opts.Annotations != nil
+	data.FormError = formError
+	data.Nav = sel.Nav
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if formError != "" {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	if err := h.tmpl.ExecuteTemplate(w, "day.html", data); err != nil {
+		slog.Error("rendering day template", "error", err)
+	}
+}
+
+// buildDayData lays out the journeys of day, in the order travelled, on a
+// timeline together with the day's rating, the annotations in notes and
+// labels from cal and the attendance overrides.
+func buildDayData(day, today time.Time, journeys []bq.Journey, ratings []bq.DailyRating, notes []annotations.Annotation, cal *calendar.Calendar, overrides map[string]AttendanceOverride) DayData {
+	data := DayData{
+		Date:        day.Format("Monday 02 January 2006"),
+		ISODate:     day.Format("2006-01-02"),
+		Timeline:    []DayJourney{},
+		ChartWidth:  dayChartWidth,
+		ChartHeight: dayChartHeight,
+		PrevDate:    day.AddDate(0, 0, -1).Format("2006-01-02"),
+	}
+	if day.Before(today) {
+		data.NextDate = day.AddDate(0, 0, 1).Format("2006-01-02")
+	}
+
+	if off, ok := cal.Lookup(day); ok {
+		data.Labels = append(data.Labels, dayOffName(off))
+	}
+	if o, ok := overrides[data.ISODate]; ok {
+		note := "Not in the office"
+		if o.Office {
+			note = "In the office"
+		}
+		if o.Note != "" {
+			note += ": " + o.Note
+		}
+		data.Labels = append(data.Labels, note)
+	}
+	for _, r := range ratings {
+		if r.Date.Equal(day) {
+			data.Rating = strconv.FormatFloat(r.Rating, 'f', -1, 64)
+			data.Comment = r.Comment
+		}
+	}
+
+	var spend float64
+	minute := float64(dayChartWidth) / (24 * 60)
+	for _, j := range journeys {
+		dj := DayJourney{
+			Start:   j.StartTime,
+			End:     j.EndTime,
+			Action:  j.JourneyAction,
+			Type:    bq.JourneyType(j),
+			Balance: formatPounds(j.Balance),
+			Note:    j.Note,
+			Key:     j.StartTime + "|" + j.JourneyAction,
+		}
+		if j.Charge != 0 {
+			dj.Charge = formatPounds(j.Charge)
+		}
+		if j.Credit != 0 {
+			dj.Credit = formatPounds(j.Credit)
+		}
+		if start, err := parseTimeToMinutes(j.StartTime); err == nil {
+			dj.X = roundTenth(float64(start) * minute)
+			dj.Width = 2
+			if end, err := parseTimeToMinutes(j.EndTime); err == nil {
+				if end < start {
+					end += 24 * 60 // tapped out after midnight
+				}
+				dj.Duration = formatDuration(end - start)
+				// Journeys running past midnight are cut off at the end of the strip.
+				dj.Width = roundTenth(max(2, min(float64(end-start)*minute, dayChartWidth-dj.X)))
+			}
+		}
+		spend += j.Charge
+		data.Timeline = append(data.Timeline, dj)
+	}
+	data.Journeys = len(data.Timeline)
+	data.Spend = formatPounds(spend)
+
+	for _, a := range notes {
+		da := DayAnnotation{ID: a.ID, Date: data.ISODate, Note: a.Note, Tags: a.Tags}
+		if !a.Journey() {
+			data.Annotations = append(data.Annotations, da)
+			continue
+		}
+		i := slices.IndexFunc(data.Timeline, func(j DayJourney) bool { return j.Start == a.StartTime && j.Action == a.Action })
+		if i < 0 {
+			// The journey is no longer in the data; keep the note visible.
+			da.Journey = strings.TrimSpace(a.StartTime + " " + a.Action)
+			data.Annotations = append(data.Annotations, da)
+			continue
+		}
+		data.Timeline[i].Annotations = append(data.Timeline[i].Annotations, da)
+	}
+	return data
+}
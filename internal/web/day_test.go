@@ -0,0 +1,78 @@
+package web
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/its-the-vibe/pearl/internal/annotations"
+	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+	"github.com/its-the-vibe/pearl/internal/calendar"
+)
+
+func TestBuildDayData(t *testing.T) {
+	day := mustDate("2024-03-05")
+	data := buildDayData(day, mustDate("2024-03-31"), []bq.Journey{
+		{Date: "05-Mar-24", StartTime: "08:00", EndTime: "08:45", JourneyAction: "Clapham Common to Bank", Charge: 2.8, Balance: 17.2},
+		{Date: "05-Mar-24", StartTime: "12:00", JourneyAction: "Auto top-up", Credit: 20, Balance: 37.2},
+		{Date: "05-Mar-24", StartTime: "23:50", EndTime: "00:20", JourneyAction: "Bank to Clapham Common", Charge: 2.8, Balance: 34.4, Note: "late one"},
+		{Date: "05-Mar-24", JourneyAction: "Bank to Clapham Common [No touch-in]", Charge: 8.8, Balance: 25.6},
+	}, []bq.DailyRating{
+		{Date: mustDate("2024-03-04"), Rating: 2},
+		{Date: day, Rating: 4, Comment: "signal failure"},
+	}, []annotations.Annotation{
+		{ID: "a", Date: day, Tags: []string{"strike"}},
+		{ID: "b", Date: day, StartTime: "08:00", Action: "Clapham Common to Bank", Note: "walked from Clapham"},
+		{ID: "c", Date: day, StartTime: "09:00", Action: "Bank to Oval", Note: "gone"},
+	}, calendar.New([]calendar.DayOff{{Date: day, Kind: calendar.KindLeave, Name: "dentist"}}), map[string]AttendanceOverride{
+		"2024-03-05": {Office: true, Note: "cycled"},
+	})
+
+	if data.Date != "Tuesday 05 March 2024" || data.PrevDate != "2024-03-04" || data.NextDate != "2024-03-06" {
+		t.Errorf("Date, PrevDate, NextDate = %q, %q, %q, want Tuesday 05 March 2024, 2024-03-04, 2024-03-06", data.Date, data.PrevDate, data.NextDate)
+	}
+	if data.Journeys != 4 || data.Spend != "£14.40" {
+		t.Errorf("Journeys, Spend = %d, %q, want 4, £14.40", data.Journeys, data.Spend)
+	}
+	if data.Rating != "4" || data.Comment != "signal failure" {
+		t.Errorf("Rating, Comment = %q, %q, want 4, signal failure", data.Rating, data.Comment)
+	}
+	if want := []string{"leave: dentist", "In the office: cycled"}; !slices.Equal(data.Labels, want) {
+		t.Errorf("Labels = %q, want %q", data.Labels, want)
+	}
+	if len(data.Annotations) != 2 || data.Annotations[0].ID != "a" || data.Annotations[0].Journey != "" || data.Annotations[1].Journey != "09:00 Bank to Oval" {
+		t.Errorf("Annotations = %+v, want the day one and the one of the missing journey", data.Annotations)
+	}
+	if a := data.Timeline[0].Annotations; len(a) != 1 || a[0].ID != "b" || a[0].Date != "2024-03-05" {
+		t.Errorf("Timeline[0].Annotations = %+v, want b", a)
+	}
+	if data.Timeline[0].Key != "08:00|Clapham Common to Bank" {
+		t.Errorf("Timeline[0].Key = %q, want 08:00|Clapham Common to Bank", data.Timeline[0].Key)
+	}
+
+	tests := []struct {
+		duration, charge, credit, balance string
+		x, width                          float64
+	}{
+		{"45m", "£2.80", "", "£17.20", 240, 22.5},
+		{"", "", "£20.00", "£37.20", 360, 2},
+		{"30m", "£2.80", "", "£34.40", 715, 5}, // cut off at midnight
+		{"", "£8.80", "", "£25.60", 0, 0},      // not placed without a start time
+	}
+	for i, tt := range tests {
+		j := data.Timeline[i]
+		if j.Duration != tt.duration || j.Charge != tt.charge || j.Credit != tt.credit || j.Balance != tt.balance {
+			t.Errorf("Timeline[%d] = %q, %q, %q, %q, want %q, %q, %q, %q", i, j.Duration, j.Charge, j.Credit, j.Balance, tt.duration, tt.charge, tt.credit, tt.balance)
+		}
+		if j.X != tt.x || j.Width != tt.width {
+			t.Errorf("Timeline[%d] at %v width %v, want %v width %v", i, j.X, j.Width, tt.x, tt.width)
+		}
+	}
+	if got, want := data.Timeline[0].Label(), "08:00–08:45 (45m): Clapham Common to Bank"; got != want {
+		t.Errorf("Label() = %q, want %q", got, want)
+	}
+
+	today := buildDayData(day, day, nil, nil, nil, nil, nil)
+	if today.NextDate != "" || len(today.Labels) != 0 || len(today.Annotations) != 0 || today.Rating != "" {
+		t.Errorf("today = next %q, labels %q, annotations %+v, rating %q, want none", today.NextDate, today.Labels, today.Annotations, today.Rating)
+	}
+}
@@ -0,0 +1,176 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+)
+
+// DigestJourney is a journey listed in a digest.
+type DigestJourney struct {
+	Date   string // e.g. "Tue 05 Mar"
+	Start  string // empty when not recorded
+	Action string
+	Charge string // empty when nothing was charged
+}
+
+// DigestData is passed to the digest email template.
+type DigestData struct {
+	Subject        string // e.g. "Weekly digest: 4–10 Mar 2024"
+	Title          string // e.g. "Weekly digest"
+	Period         string // e.g. "4–10 Mar 2024"
+	CardName       string
+	Journeys       int // excluding top-ups
+	ActiveDays     int
+	Spend          string
+	Commutes       int
+	TypicalCommute string // median commute duration; empty without commutes
+	LongestCommute string
+	CapDays        []string        // days the daily cap was reached, e.g. "Tue 05 Mar"
+	Incomplete     []DigestJourney // journeys missing a touch in or out
+	URL            string          // journeys of the period on the dashboard; empty without a base URL
+}
+
+// Digest renders the digest email of kind, "week" or "month", for card. It
+// covers the last complete week or month before today and returns the
+// subject and HTML body.
+func (h *Handler) Digest(ctx context.Context, card Card, kind string, today time.Time) (string, []byte, error) {
+	data, err := h.loadDigest(ctx, card, kind, today)
+	if err != nil {
+		return "", nil, err
+	}
+	var buf bytes.Buffer
+	if err := h.tmpl.ExecuteTemplate(&buf, "digest.html", data); err != nil {
+		return "", nil, fmt.Errorf("rendering digest: %w", err)
+	}
+	return data.Subject, buf.Bytes(), nil
+}
+
+// handleDigestPreview renders the digest email of the selected card in the
+// browser. The "period" parameter selects the weekly (default) or monthly
+// digest.
+func (h *Handler) handleDigestPreview(w http.ResponseWriter, r *http.Request) {
+	sel, ok := h.selectCards(w, r, "digest", false)
+	if !ok {
+		return
+	}
+
+	kind := periodWeek
+	if r.URL.Query().Get("period") == periodMonth {
+		kind = periodMonth
+	}
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	_, body, err := h.Digest(bq.WithPage(r.Context(), "digest"), sel.Card, kind, today)
+	if err != nil {
+		slog.Error("building digest", "card", sel.Card.ID, "error", err)
+		http.Error(w, "failed to build digest", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(body)
+}
+
+// loadDigest queries the journeys and commutes of card for the digest.
+func (h *Handler) loadDigest(ctx context.Context, card Card, kind string, today time.Time) (DigestData, error) {
+	p := digestPeriod(kind, today)
+	rng := p.dateRange()
+	journeys, _, err := card.Client.SearchJourneys(ctx, bq.JourneySearch{Filter: rng.journeyFilter(today), Ascending: true})
+	if err != nil {
+		return DigestData{}, fmt.Errorf("querying journeys: %w", err)
+	}
+	notes := h.loadAnnotations(ctx, card, time.Time{}, time.Time{})
+	commutes, err := card.Client.CommuteJourneys(ctx, h.commuteFilter(notes, rng, today))
+	if err != nil {
+		return DigestData{}, fmt.Errorf("querying commutes: %w", err)
+	}
+
+	data := buildDigestData(kind, p, journeys, withoutDaysOff(commutes, h.opts.Calendar), h.opts.BaseURL)
+	data.CardName = card.Name
+	return data, nil
+}
+
+// digestPeriod returns the last complete week, Monday to Sunday, or calendar
+// month before today.
+func digestPeriod(kind string, today time.Time) period {
+	if kind == periodMonth {
+		from := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -1, 0)
+		return period{From: from, To: from.AddDate(0, 1, -1)}
+	}
+	monday := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+	return period{From: monday.AddDate(0, 0, -7), To: monday.AddDate(0, 0, -1)}
+}
+
+// buildDigestData summarises the journeys and commutes of p: totals, commute
+// durations, the days the daily cap was reached and incomplete journeys.
+// Links point to baseURL when it is set.
+func buildDigestData(kind string, p period, journeys []bq.Journey, commutes []bq.CommuteJourney, baseURL string) DigestData {
+	data := DigestData{Title: "Weekly digest", Period: p.label()}
+	if kind == periodMonth {
+		data.Title = "Monthly digest"
+	}
+	data.Subject = data.Title + ": " + data.Period
+
+	var spend float64
+	active := make(map[string]bool)
+	capped := make(map[string]bool)
+	for _, j := range journeys {
+		spend += j.Charge
+		if bq.JourneyType(j) == bq.JourneyTypeTopUp {
+			continue
+		}
+		data.Journeys++
+		t, err := parseJourneyDate(j.Date)
+		if err != nil {
+			continue
+		}
+		day := t.Format("Mon 02 Jan")
+		active[day] = true
+		if capHit(j) && !capped[day] {
+			capped[day] = true
+			data.CapDays = append(data.CapDays, day)
+		}
+		if incompleteJourney(j) {
+			dj := DigestJourney{Date: day, Start: j.StartTime, Action: j.JourneyAction}
+			if j.Charge != 0 {
+				dj.Charge = formatPounds(j.Charge)
+			}
+			data.Incomplete = append(data.Incomplete, dj)
+		}
+	}
+	data.ActiveDays = len(active)
+	data.Spend = formatPounds(spend)
+
+	cd := buildCommuteData(commutes, nil, p.dateRange())
+	data.Commutes = cd.TotalCommutes
+	if data.Commutes > 0 {
+		data.TypicalCommute = cd.Durations.P50
+		data.LongestCommute = cd.LongestCommute
+	}
+
+	if baseURL != "" {
+		q := url.Values{"from": {p.From.Format("2006-01-02")}, "to": {p.To.Format("2006-01-02")}}
+		data.URL = strings.TrimSuffix(baseURL, "/") + "/journeys?" + q.Encode()
+	}
+	return data
+}
+
+// capHit reports whether the fare of j was capped, which Oyster notes as "The
+// fare for this journey was capped as you reached the daily charging limit
+// for the zones used".
+func capHit(j bq.Journey) bool {
+	return strings.Contains(strings.ToLower(j.Note), "capped")
+}
+
+// incompleteJourney reports whether j is missing a touch in or out, such as
+// "Bank to Clapham Common [No touch-out]".
+func incompleteJourney(j bq.Journey) bool {
+	action := strings.ToLower(j.JourneyAction)
+	return strings.Contains(action, "no touch-in") || strings.Contains(action, "no touch-out")
+}
@@ -0,0 +1,63 @@
+package web
+
+import (
+	"slices"
+	"testing"
+
+	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+)
+
+func TestDigestPeriod(t *testing.T) {
+	tests := []struct {
+		kind, today, from, to string
+	}{
+		{periodWeek, "2024-03-11", "2024-03-04", "2024-03-10"}, // a Monday
+		{periodWeek, "2024-03-17", "2024-03-04", "2024-03-10"}, // a Sunday
+		{periodMonth, "2024-03-01", "2024-02-01", "2024-02-29"},
+		{periodMonth, "2024-01-15", "2023-12-01", "2023-12-31"},
+	}
+	for _, tt := range tests {
+		p := digestPeriod(tt.kind, mustDate(tt.today))
+		if got, want := p.From.Format("2006-01-02")+" "+p.To.Format("2006-01-02"), tt.from+" "+tt.to; got != want {
+			t.Errorf("digestPeriod(%s, %s) = %s, want %s", tt.kind, tt.today, got, want)
+		}
+	}
+}
+
+func TestBuildDigestData(t *testing.T) {
+	p := digestPeriod(periodWeek, mustDate("2024-03-11"))
+	data := buildDigestData(periodWeek, p, []bq.Journey{
+		{Date: "05-Mar-24", StartTime: "08:00", EndTime: "08:45", JourneyAction: "Clapham Common to Bank", Charge: 2.8},
+		{Date: "05-Mar-24", StartTime: "12:00", JourneyAction: "Auto top-up", Credit: 20},
+		{Date: "05-Mar-24", StartTime: "18:00", EndTime: "18:40", JourneyAction: "Bank to Clapham Common", Charge: 1.2, Note: "The fare for this journey was capped as you reached the daily charging limit for the zones used"},
+		{Date: "05-Mar-24", StartTime: "20:00", EndTime: "20:30", JourneyAction: "Clapham Common to Oval", Note: "This journey was capped"},
+		{Date: "07-Mar-24", JourneyAction: "Bank to Clapham Common [No touch-in]", Charge: 8.8},
+	}, []bq.CommuteJourney{
+		{Date: "05-Mar-24", StartTime: "08:00", EndTime: "08:45", JourneyAction: "Clapham Common to Bank"},
+		{Date: "07-Mar-24", StartTime: "08:10", EndTime: "09:10", JourneyAction: "Clapham Common to Bank"},
+	}, "https://pearl.example.com/")
+
+	if data.Subject != "Weekly digest: 4–10 Mar 2024" {
+		t.Errorf("Subject = %q, want Weekly digest: 4–10 Mar 2024", data.Subject)
+	}
+	if data.Journeys != 4 || data.ActiveDays != 2 || data.Spend != "£12.80" {
+		t.Errorf("Journeys, ActiveDays, Spend = %d, %d, %q, want 4, 2, £12.80", data.Journeys, data.ActiveDays, data.Spend)
+	}
+	if data.Commutes != 2 || data.LongestCommute != "1h" {
+		t.Errorf("Commutes, LongestCommute = %d, %q, want 2, 1h", data.Commutes, data.LongestCommute)
+	}
+	if want := []string{"Tue 05 Mar"}; !slices.Equal(data.CapDays, want) {
+		t.Errorf("CapDays = %q, want %q", data.CapDays, want)
+	}
+	if len(data.Incomplete) != 1 || data.Incomplete[0] != (DigestJourney{Date: "Thu 07 Mar", Action: "Bank to Clapham Common [No touch-in]", Charge: "£8.80"}) {
+		t.Errorf("Incomplete = %+v, want the journey without a touch in", data.Incomplete)
+	}
+	if want := "https://pearl.example.com/journeys?from=2024-03-04&to=2024-03-10"; data.URL != want {
+		t.Errorf("URL = %q, want %q", data.URL, want)
+	}
+
+	month := buildDigestData(periodMonth, digestPeriod(periodMonth, mustDate("2024-03-01")), nil, nil, "")
+	if month.Title != "Monthly digest" || month.Commutes != 0 || month.TypicalCommute != "" || month.URL != "" {
+		t.Errorf("empty monthly digest = %+v", month)
+	}
+}
@@ -0,0 +1,235 @@
+package web
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/its-the-vibe/pearl/internal/tags"
+)
+
+// Duration histogram layout constants.
+const (
+	histPaddingLeft   = 40
+	histPaddingTop    = 10
+	histPaddingBottom = 30
+	histPlotHeight    = 120
+	histBarStep       = 24 // horizontal distance between bucket bars
+	histBarWidth      = 20
+	histMaxBuckets    = 20
+)
+
+// DurationBucket is a single bar of the commute duration histogram. The
+// bucket covers durations in [MinMinutes, MaxMinutes).
+type DurationBucket struct {
+	Label      string `json:"label"` // e.g. "35–40m"
+	MinMinutes int    `json:"min_minutes"`
+	MaxMinutes int    `json:"max_minutes"`
+	Count      int    `json:"count"`
+	X          int    `json:"-"` // left edge of bar rect
+	Y          int    `json:"-"` // top y of bar rect
+	Height     int    `json:"-"`
+	LabelX     int    `json:"-"` // centre x for the axis label
+}
+
+// WeekdayDurations summarises commute durations on one day of the week.
+type WeekdayDurations struct {
+	Weekday     string  `json:"weekday"`
+	Count       int     `json:"count"`
+	MeanMinutes float64 `json:"mean_minutes"`
+	P50Minutes  float64 `json:"p50_minutes"`
+	P90Minutes  float64 `json:"p90_minutes"`
+	Mean        string  `json:"-"`
+	P50         string  `json:"-"`
+	P90         string  `json:"-"`
+}
+
+// DurationStats describes the distribution of commute durations.
+type DurationStats struct {
+	Count         int                `json:"count"`
+	MeanMinutes   float64            `json:"mean_minutes"`
+	StdDevMinutes float64            `json:"stddev_minutes"`
+	P50Minutes    float64            `json:"p50_minutes"`
+	P90Minutes    float64            `json:"p90_minutes"`
+	P95Minutes    float64            `json:"p95_minutes"`
+	Histogram     []DurationBucket   `json:"histogram"`
+	Weekdays      []WeekdayDurations `json:"weekdays"`
+
+	// Formatted values (e.g. "42m") and histogram geometry for the template.
+	StdDev      string `json:"-"`
+	P50         string `json:"-"`
+	P90         string `json:"-"`
+	P95         string `json:"-"`
+	SVGWidth    int    `json:"-"`
+	SVGHeight   int    `json:"-"`
+	ChartLeft   int    `json:"-"`
+	ChartBottom int    `json:"-"`
+	LabelY      int    `json:"-"`
+	MaxCount    int    `json:"-"`
+	MaxCountY   int    `json:"-"`
+}
+
+// buildDurationStats computes the duration distribution of the given commutes:
+// percentiles, standard deviation, a histogram laid out for the SVG template,
+// and a breakdown for each weekday of the default commute and any other
+// weekday with commutes.
+func buildDurationStats(points []CommutePoint) DurationStats {
+	durations := make([]int, 0, len(points))
+	byWeekday := make(map[time.Weekday][]int)
+	for _, p := range points {
+		durations = append(durations, p.Minutes)
+		if t, err := time.Parse("2006-01-02", p.ISODate); err == nil {
+			byWeekday[t.Weekday()] = append(byWeekday[t.Weekday()], p.Minutes)
+		}
+	}
+	slices.Sort(durations)
+
+	stats := DurationStats{
+		Count:         len(durations),
+		MeanMinutes:   mean(durations),
+		StdDevMinutes: stdDev(durations),
+		P50Minutes:    percentile(durations, 50),
+		P90Minutes:    percentile(durations, 90),
+		P95Minutes:    percentile(durations, 95),
+		StdDev:        "–",
+		P50:           "–",
+		P90:           "–",
+		P95:           "–",
+		Histogram:     []DurationBucket{},
+		Weekdays:      []WeekdayDurations{},
+	}
+	if len(durations) > 0 {
+		stats.StdDev = formatMinutes(stats.StdDevMinutes)
+		stats.P50 = formatMinutes(stats.P50Minutes)
+		stats.P90 = formatMinutes(stats.P90Minutes)
+		stats.P95 = formatMinutes(stats.P95Minutes)
+	}
+
+	for i := range 7 {
+		wd := (time.Monday + time.Weekday(i)) % 7 // the week starts on Monday
+		ds := byWeekday[wd]
+		if len(ds) == 0 && !slices.Contains(tags.DefaultCommute.Weekdays, wd) {
+			continue
+		}
+		slices.Sort(ds)
+		w := WeekdayDurations{
+			Weekday:     wd.String(),
+			Count:       len(ds),
+			MeanMinutes: mean(ds),
+			P50Minutes:  percentile(ds, 50),
+			P90Minutes:  percentile(ds, 90),
+			Mean:        "–",
+			P50:         "–",
+			P90:         "–",
+		}
+		if len(ds) > 0 {
+			w.Mean = formatMinutes(w.MeanMinutes)
+			w.P50 = formatMinutes(w.P50Minutes)
+			w.P90 = formatMinutes(w.P90Minutes)
+		}
+		stats.Weekdays = append(stats.Weekdays, w)
+	}
+
+	stats.Histogram, stats.MaxCount = durationHistogram(durations)
+	chartBottom := histPaddingTop + histPlotHeight
+	numBars := len(stats.Histogram)
+	if numBars == 0 {
+		numBars = 1
+	}
+	stats.SVGWidth = histPaddingLeft + numBars*histBarStep + histBarStep
+	stats.SVGHeight = chartBottom + histPaddingBottom
+	stats.ChartLeft = histPaddingLeft
+	stats.ChartBottom = chartBottom
+	stats.LabelY = chartBottom + 15
+	stats.MaxCountY = histPaddingTop
+	for i := range stats.Histogram {
+		b := &stats.Histogram[i]
+		if stats.MaxCount > 0 {
+			b.Height = b.Count * histPlotHeight / stats.MaxCount
+		}
+		b.X = histPaddingLeft + i*histBarStep + (histBarStep-histBarWidth)/2
+		b.Y = chartBottom - b.Height
+		b.LabelX = histPaddingLeft + i*histBarStep + histBarStep/2
+	}
+	return stats
+}
+
+// durationHistogram groups sorted durations into equal-width buckets spanning
+// the observed range. The bucket width is a multiple of 5 minutes chosen so
+// that there are at most histMaxBuckets buckets. It also returns the largest
+// bucket count.
+func durationHistogram(sorted []int) ([]DurationBucket, int) {
+	if len(sorted) == 0 {
+		return []DurationBucket{}, 0
+	}
+	lo, hi := sorted[0], sorted[len(sorted)-1]
+	width := 5
+	for (hi/width-lo/width)+1 > histMaxBuckets {
+		width += 5
+	}
+	start := lo / width * width
+
+	buckets := make([]DurationBucket, hi/width-lo/width+1)
+	for i := range buckets {
+		min := start + i*width
+		buckets[i] = DurationBucket{
+			Label:      fmt.Sprintf("%d–%dm", min, min+width),
+			MinMinutes: min,
+			MaxMinutes: min + width,
+		}
+	}
+	maxCount := 0
+	for _, d := range sorted {
+		b := &buckets[(d-start)/width]
+		b.Count++
+		if b.Count > maxCount {
+			maxCount = b.Count
+		}
+	}
+	return buckets, maxCount
+}
+
+// percentile returns the p-th percentile (0–100) of sorted values using
+// linear interpolation between closest ranks. It returns 0 for no values.
+func percentile(sorted []int, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	hi := int(math.Ceil(rank))
+	frac := rank - float64(lo)
+	return float64(sorted[lo]) + frac*float64(sorted[hi]-sorted[lo])
+}
+
+// mean returns the arithmetic mean of values, or 0 for no values.
+func mean(values []int) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sum := 0
+	for _, v := range values {
+		sum += v
+	}
+	return float64(sum) / float64(len(values))
+}
+
+// stdDev returns the population standard deviation of values.
+func stdDev(values []int) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	m := mean(values)
+	var sq float64
+	for _, v := range values {
+		sq += (float64(v) - m) * (float64(v) - m)
+	}
+	return math.Sqrt(sq / float64(len(values)))
+}
+
+// formatMinutes rounds a fractional number of minutes and formats it with
+// formatDuration.
+func formatMinutes(minutes float64) string {
+	return formatDuration(int(math.Round(minutes)))
+}
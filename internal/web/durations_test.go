@@ -0,0 +1,118 @@
+package web
+
+import (
+	"math"
+	"testing"
+
+	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+)
+
+func TestPercentile(t *testing.T) {
+	values := []int{10, 20, 30, 40, 50}
+	tests := []struct {
+		p    float64
+		want float64
+	}{
+		{0, 10},
+		{50, 30},
+		{90, 46},
+		{95, 48},
+		{100, 50},
+	}
+	for _, tt := range tests {
+		if got := percentile(values, tt.p); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("percentile(%v) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+	if got := percentile(nil, 50); got != 0 {
+		t.Errorf("percentile(nil) = %v, want 0", got)
+	}
+}
+
+func TestStdDev(t *testing.T) {
+	if got := stdDev([]int{2, 4, 4, 4, 5, 5, 7, 9}); got != 2 {
+		t.Errorf("stdDev = %v, want 2", got)
+	}
+	if got := stdDev(nil); got != 0 {
+		t.Errorf("stdDev(nil) = %v, want 0", got)
+	}
+}
+
+func TestDurationHistogram(t *testing.T) {
+	buckets, maxCount := durationHistogram([]int{31, 34, 35, 48})
+
+	want := []struct {
+		label string
+		count int
+	}{
+		{"30–35m", 2},
+		{"35–40m", 1},
+		{"40–45m", 0},
+		{"45–50m", 1},
+	}
+	if len(buckets) != len(want) {
+		t.Fatalf("expected %d buckets, got %d", len(want), len(buckets))
+	}
+	for i, w := range want {
+		if buckets[i].Label != w.label || buckets[i].Count != w.count {
+			t.Errorf("bucket %d = %s/%d, want %s/%d", i, buckets[i].Label, buckets[i].Count, w.label, w.count)
+		}
+	}
+	if maxCount != 2 {
+		t.Errorf("maxCount = %d, want 2", maxCount)
+	}
+}
+
+func TestDurationHistogram_WideRange(t *testing.T) {
+	buckets, _ := durationHistogram([]int{10, 400})
+	if len(buckets) > histMaxBuckets {
+		t.Errorf("expected at most %d buckets, got %d", histMaxBuckets, len(buckets))
+	}
+	if width := buckets[0].MaxMinutes - buckets[0].MinMinutes; width%5 != 0 {
+		t.Errorf("bucket width %d is not a multiple of 5", width)
+	}
+}
+
+func TestBuildCommuteData_DurationStats(t *testing.T) {
+	tue, wed, thu := commuteWeekDates()
+
+	journeys := []bq.CommuteJourney{
+		{Date: tue.Format("2006-01-02"), StartTime: "08:00", EndTime: "08:40"},
+		{Date: wed.Format("2006-01-02"), StartTime: "08:00", EndTime: "08:50"},
+		{Date: thu.Format("2006-01-02"), StartTime: "08:00", EndTime: "09:00"},
+	}
+	stats := buildCommuteData(journeys, nil, DateRange{}).Durations
+
+	if stats.Count != 3 {
+		t.Fatalf("Count = %d, want 3", stats.Count)
+	}
+	if stats.P50 != "50m" {
+		t.Errorf("P50 = %q, want %q", stats.P50, "50m")
+	}
+	if stats.P90Minutes != 58 {
+		t.Errorf("P90Minutes = %v, want 58", stats.P90Minutes)
+	}
+	if len(stats.Weekdays) != 3 {
+		t.Fatalf("expected 3 weekday rows, got %d", len(stats.Weekdays))
+	}
+	for i, want := range []string{"40m", "50m", "1h"} {
+		if got := stats.Weekdays[i].Mean; got != want {
+			t.Errorf("%s mean = %q, want %q", stats.Weekdays[i].Weekday, got, want)
+		}
+	}
+	for _, b := range stats.Histogram {
+		if b.Count > 0 && b.Y+b.Height != stats.ChartBottom {
+			t.Errorf("bucket %s does not sit on the x-axis", b.Label)
+		}
+	}
+}
+
+func TestBuildDurationStats_Empty(t *testing.T) {
+	stats := buildDurationStats(nil)
+	if stats.Count != 0 || stats.P50 != "–" || len(stats.Histogram) != 0 {
+		t.Errorf("unexpected stats for no commutes: %+v", stats)
+	}
+	if len(stats.Weekdays) != 3 || stats.Weekdays[0].Mean != "–" {
+		t.Errorf("expected empty rows for each commute weekday, got %+v", stats.Weekdays)
+	}
+}
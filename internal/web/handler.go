@@ -4,13 +4,20 @@ import (
 	"embed"
 	"fmt"
 	"html/template"
+	"image"
 	"log/slog"
 	"net/http"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/its-the-vibe/pearl/internal/annotations"
 	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+	"github.com/its-the-vibe/pearl/internal/calendar"
+	"github.com/its-the-vibe/pearl/internal/stations"
+	"github.com/its-the-vibe/pearl/internal/tags"
 )
 
 //go:embed templates/*.html
@@ -18,25 +25,44 @@ var templateFS embed.FS
 
 // Cell represents a single day cell in the heatmap grid.
 type Cell struct {
-	Empty bool
-	Level int
-	Label string
+	Empty  bool
+	Date   string // ISO date of the day, e.g. "2024-03-05", linking to its day page
+	Level  int
+	Label  string
+	DayOff string // calendar.KindBankHoliday or calendar.KindLeave; empty on other days
+	Buses  int    // bus journeys, shown as a marker on the cell
+	// Annotation summarises the annotations of the day, shown as a marker
+	// on the cell; empty without any.
+	Annotation string
 }
 
 // MonthLabel positions a month name above the heatmap columns.
 type MonthLabel struct {
 	Name   string
+	Month  string // e.g. "2024-03", linking to its calendar page
 	Offset int
 	Width  int
 }
 
 // HeatmapData is passed to the heatmap template.
 type HeatmapData struct {
-	Weeks         [][]Cell
-	MonthLabels   []MonthLabel
-	TotalJourneys int
-	ActiveDays    int
-	BusiestDay    string
+	Weeks          [][]Cell
+	MonthLabels    []MonthLabel
+	TotalJourneys  int
+	ActiveDays     int
+	BusiestDay     string
+	TotalSpend     string // e.g. "£1,234.50"
+	LongestStreak  int    // most consecutive days with travel in the last year
+	CurrentStreak  int    // consecutive days with travel up to today, or yesterday when today has none yet
+	LongestGap     int    // most consecutive days without travel since the first journey of the last year
+	BusiestWeekday string // weekday with the most journeys, e.g. "Tuesday"; "–" without data
+	AvgPerDay      string // average journeys per active day, e.g. "2.4"; "–" without data
+	AnnotatedDays  int    // days with annotations, shown with a marker
+	Tag            string // tag the journeys are filtered by, kept in day links; empty for all
+	CardID         string
+	CardName       string
+	Compare        *HeatmapData // second card shown side by side; nil when not comparing
+	Nav            Nav
 }
 
 // TimeLabel positions a time label on the Y-axis of the commute chart.
@@ -47,16 +73,21 @@ type TimeLabel struct {
 
 // CommutePoint represents a single commute journey rendered in the SVG chart.
 type CommutePoint struct {
-	Date        string // e.g. "Tue 05 Mar" – used as x-axis label
-	ISODate     string // e.g. "2024-03-05" – used to match with ratings
-	Start       string // e.g. "07:45" – used in tooltip
-	End         string // e.g. "09:15" – used in tooltip
-	Duration    string // e.g. "1h 30m" – used in tooltip
-	X           int    // center x of bar in SVG
-	BarX        int    // left edge of bar rect
-	BarY        int    // top y of bar rect (= start time position)
-	BarHeight   int    // height of bar rect
-	BarBottomY  int    // BarY + BarHeight (= end time position)
+	Date         string // e.g. "Tue 05 Mar" – used as x-axis label
+	ISODate      string // e.g. "2024-03-05" – used to match with ratings
+	Start        string // e.g. "07:45" – used in tooltip
+	End          string // e.g. "09:15" – used in tooltip
+	Duration     string // e.g. "1h 30m" – used in tooltip
+	StartMinutes int    // start time in minutes from midnight
+	Minutes      int    // duration in minutes
+	Route        string // journey action, e.g. "Clapham Common to Bank"
+	Anomaly      bool   // duration is unusually long for the route
+	Annotation   string // annotations of the day, shown as a marker; empty without any
+	X            int    // center x of bar in SVG
+	BarX         int    // left edge of bar rect
+	BarY         int    // top y of bar rect (= start time position)
+	BarHeight    int    // height of bar rect
+	BarBottomY   int    // BarY + BarHeight (= end time position)
 }
 
 // RatingPoint represents a single daily rating overlaid on the commute chart.
@@ -78,7 +109,7 @@ type RatingLabel struct {
 // DateRangeOption represents a selectable date range for the commutes chart.
 type DateRangeOption struct {
 	Label    string
-	Days     int  // 0 = all available
+	Days     int // 0 = all available
 	Selected bool
 }
 
@@ -86,6 +117,7 @@ type DateRangeOption struct {
 type CommuteData struct {
 	Commutes         []CommutePoint
 	TimeLabels       []TimeLabel
+	Axis             timeAxis // span of the time axis the commutes are drawn on
 	TotalCommutes    int
 	AvgDuration      string
 	ShortestCommute  string
@@ -102,28 +134,78 @@ type CommuteData struct {
 	RatingPath       string // SVG cubic-bezier path for the smooth ratings line
 	HasRatings       bool
 	DateRangeOptions []DateRangeOption
+	Range            DateRange
+	RangeForm        DateRangeForm
+	Durations        DurationStats
+	Trend            CommuteTrend
+	Anomalies        []CommuteAnomaly // unusually long commutes, most recent first
+	CardID           string
+	CardName         string
+	Compare          *CommuteData // second card shown side by side; nil when not comparing
+	Nav              Nav
+}
+
+// Options holds optional dashboard settings.
+type Options struct {
+	Attendance AttendanceOptions
+	Calendar   *calendar.Calendar  // bank holidays and leave; nil for none
+	Stations   *stations.Directory // station reference data; nil for none
+	// Annotations stores notes and tags on days and journeys; nil
+	// disables them.
+	Annotations annotations.Store
+	// Tags assigns tags to journeys; nil assigns only the default commute
+	// tag.
+	Tags tags.Rules
+	// BaseURL is the public URL of the dashboard, linked from digests;
+	// empty omits the links.
+	BaseURL string
 }
 
 // Handler holds the dependencies for HTTP handlers.
 type Handler struct {
-	bqClient *bq.Client
-	tmpl     *template.Template
+	cards []Card
+	opts  Options
+	tmpl  *template.Template
 }
 
-// NewHandler creates a Handler with the given BigQuery client.
-func NewHandler(client *bq.Client) (*Handler, error) {
+// NewHandler creates a Handler serving the given cards. The first card the
+// requesting user may view is shown by default.
+func NewHandler(cards []Card, opts Options) (*Handler, error) {
+	if len(cards) == 0 {
+		return nil, fmt.Errorf("at least one card is required")
+	}
 	tmpl, err := template.ParseFS(templateFS, "templates/*.html")
 	if err != nil {
 		return nil, fmt.Errorf("parsing templates: %w", err)
 	}
-	return &Handler{bqClient: client, tmpl: tmpl}, nil
+	return &Handler{cards: cards, opts: opts, tmpl: tmpl}, nil
 }
 
 // RegisterRoutes registers all HTTP routes on the given mux.
 func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/", h.handleHeatmap)
+	mux.HandleFunc("/calendar", h.handleCalendarRedirect)
+	mux.HandleFunc("/calendar/{month}", h.handleCalendar)
 	mux.HandleFunc("/commutes", h.handleCommutes)
+	mux.HandleFunc("/punchcard", h.handlePunchcard)
+	mux.HandleFunc("/periods", h.handlePeriods)
+	mux.HandleFunc("/attendance", h.handleAttendance)
+	mux.HandleFunc("/attendance.csv", h.handleAttendanceCSV)
+	mux.HandleFunc("/review", h.handleReviewRedirect)
+	mux.HandleFunc("/review/{year}", h.handleReview)
+	mux.HandleFunc("/buses", h.handleBuses)
+	mux.HandleFunc("/journeys", h.handleJourneys)
+	mux.HandleFunc("/day/{date}", h.handleDay)
+	csrf := http.NewCrossOriginProtection()
+	mux.Handle("POST /day/{date}/annotations", csrf.Handler(http.HandlerFunc(h.handleAddAnnotation)))
+	mux.Handle("POST /day/{date}/annotations/{id}/delete", csrf.Handler(http.HandlerFunc(h.handleDeleteAnnotation)))
+	mux.HandleFunc("/map", h.handleMap)
+	mux.HandleFunc("/station/{name...}", h.handleStation)
+	mux.HandleFunc("/api/commutes", h.handleCommutesAPI)
 	mux.HandleFunc("/health", h.handleHealth)
+	mux.HandleFunc("/admin/usage", h.handleUsage)
+	mux.HandleFunc("/admin/stations", h.handleStationReport)
+	mux.HandleFunc("/admin/digest", h.handleDigestPreview)
 }
 
 func (h *Handler) handleHealth(w http.ResponseWriter, _ *http.Request) {
@@ -137,14 +219,48 @@ func (h *Handler) handleHeatmap(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	counts, err := h.bqClient.JourneyCountsByDay(r.Context())
+	sel, ok := h.selectCards(w, r, "overview", true)
+	if !ok {
+		return
+	}
+
+	ctx := bq.WithPage(r.Context(), "heatmap")
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	load := func(card Card) (HeatmapData, error) {
+		notes := h.cardNotes(ctx, sel, card)
+		filter := h.tagged(notes, sel.Tag, heatmapFilter(today))
+		counts, err := card.Client.JourneyCountsByDay(ctx, filter)
+		if err != nil {
+			return HeatmapData{}, err
+		}
+		buses, err := card.Client.BusJourneys(ctx, filter)
+		if err != nil {
+			// Bus markers are optional; log and continue without them.
+			slog.Warn("querying bigquery for bus journeys", "card", card.ID, "error", err)
+		}
+		data := buildHeatmapData(counts, buses, h.opts.Calendar)
+		markHeatmapAnnotations(&data, newAnnotationIndex(notes))
+		data.Tag = sel.Tag
+		data.CardID, data.CardName = card.ID, card.Name
+		return data, nil
+	}
+
+	data, err := load(sel.Card)
 	if err != nil {
-		slog.Error("querying bigquery", "error", err)
+		slog.Error("querying bigquery", "card", sel.Card.ID, "error", err)
 		http.Error(w, "failed to load journey data", http.StatusInternalServerError)
 		return
 	}
-
-	data := buildHeatmapData(counts)
+	if sel.Compare != nil {
+		compare, err := load(*sel.Compare)
+		if err != nil {
+			slog.Error("querying bigquery", "card", sel.Compare.ID, "error", err)
+			http.Error(w, "failed to load journey data", http.StatusInternalServerError)
+			return
+		}
+		data.Compare = &compare
+	}
+	data.Nav = sel.Nav
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	if err := h.tmpl.ExecuteTemplate(w, "heatmap.html", data); err != nil {
@@ -153,21 +269,70 @@ func (h *Handler) handleHeatmap(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) handleCommutes(w http.ResponseWriter, r *http.Request) {
-	journeys, err := h.bqClient.CommuteJourneys(r.Context())
-	if err != nil {
-		slog.Error("querying bigquery for commutes", "error", err)
-		http.Error(w, "failed to load commute data", http.StatusInternalServerError)
+	sel, ok := h.selectCards(w, r, "commutes", true)
+	if !ok {
 		return
 	}
 
-	ratings, err := h.bqClient.Ratings(r.Context())
+	ctx := bq.WithPage(r.Context(), "commutes")
+	q := r.URL.Query()
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	window := parseWindowParam(q.Get("window"))
+	rng, err := parseDateRange(q, today)
 	if err != nil {
-		// Ratings are optional; log and continue without the overlay.
-		slog.Warn("querying bigquery for ratings", "error", err)
+		// Show the error next to the date picker rather than silently
+		// falling back to another range.
+		data := buildCommuteData(nil, nil, DateRange{Days: customRangeDays})
+		data.Trend = buildCommuteTrend(nil, data.Axis, window)
+		data.RangeForm = DateRangeForm{From: q.Get("from"), To: q.Get("to"), Error: err.Error()}
+		data.Nav = sel.Nav
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusBadRequest)
+		if err := h.tmpl.ExecuteTemplate(w, "commutes.html", data); err != nil {
+			slog.Error("rendering commutes template", "error", err)
+		}
+		return
 	}
 
-	days := parseDaysParam(r.URL.Query().Get("days"))
-	data := buildCommuteData(journeys, ratings, days)
+	load := func(card Card) (CommuteData, error) {
+		notes := h.cardNotes(ctx, sel, card)
+		filter := h.tagged(notes, sel.Tag, h.commuteFilter(notes, rng, today))
+		journeys, err := card.Client.CommuteJourneys(ctx, filter)
+		if err != nil {
+			return CommuteData{}, err
+		}
+		journeys = withoutDaysOff(journeys, h.opts.Calendar)
+
+		ratings, err := card.Client.Ratings(ctx, filter)
+		if err != nil {
+			// Ratings are optional; log and continue without the overlay.
+			slog.Warn("querying bigquery for ratings", "card", card.ID, "error", err)
+		}
+
+		data := buildCommuteData(journeys, ratings, rng)
+		markCommuteAnnotations(data.Commutes, newAnnotationIndex(notes))
+		data.Trend = buildCommuteTrend(data.Commutes, data.Axis, window)
+		data.RangeForm = rng.Form()
+		data.CardID, data.CardName = card.ID, card.Name
+		return data, nil
+	}
+
+	data, err := load(sel.Card)
+	if err != nil {
+		slog.Error("querying bigquery for commutes", "card", sel.Card.ID, "error", err)
+		http.Error(w, "failed to load commute data", http.StatusInternalServerError)
+		return
+	}
+	if sel.Compare != nil {
+		compare, err := load(*sel.Compare)
+		if err != nil {
+			slog.Error("querying bigquery for commutes", "card", sel.Compare.ID, "error", err)
+			http.Error(w, "failed to load commute data", http.StatusInternalServerError)
+			return
+		}
+		data.Compare = &compare
+	}
+	data.Nav = sel.Nav
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	if err := h.tmpl.ExecuteTemplate(w, "commutes.html", data); err != nil {
@@ -176,25 +341,61 @@ func (h *Handler) handleCommutes(w http.ResponseWriter, r *http.Request) {
 }
 
 // parseDaysParam parses the "days" URL query parameter into a number of days.
-// Accepted values are "7", "30", "60", "90", and "0" (all available).
-// Any other value (including empty) returns the default of 30.
-func parseDaysParam(s string) int {
+// Accepted values are "7", "30", "60", "90", and "0" (all available). An empty
+// value returns the default of 30; any other value is an error.
+func parseDaysParam(s string) (int, error) {
 	switch s {
 	case "7":
-		return 7
-	case "30":
-		return 30
+		return 7, nil
+	case "", "30":
+		return 30, nil // last 30 days is the default
 	case "60":
-		return 60
+		return 60, nil
 	case "90":
-		return 90
+		return 90, nil
 	case "0":
-		return 0
+		return 0, nil
 	default:
-		return 30 // last 30 days is the default
+		return 0, fmt.Errorf("invalid date range %q: choose 7, 30, 60, 90 or all available", s)
 	}
 }
 
+// heatmapFilter returns the journey filter covering every day drawn on the
+// heatmap or counted in its stats, relative to today.
+func heatmapFilter(today time.Time) bq.JourneyFilter {
+	return bq.JourneyFilter{From: today.AddDate(-1, 0, 0)}
+}
+
+// withoutDaysOff returns the journeys that do not fall on a bank holiday or
+// leave day in cal, so that travel on days off does not count as commuting.
+func withoutDaysOff(journeys []bq.CommuteJourney, cal *calendar.Calendar) []bq.CommuteJourney {
+	if cal.Len() == 0 {
+		return journeys
+	}
+	kept := make([]bq.CommuteJourney, 0, len(journeys))
+	for _, j := range journeys {
+		if t, err := parseJourneyDate(j.Date); err == nil {
+			if _, off := cal.Lookup(t); off {
+				continue
+			}
+		}
+		kept = append(kept, j)
+	}
+	return kept
+}
+
+// dayOffName describes a day off for tooltips, such as "Christmas Day" or
+// "leave: Summer holiday".
+func dayOffName(off calendar.DayOff) string {
+	if off.Kind == calendar.KindBankHoliday {
+		return off.Name
+	}
+	if off.Name == "" {
+		return "leave"
+	}
+	return "leave: " + off.Name
+}
+
 // buildDateRangeOptions returns the list of date range options with the
 // selected flag set on whichever option matches selectedDays.
 func buildDateRangeOptions(selectedDays int) []DateRangeOption {
@@ -213,18 +414,19 @@ func buildDateRangeOptions(selectedDays int) []DateRangeOption {
 
 // buildHeatmapData converts raw day counts into a grid suitable for the heatmap template.
 // It renders the last 52 weeks (364 days), anchored to the most recent Sunday.
-func buildHeatmapData(counts []bq.DayCount) HeatmapData {
-	// Build a lookup map of date string → count.
-	lookup := make(map[string]int, len(counts))
-	for _, dc := range counts {
-		lookup[dc.Date.Format("2006-01-02")] = dc.Count
-	}
-
-	// Determine max count for intensity scaling.
-	maxCount := 0
-	for _, c := range counts {
-		if c.Count > maxCount {
-			maxCount = c.Count
+// Days with bus journeys and bank holidays and leave days in cal are marked
+// on the grid.
+func buildHeatmapData(counts []bq.DayCount, buses []bq.BusJourney, cal *calendar.Calendar) HeatmapData {
+	lookup, maxCount := dayLookup(counts)
+	busRoutes := make(map[string][]string)
+	for _, b := range buses {
+		t, err := parseJourneyDate(b.Date)
+		if err != nil {
+			continue
+		}
+		if route, ok := busRoute(b.JourneyAction); ok {
+			key := t.Format("2006-01-02")
+			busRoutes[key] = append(busRoutes[key], route)
 		}
 	}
 
@@ -248,11 +450,25 @@ func buildHeatmapData(counts []bq.DayCount) HeatmapData {
 				continue
 			}
 			key := day.Format("2006-01-02")
-			count := lookup[key]
-			weeks[w][d] = Cell{
-				Level: intensityLevel(count, maxCount),
-				Label: fmt.Sprintf("%s: %d journeys", day.Format("02 Jan 2006"), count),
+			dc := lookup[key]
+			label := fmt.Sprintf("%s: %d journeys", day.Format("02 Jan 2006"), dc.Count)
+			if routes := busRoutes[key]; len(routes) > 0 {
+				label += fmt.Sprintf(" (%d by bus: %s)", len(routes), strings.Join(slices.Compact(slices.Sorted(slices.Values(routes))), ", "))
+			}
+			if dc.Spend > 0 {
+				label += ", " + formatPounds(dc.Spend)
+			}
+			cell := Cell{
+				Date:  key,
+				Level: intensityLevel(dc.Count, maxCount),
+				Label: label,
+				Buses: len(busRoutes[key]),
+			}
+			if off, ok := cal.Lookup(day); ok {
+				cell.DayOff = off.Kind
+				cell.Label += " – " + dayOffName(off)
 			}
+			weeks[w][d] = cell
 		}
 	}
 
@@ -261,6 +477,7 @@ func buildHeatmapData(counts []bq.DayCount) HeatmapData {
 
 	// Compute stats over the last year.
 	var totalJourneys, activeDays int
+	var totalSpend float64
 	var busiestDate string
 	busiestCount := 0
 	oneYearAgo := today.AddDate(-1, 0, 0)
@@ -269,6 +486,7 @@ func buildHeatmapData(counts []bq.DayCount) HeatmapData {
 			continue
 		}
 		totalJourneys += dc.Count
+		totalSpend += dc.Spend
 		activeDays++
 		if dc.Count > busiestCount {
 			busiestCount = dc.Count
@@ -279,12 +497,65 @@ func buildHeatmapData(counts []bq.DayCount) HeatmapData {
 		busiestDate = "–"
 	}
 
+	// Walk the year day by day for streaks, gaps and weekday totals.
+	var streak, longestStreak, gap, longestGap int
+	var weekdayJourneys [7]int
+	travelled := false
+	for day := oneYearAgo; !day.After(today); day = day.AddDate(0, 0, 1) {
+		count := lookup[day.Format("2006-01-02")].Count
+		weekdayJourneys[day.Weekday()] += count
+		if count > 0 {
+			travelled = true
+			streak++
+			gap = 0
+			longestStreak = max(longestStreak, streak)
+			continue
+		}
+		streak = 0
+		if travelled && day.Before(today) {
+			// Gaps before the first journey are missing history, not
+			// time off; today may still see travel.
+			gap++
+			longestGap = max(longestGap, gap)
+		}
+	}
+	currentStreak := 0
+	day := today
+	if lookup[day.Format("2006-01-02")].Count == 0 {
+		day = day.AddDate(0, 0, -1)
+	}
+	for ; !day.Before(oneYearAgo) && lookup[day.Format("2006-01-02")].Count > 0; day = day.AddDate(0, 0, -1) {
+		currentStreak++
+	}
+
+	busiestWeekday, avgPerDay := "–", "–"
+	if totalJourneys > 0 {
+		// Ties go to the earlier day of the working week.
+		best := time.Monday
+		for i := 1; i < 7; i++ {
+			wd := (time.Monday + time.Weekday(i)) % 7
+			if weekdayJourneys[wd] > weekdayJourneys[best] {
+				best = wd
+			}
+		}
+		busiestWeekday = best.String()
+	}
+	if activeDays > 0 {
+		avgPerDay = fmt.Sprintf("%.1f", float64(totalJourneys)/float64(activeDays))
+	}
+
 	return HeatmapData{
-		Weeks:         weeks,
-		MonthLabels:   monthLabels,
-		TotalJourneys: totalJourneys,
-		ActiveDays:    activeDays,
-		BusiestDay:    busiestDate,
+		Weeks:          weeks,
+		MonthLabels:    monthLabels,
+		TotalJourneys:  totalJourneys,
+		ActiveDays:     activeDays,
+		BusiestDay:     busiestDate,
+		TotalSpend:     formatPounds(totalSpend),
+		LongestStreak:  longestStreak,
+		CurrentStreak:  currentStreak,
+		LongestGap:     longestGap,
+		BusiestWeekday: busiestWeekday,
+		AvgPerDay:      avgPerDay,
 	}
 }
 
@@ -306,6 +577,18 @@ func intensityLevel(count, max int) int {
 	}
 }
 
+// dayLookup indexes counts by ISO date and returns the largest count, for
+// intensity scaling. It is shared by the heatmap and the month calendar.
+func dayLookup(counts []bq.DayCount) (map[string]bq.DayCount, int) {
+	lookup := make(map[string]bq.DayCount, len(counts))
+	maxCount := 0
+	for _, dc := range counts {
+		lookup[dc.Date.Format("2006-01-02")] = dc
+		maxCount = max(maxCount, dc.Count)
+	}
+	return lookup, maxCount
+}
+
 // buildMonthLabels returns labels positioned above the week columns.
 func buildMonthLabels(startSunday time.Time, numWeeks int) []MonthLabel {
 	const cellWidth = 13 // 11px cell + 2px gap
@@ -327,6 +610,7 @@ func buildMonthLabels(startSunday time.Time, numWeeks int) []MonthLabel {
 			}
 			labels = append(labels, MonthLabel{
 				Name:   day.Format("Jan"),
+				Month:  day.Format("2006-01"),
 				Offset: offset,
 			})
 			if len(labels) > 1 {
@@ -354,24 +638,39 @@ const (
 	svgBarStep       = 50 // horizontal distance between bar centres
 	svgBarHalfWidth  = 8  // half the width of each range bar
 
-	// Commute time window – 7:00 to 10:30.
-	svgMinMinutes   = 7*60 + 0
-	svgMaxMinutes   = 10*60 + 30
-	svgMinutesRange = svgMaxMinutes - svgMinMinutes // 210
-	svgChartHeight  = svgPaddingTop + svgPlotHeight + svgPaddingBottom
-)
+	svgChartHeight = svgPaddingTop + svgPlotHeight + svgPaddingBottom
 
-// commuteMinWindowMinutes and commuteMaxWindowMinutes define the start-time
-// window used to decide whether a journey qualifies as a commute.
-const (
-	commuteMinWindowMinutes = svgMinMinutes
-	commuteMaxWindowMinutes = svgMaxMinutes
+	// svgAxisStep is the interval between time labels, to which the time
+	// axis is rounded.
+	svgAxisStep = 30
 )
 
-// minutesToSVGY maps a time (in minutes from midnight) to a Y coordinate in
-// the SVG chart. Earlier times appear at the top (smaller Y).
-func minutesToSVGY(minutes int) int {
-	return svgPaddingTop + (minutes-svgMinMinutes)*svgPlotHeight/svgMinutesRange
+// timeAxis is the span of the commute chart's time axis, in minutes from
+// midnight.
+type timeAxis struct {
+	Min, Max int
+}
+
+// defaultTimeAxis is the time axis of a chart whose commutes fit between 7:00
+// and 10:30.
+var defaultTimeAxis = timeAxis{Min: 7 * 60, Max: 10*60 + 30}
+
+// fitTimeAxis widens the default time axis to the half hours around the
+// start and end times of points.
+func fitTimeAxis(points []CommutePoint) timeAxis {
+	axis := defaultTimeAxis
+	for _, p := range points {
+		axis.Min = min(axis.Min, p.StartMinutes/svgAxisStep*svgAxisStep)
+		end := p.StartMinutes + p.Minutes
+		axis.Max = max(axis.Max, (end+svgAxisStep-1)/svgAxisStep*svgAxisStep)
+	}
+	return axis
+}
+
+// y maps a time (in minutes from midnight) to a Y coordinate in the SVG
+// chart. Earlier times appear at the top (smaller Y).
+func (a timeAxis) y(minutes int) int {
+	return svgPaddingTop + (minutes-a.Min)*svgPlotHeight/(a.Max-a.Min)
 }
 
 // ratingToSVGY maps a rating value (1–5) to a Y coordinate in the SVG chart,
@@ -382,10 +681,21 @@ func ratingToSVGY(rating float64) int {
 }
 
 // smoothRatingPath converts a slice of rating points into an SVG cubic-bezier
-// path string that produces a smooth curve through all the data points using
-// the Catmull-Rom → cubic Bézier conversion.
+// path string that produces a smooth curve through all the data points.
 // Returns an empty string when fewer than 2 points are provided.
 func smoothRatingPath(pts []RatingPoint) string {
+	points := make([]image.Point, len(pts))
+	for i, p := range pts {
+		points[i] = image.Pt(p.X, p.Y)
+	}
+	return smoothPath(points)
+}
+
+// smoothPath converts a slice of points into an SVG cubic-bezier path string
+// that produces a smooth curve through all of them using the Catmull-Rom →
+// cubic Bézier conversion.
+// Returns an empty string when fewer than 2 points are provided.
+func smoothPath(pts []image.Point) string {
 	if len(pts) < 2 {
 		return ""
 	}
@@ -426,24 +736,22 @@ func smoothRatingPath(pts []RatingPoint) string {
 	return sb.String()
 }
 
-// buildCommuteData filters journeys to commute candidates (Tue/Wed/Thu,
-// 7:00–10:30 start time) and computes all values needed by the template.
+// buildCommuteData lays out journeys tagged as commutes on the chart and
+// computes all values needed by the template. The chart's time axis spans
+// 7:00 to 10:30, widened to fit commutes outside it.
 // ratings is optional; pass nil to omit the ratings overlay.
-// days limits results to the last N days; 0 means all available data.
-func buildCommuteData(journeys []bq.CommuteJourney, ratings []bq.DailyRating, days int) CommuteData {
+// rng limits results to a date range; the zero value means all available data.
+func buildCommuteData(journeys []bq.CommuteJourney, ratings []bq.DailyRating, rng DateRange) CommuteData {
 	// Apply date range filter when a limit is requested.
-	if days > 0 {
-		cutoff := time.Now().UTC().Truncate(24 * time.Hour).AddDate(0, 0, -days)
+	if rng.Days != 0 {
+		today := time.Now().UTC().Truncate(24 * time.Hour)
 		var filteredJourneys []bq.CommuteJourney
 		for _, j := range journeys {
-			t, err := time.Parse("02-Jan-06", j.Date)
+			t, err := parseJourneyDate(j.Date)
 			if err != nil {
-				t, err = time.Parse("2006-01-02", j.Date)
-				if err != nil {
-					continue
-				}
+				continue
 			}
-			if !t.Before(cutoff) {
+			if rng.contains(t, today) {
 				filteredJourneys = append(filteredJourneys, j)
 			}
 		}
@@ -451,7 +759,7 @@ func buildCommuteData(journeys []bq.CommuteJourney, ratings []bq.DailyRating, da
 
 		var filteredRatings []bq.DailyRating
 		for _, r := range ratings {
-			if !r.Date.Before(cutoff) {
+			if rng.contains(r.Date, today) {
 				filteredRatings = append(filteredRatings, r)
 			}
 		}
@@ -466,29 +774,15 @@ func buildCommuteData(journeys []bq.CommuteJourney, ratings []bq.DailyRating, da
 	shortestCommute := ""
 
 	for _, j := range journeys {
-		// Parse the date using the two formats supported by the app.
-		t, err := time.Parse("02-Jan-06", j.Date)
+		t, err := parseJourneyDate(j.Date)
 		if err != nil {
-			t, err = time.Parse("2006-01-02", j.Date)
-			if err != nil {
-				continue
-			}
-		}
-
-		// Keep only Tuesday, Wednesday, Thursday.
-		wd := t.Weekday()
-		if wd != time.Tuesday && wd != time.Wednesday && wd != time.Thursday {
 			continue
 		}
 
-		// Parse start time and apply the 7:00–10:30 window filter.
 		startMins, err := parseTimeToMinutes(j.StartTime)
 		if err != nil {
 			continue
 		}
-		if startMins < commuteMinWindowMinutes || startMins > commuteMaxWindowMinutes {
-			continue
-		}
 
 		// Parse end time; skip journeys without a valid end time.
 		endMins, err := parseTimeToMinutes(j.EndTime)
@@ -512,26 +806,38 @@ func buildCommuteData(journeys []bq.CommuteJourney, ratings []bq.DailyRating, da
 		}
 		totalMinutes += duration
 
-		// SVG bar geometry.
-		idx := len(points)
-		x := svgPaddingLeft + idx*svgBarStep + svgBarStep/2
-		barY := minutesToSVGY(startMins)
-		barHeight := minutesToSVGY(endMins) - barY
-
+		// Bar x positions are assigned below once all dates are known.
 		points = append(points, CommutePoint{
-			Date:       t.Format("Mon 02 Jan"),
-			ISODate:    t.Format("2006-01-02"),
-			Start:      j.StartTime,
-			End:        j.EndTime,
-			Duration:   durationStr,
-			X:          x,
-			BarX:       x - svgBarHalfWidth,
-			BarY:       barY,
-			BarHeight:  barHeight,
-			BarBottomY: barY + barHeight,
+			Date:         t.Format("Mon 02 Jan"),
+			ISODate:      t.Format("2006-01-02"),
+			Start:        j.StartTime,
+			End:          j.EndTime,
+			Duration:     durationStr,
+			StartMinutes: startMins,
+			Minutes:      duration,
+			Route:        strings.TrimSpace(j.JourneyAction),
 		})
 	}
 
+	// Journeys come sorted by the raw date column, which orders Oyster
+	// dates such as "01-Mar-24" before "02-Feb-24"; put them in date order.
+	sort.SliceStable(points, func(a, b int) bool {
+		if points[a].ISODate != points[b].ISODate {
+			return points[a].ISODate < points[b].ISODate
+		}
+		return points[a].StartMinutes < points[b].StartMinutes
+	})
+
+	axis := fitTimeAxis(points)
+	for i := range points {
+		p := &points[i]
+		p.BarY = axis.y(p.StartMinutes)
+		p.BarBottomY = axis.y(p.StartMinutes + p.Minutes)
+		p.BarHeight = p.BarBottomY - p.BarY
+	}
+
+	anomalies := detectAnomalies(points)
+
 	avgDuration := "–"
 	if len(points) > 0 {
 		avgDuration = formatDuration(totalMinutes / len(points))
@@ -543,11 +849,15 @@ func buildCommuteData(journeys []bq.CommuteJourney, ratings []bq.DailyRating, da
 		shortestCommute = "–"
 	}
 
-	// Build Y-axis time labels every 30 minutes from 7:00 to 10:30.
+	// Build Y-axis time labels every 30 minutes, thinned out on wide axes.
+	step := svgAxisStep
+	for (axis.Max-axis.Min)/step > 12 {
+		step *= 2
+	}
 	var timeLabels []TimeLabel
-	for mins := svgMinMinutes; mins <= svgMaxMinutes; mins += 30 {
+	for mins := axis.Min; mins <= axis.Max; mins += step {
 		timeLabels = append(timeLabels, TimeLabel{
-			Y:     minutesToSVGY(mins),
+			Y:     axis.y(mins),
 			Label: fmt.Sprintf("%02d:%02d", mins/60, mins%60),
 		})
 	}
@@ -635,6 +945,7 @@ func buildCommuteData(journeys []bq.CommuteJourney, ratings []bq.DailyRating, da
 	return CommuteData{
 		Commutes:         points,
 		TimeLabels:       timeLabels,
+		Axis:             axis,
 		TotalCommutes:    len(points),
 		AvgDuration:      avgDuration,
 		ShortestCommute:  shortestCommute,
@@ -650,7 +961,10 @@ func buildCommuteData(journeys []bq.CommuteJourney, ratings []bq.DailyRating, da
 		RatingLabels:     ratingLabels,
 		RatingPath:       smoothRatingPath(ratingPoints),
 		HasRatings:       len(ratingPoints) > 0,
-		DateRangeOptions: buildDateRangeOptions(days),
+		DateRangeOptions: buildDateRangeOptions(rng.Days),
+		Range:            rng,
+		Durations:        buildDurationStats(points),
+		Anomalies:        anomalies,
 	}
 }
 
@@ -667,6 +981,16 @@ func parseTimeToMinutes(s string) (int, error) {
 	return h*60 + m, nil
 }
 
+// parseJourneyDate parses a journey date in either of the formats stored in
+// the journeys table: "02-Jan-06" from Oyster CSV exports or an ISO date.
+func parseJourneyDate(s string) (time.Time, error) {
+	t, err := time.Parse("02-Jan-06", s)
+	if err != nil {
+		t, err = time.Parse("2006-01-02", s)
+	}
+	return t, err
+}
+
 // formatDuration converts a number of minutes into a human-readable string
 // such as "45m" or "1h 30m".
 func formatDuration(minutes int) string {
@@ -683,3 +1007,19 @@ func formatDuration(minutes int) string {
 	}
 	return fmt.Sprintf("%dh %dm", h, m)
 }
+
+// formatPounds formats an amount in pounds with a thousands separator, such
+// as "£7.20" or "£1,234.50".
+func formatPounds(amount float64) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	pence := int64(amount*100 + 0.5)
+	whole := strconv.FormatInt(pence/100, 10)
+	for i := len(whole) - 3; i > 0; i -= 3 {
+		whole = whole[:i] + "," + whole[i:]
+	}
+	return fmt.Sprintf("%s£%s.%02d", sign, whole, pence%100)
+}
@@ -6,10 +6,12 @@ import (
 	"time"
 
 	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+	"github.com/its-the-vibe/pearl/internal/calendar"
+	"github.com/its-the-vibe/pearl/internal/tags"
 )
 
 func TestBuildHeatmapData_Empty(t *testing.T) {
-	data := buildHeatmapData(nil)
+	data := buildHeatmapData(nil, nil, nil)
 
 	if len(data.Weeks) != 53 {
 		t.Errorf("expected 53 weeks, got %d", len(data.Weeks))
@@ -35,11 +37,11 @@ func TestBuildHeatmapData_WithCounts(t *testing.T) {
 	yesterday := today.AddDate(0, 0, -1)
 
 	counts := []bq.DayCount{
-		{Date: yesterday, Count: 3},
-		{Date: today, Count: 5},
+		{Date: yesterday, Count: 3, Spend: 5.6},
+		{Date: today, Count: 5, Spend: 8.1},
 	}
 
-	data := buildHeatmapData(counts)
+	data := buildHeatmapData(counts, nil, nil)
 
 	if data.TotalJourneys != 8 {
 		t.Errorf("expected 8 total journeys, got %d", data.TotalJourneys)
@@ -47,6 +49,141 @@ func TestBuildHeatmapData_WithCounts(t *testing.T) {
 	if data.ActiveDays != 2 {
 		t.Errorf("expected 2 active days, got %d", data.ActiveDays)
 	}
+	if data.TotalSpend != "£13.70" {
+		t.Errorf("TotalSpend = %q, want %q", data.TotalSpend, "£13.70")
+	}
+}
+
+func TestBuildHeatmapData_Streaks(t *testing.T) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	day := func(n int) time.Time { return today.AddDate(0, 0, -n) }
+
+	// Travel 20–16 days ago (a streak of 5), a 10-day gap, then 5 days ago
+	// up to yesterday. Today has no travel yet.
+	var counts []bq.DayCount
+	for n := 20; n >= 16; n-- {
+		counts = append(counts, bq.DayCount{Date: day(n), Count: 2})
+	}
+	for n := 5; n >= 1; n-- {
+		counts = append(counts, bq.DayCount{Date: day(n), Count: 1})
+	}
+	counts[0].Count = 10 // make one weekday clearly the busiest
+
+	data := buildHeatmapData(counts, nil, nil)
+
+	if data.LongestStreak != 5 {
+		t.Errorf("LongestStreak = %d, want 5", data.LongestStreak)
+	}
+	if data.CurrentStreak != 5 {
+		t.Errorf("CurrentStreak = %d, want 5 (today has no travel yet)", data.CurrentStreak)
+	}
+	if data.LongestGap != 10 {
+		t.Errorf("LongestGap = %d, want 10", data.LongestGap)
+	}
+	if want := day(20).Weekday().String(); data.BusiestWeekday != want {
+		t.Errorf("BusiestWeekday = %q, want %q", data.BusiestWeekday, want)
+	}
+	// 23 journeys over 10 active days.
+	if data.AvgPerDay != "2.3" {
+		t.Errorf("AvgPerDay = %q, want %q", data.AvgPerDay, "2.3")
+	}
+
+	empty := buildHeatmapData(nil, nil, nil)
+	if empty.CurrentStreak != 0 || empty.LongestGap != 0 || empty.BusiestWeekday != "–" || empty.AvgPerDay != "–" {
+		t.Errorf("empty stats = %d, %d, %q, %q", empty.CurrentStreak, empty.LongestGap, empty.BusiestWeekday, empty.AvgPerDay)
+	}
+}
+
+func TestBuildHeatmapData_DaysOff(t *testing.T) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	cal := calendar.New(
+		[]calendar.DayOff{{Date: today, Kind: calendar.KindBankHoliday, Name: "Christmas Day"}},
+		[]calendar.DayOff{{Date: today.AddDate(0, 0, -1), Kind: calendar.KindLeave}},
+	)
+	data := buildHeatmapData(nil, nil, cal)
+
+	var found []Cell
+	for _, week := range data.Weeks {
+		for _, c := range week {
+			if c.DayOff != "" {
+				found = append(found, c)
+			}
+		}
+	}
+	if len(found) != 2 {
+		t.Fatalf("expected 2 days off, got %d", len(found))
+	}
+	if found[0].DayOff != calendar.KindLeave || !strings.HasSuffix(found[0].Label, "– leave") {
+		t.Errorf("yesterday = %+v, want leave", found[0])
+	}
+	if found[1].DayOff != calendar.KindBankHoliday || !strings.HasSuffix(found[1].Label, "– Christmas Day") {
+		t.Errorf("today = %+v, want the bank holiday", found[1])
+	}
+}
+
+func TestBuildHeatmapData_Buses(t *testing.T) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	key := today.Format("2006-01-02")
+	counts := []bq.DayCount{{Date: today, Count: 4, Spend: 5.6}}
+	buses := []bq.BusJourney{
+		{Date: key, StartTime: "08:00", JourneyAction: "Bus journey, route 88", Charge: 1.75},
+		{Date: key, StartTime: "08:30", JourneyAction: "Bus journey, route 155"},
+		{Date: key, StartTime: "18:00", JourneyAction: "Bus journey, route 88", Charge: 1.75},
+	}
+	data := buildHeatmapData(counts, buses, nil)
+
+	var cell Cell
+	for _, week := range data.Weeks {
+		for _, c := range week {
+			if c.Buses > 0 {
+				cell = c
+			}
+		}
+	}
+	if cell.Buses != 3 {
+		t.Fatalf("Buses = %d, want 3", cell.Buses)
+	}
+	if want := "4 journeys (3 by bus: 155, 88), £5.60"; !strings.HasSuffix(cell.Label, want) {
+		t.Errorf("Label = %q, want suffix %q", cell.Label, want)
+	}
+}
+
+func TestWithoutDaysOff(t *testing.T) {
+	journeys := []bq.CommuteJourney{
+		{Date: "2024-12-24", StartTime: "08:00", EndTime: "08:45"},
+		{Date: "25-Dec-24", StartTime: "08:00", EndTime: "08:45"},
+		{Date: "2024-12-27", StartTime: "08:00", EndTime: "08:45"},
+	}
+	cal := calendar.New(
+		[]calendar.DayOff{{Date: mustDate("2024-12-25"), Kind: calendar.KindBankHoliday}},
+		[]calendar.DayOff{{Date: mustDate("2024-12-27"), Kind: calendar.KindLeave}},
+	)
+	got := withoutDaysOff(journeys, cal)
+	if len(got) != 1 || got[0].Date != "2024-12-24" {
+		t.Errorf("withoutDaysOff() = %+v, want only 24 Dec", got)
+	}
+	if got := withoutDaysOff(journeys, nil); len(got) != 3 {
+		t.Errorf("withoutDaysOff(nil calendar) returned %d journeys, want 3", len(got))
+	}
+}
+
+func TestFormatPounds(t *testing.T) {
+	tests := []struct {
+		amount float64
+		want   string
+	}{
+		{0, "£0.00"},
+		{2.8, "£2.80"},
+		{7.199999, "£7.20"},
+		{1234.5, "£1,234.50"},
+		{1234567, "£1,234,567.00"},
+		{-3.1, "-£3.10"},
+	}
+	for _, tt := range tests {
+		if got := formatPounds(tt.amount); got != tt.want {
+			t.Errorf("formatPounds(%v) = %q, want %q", tt.amount, got, tt.want)
+		}
+	}
 }
 
 func TestBuildMonthLabels_OffsetAndWidth(t *testing.T) {
@@ -62,6 +199,10 @@ func TestBuildMonthLabels_OffsetAndWidth(t *testing.T) {
 		t.Fatalf("expected at least 2 month labels, got %d", len(labels))
 	}
 
+	if labels[0].Month != "2024-01" || labels[1].Month != "2024-02" {
+		t.Errorf("label months = %q, %q, want 2024-01, 2024-02", labels[0].Month, labels[1].Month)
+	}
+
 	// The first label should have a non-negative offset (its absolute position).
 	if labels[0].Offset < 0 {
 		t.Errorf("first label Offset should be >= 0, got %d", labels[0].Offset)
@@ -145,6 +286,29 @@ func TestParseTimeToMinutes(t *testing.T) {
 	}
 }
 
+func TestParseJourneyDate(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"05-Mar-24", "2024-03-05", false},
+		{"2024-03-05", "2024-03-05", false},
+		{"5 March 2024", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		got, err := parseJourneyDate(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseJourneyDate(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got.Format("2006-01-02") != tt.want {
+			t.Errorf("parseJourneyDate(%q) = %s, want %s", tt.input, got.Format("2006-01-02"), tt.want)
+		}
+	}
+}
+
 func TestFormatDuration(t *testing.T) {
 	tests := []struct {
 		minutes int
@@ -166,7 +330,7 @@ func TestFormatDuration(t *testing.T) {
 }
 
 func TestBuildCommuteData_Empty(t *testing.T) {
-	data := buildCommuteData(nil, nil, 0)
+	data := buildCommuteData(nil, nil, DateRange{})
 
 	if data.TotalCommutes != 0 {
 		t.Errorf("expected 0 commutes, got %d", data.TotalCommutes)
@@ -193,9 +357,10 @@ func commuteWeekDates() (tue, wed, thu time.Time) {
 	return mon.AddDate(0, 0, 1), mon.AddDate(0, 0, 2), mon.AddDate(0, 0, 3)
 }
 
-func TestBuildCommuteData_FiltersDaysOfWeek(t *testing.T) {
+func TestBuildCommuteData_KeepsAllDaysOfWeek(t *testing.T) {
 	tue, wed, thu := commuteWeekDates()
-	// 2024-01-01 (Monday) and 2024-01-06 (Saturday) should be excluded.
+	// Commute weekdays are chosen by the commute tag rules in the query, so
+	// 2024-01-01 (Monday) and 2024-01-06 (Saturday) are plotted too.
 	mon := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
 	sat := mon.AddDate(0, 0, 5)
 
@@ -207,31 +372,61 @@ func TestBuildCommuteData_FiltersDaysOfWeek(t *testing.T) {
 		{Date: sat.Format("2006-01-02"), StartTime: "08:00", EndTime: "09:00"},
 	}
 
-	data := buildCommuteData(journeys, nil, 0)
-	if data.TotalCommutes != 3 {
-		t.Errorf("expected 3 commutes (Tue/Wed/Thu only), got %d", data.TotalCommutes)
+	data := buildCommuteData(journeys, nil, DateRange{})
+	if data.TotalCommutes != 5 {
+		t.Errorf("expected 5 commutes, got %d", data.TotalCommutes)
 	}
 }
 
-func TestBuildCommuteData_FiltersTimeWindow(t *testing.T) {
+func TestBuildCommuteData_FitsTimeAxis(t *testing.T) {
 	_, wed, _ := commuteWeekDates()
+	day := wed.Format("2006-01-02")
+
+	// Within the default 07:00–10:30 axis.
+	data := buildCommuteData([]bq.CommuteJourney{{Date: day, StartTime: "07:00", EndTime: "08:00"}}, nil, DateRange{})
+	if data.Axis != defaultTimeAxis || len(data.TimeLabels) != 8 {
+		t.Errorf("Axis = %+v with %d labels, want %+v with 8", data.Axis, len(data.TimeLabels), defaultTimeAxis)
+	}
 
+	// Commutes outside it are kept and widen the axis to the half hour.
 	journeys := []bq.CommuteJourney{
-		// Too early (before 07:00).
-		{Date: wed.Format("2006-01-02"), StartTime: "06:50", EndTime: "07:45"},
-		// Exactly on the lower boundary – included.
-		{Date: wed.Format("2006-01-02"), StartTime: "07:00", EndTime: "08:00"},
-		// Mid-window – included.
-		{Date: wed.Format("2006-01-02"), StartTime: "09:00", EndTime: "10:00"},
-		// Exactly on the upper boundary – included.
-		{Date: wed.Format("2006-01-02"), StartTime: "10:30", EndTime: "11:00"},
-		// After window (start after 10:30) – excluded.
-		{Date: wed.Format("2006-01-02"), StartTime: "10:31", EndTime: "11:30"},
-	}
-
-	data := buildCommuteData(journeys, nil, 0)
-	if data.TotalCommutes != 3 {
-		t.Errorf("expected 3 commutes within time window, got %d", data.TotalCommutes)
+		{Date: day, StartTime: "06:50", EndTime: "07:45"},
+		{Date: day, StartTime: "17:40", EndTime: "18:35"},
+	}
+	data = buildCommuteData(journeys, nil, DateRange{})
+	if data.TotalCommutes != 2 {
+		t.Fatalf("expected both commutes, got %d", data.TotalCommutes)
+	}
+	if want := (timeAxis{Min: 6*60 + 30, Max: 19 * 60}); data.Axis != want {
+		t.Errorf("Axis = %+v, want %+v", data.Axis, want)
+	}
+	first, last := data.Commutes[0], data.Commutes[1]
+	if first.BarY < data.ChartTop || last.BarBottomY > data.ChartBottom {
+		t.Errorf("bars %+v and %+v fall outside the plot %d–%d", first, last, data.ChartTop, data.ChartBottom)
+	}
+	if n := len(data.TimeLabels); n > 13 || data.TimeLabels[0].Label != "06:30" {
+		t.Errorf("TimeLabels = %+v, want at most 13 starting at 06:30", data.TimeLabels)
+	}
+}
+
+func TestCommuteFilter_ConfiguredRule(t *testing.T) {
+	today := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	evening := tags.Rule{Tag: tags.Commute, From: "17:00", To: "19:00"}
+	h := &Handler{opts: Options{Tags: tags.Rules{evening}}}
+
+	f := h.commuteFilter(nil, DateRange{Days: 30}, today)
+	if len(f.Tags) != 1 || len(f.Tags[0].Rules) != 1 || f.Tags[0].Rules[0].From != "17:00" {
+		t.Errorf("expected only the configured commute rule, got %+v", f.Tags)
+	}
+
+	// The journeys the rule selects are all charted.
+	journeys := []bq.CommuteJourney{
+		{Date: "2024-03-04", StartTime: "17:15", EndTime: "17:55"},
+		{Date: "2024-03-05", StartTime: "18:30", EndTime: "19:20"},
+	}
+	data := buildCommuteData(journeys, nil, DateRange{})
+	if data.TotalCommutes != 2 || data.Axis.Max != 19*60+30 {
+		t.Errorf("got %d commutes on axis %+v, want 2 up to 19:30", data.TotalCommutes, data.Axis)
 	}
 }
 
@@ -245,7 +440,7 @@ func TestBuildCommuteData_ComputesDuration(t *testing.T) {
 		{Date: thu.Format("2006-01-02"), StartTime: "07:30", EndTime: "09:00"},
 	}
 
-	data := buildCommuteData(journeys, nil, 0)
+	data := buildCommuteData(journeys, nil, DateRange{})
 	if data.TotalCommutes != 2 {
 		t.Fatalf("expected 2 commutes, got %d", data.TotalCommutes)
 	}
@@ -269,7 +464,7 @@ func TestBuildCommuteData_SVGGeometry(t *testing.T) {
 		{Date: tue.Format("2006-01-02"), StartTime: "07:00", EndTime: "10:30"},
 	}
 
-	data := buildCommuteData(journeys, nil, 0)
+	data := buildCommuteData(journeys, nil, DateRange{})
 	if data.TotalCommutes != 1 {
 		t.Fatalf("expected 1 commute, got %d", data.TotalCommutes)
 	}
@@ -305,7 +500,7 @@ func TestBuildCommuteData_SkipsInvalidEndTime(t *testing.T) {
 		{Date: wed.Format("2006-01-02"), StartTime: "09:00", EndTime: ""},
 	}
 
-	data := buildCommuteData(journeys, nil, 0)
+	data := buildCommuteData(journeys, nil, DateRange{})
 	if data.TotalCommutes != 1 {
 		t.Errorf("expected 1 commute (skipping invalid end times), got %d", data.TotalCommutes)
 	}
@@ -344,7 +539,7 @@ func TestBuildCommuteData_RatingsOverlay(t *testing.T) {
 		// No rating for Thursday – that commute gets no overlay point.
 	}
 
-	data := buildCommuteData(journeys, ratings, 0)
+	data := buildCommuteData(journeys, ratings, DateRange{})
 
 	if data.TotalCommutes != 3 {
 		t.Fatalf("expected 3 commutes, got %d", data.TotalCommutes)
@@ -388,7 +583,7 @@ func TestBuildCommuteData_RatingComments(t *testing.T) {
 		{Date: wed, Rating: 2, Comment: ""},
 	}
 
-	data := buildCommuteData(journeys, ratings, 0)
+	data := buildCommuteData(journeys, ratings, DateRange{})
 
 	if len(data.Ratings) != 2 {
 		t.Fatalf("expected 2 rating points, got %d", len(data.Ratings))
@@ -414,7 +609,7 @@ func TestBuildCommuteData_ShortestCommute(t *testing.T) {
 		{Date: thu.Format("2006-01-02"), StartTime: "09:00", EndTime: "10:00"}, // 60m
 	}
 
-	data := buildCommuteData(journeys, nil, 0)
+	data := buildCommuteData(journeys, nil, DateRange{})
 
 	if data.ShortestCommute != "30m" {
 		t.Errorf("ShortestCommute = %q, want %q", data.ShortestCommute, "30m")
@@ -431,7 +626,7 @@ func TestBuildCommuteData_NoRatingsWhenNil(t *testing.T) {
 		{Date: wed.Format("2006-01-02"), StartTime: "08:00", EndTime: "09:00"},
 	}
 
-	data := buildCommuteData(journeys, nil, 0)
+	data := buildCommuteData(journeys, nil, DateRange{})
 
 	if data.HasRatings {
 		t.Error("HasRatings should be false when no ratings provided")
@@ -461,7 +656,7 @@ func TestBuildCommuteData_RatingExpandsXAxis(t *testing.T) {
 		{Date: fri, Rating: 3},
 	}
 
-	data := buildCommuteData(journeys, ratings, 0)
+	data := buildCommuteData(journeys, ratings, DateRange{})
 
 	// Two journeys should be recorded.
 	if data.TotalCommutes != 2 {
@@ -505,7 +700,7 @@ func TestBuildCommuteData_RatingExpandsXAxis(t *testing.T) {
 }
 
 func TestBuildCommuteData_RatingLabels(t *testing.T) {
-	data := buildCommuteData(nil, nil, 0)
+	data := buildCommuteData(nil, nil, DateRange{})
 
 	if len(data.RatingLabels) != 5 {
 		t.Fatalf("expected 5 rating labels, got %d", len(data.RatingLabels))
@@ -580,20 +775,25 @@ func TestSmoothRatingPath_MultiplePoints(t *testing.T) {
 
 func TestParseDaysParam(t *testing.T) {
 	tests := []struct {
-		input string
-		want  int
+		input   string
+		want    int
+		wantErr bool
 	}{
-		{"7", 7},
-		{"30", 30},
-		{"60", 60},
-		{"90", 90},
-		{"0", 0},
-		{"", 30},    // empty → default 30
-		{"15", 30},  // unknown value → default 30
-		{"abc", 30}, // non-numeric → default 30
+		{"7", 7, false},
+		{"30", 30, false},
+		{"60", 60, false},
+		{"90", 90, false},
+		{"0", 0, false},
+		{"", 30, false},  // empty → default 30
+		{"15", 0, true},  // unknown value → error
+		{"abc", 0, true}, // non-numeric → error
 	}
 	for _, tt := range tests {
-		got := parseDaysParam(tt.input)
+		got, err := parseDaysParam(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseDaysParam(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
 		if got != tt.want {
 			t.Errorf("parseDaysParam(%q) = %d, want %d", tt.input, got, tt.want)
 		}
@@ -628,7 +828,7 @@ func TestBuildDateRangeOptions(t *testing.T) {
 }
 
 func TestBuildCommuteData_DateRangeOptions_PresentInOutput(t *testing.T) {
-	data := buildCommuteData(nil, nil, 30)
+	data := buildCommuteData(nil, nil, DateRange{Days: 30})
 
 	if len(data.DateRangeOptions) != 5 {
 		t.Fatalf("expected 5 DateRangeOptions, got %d", len(data.DateRangeOptions))
@@ -673,20 +873,52 @@ func TestBuildCommuteData_FiltersByDateRange(t *testing.T) {
 	}
 
 	// With 30-day range: only the recent commute should be visible.
-	data30 := buildCommuteData(journeys, nil, 30)
+	data30 := buildCommuteData(journeys, nil, DateRange{Days: 30})
 	if data30.TotalCommutes != 1 {
 		t.Errorf("days=30: expected 1 commute, got %d", data30.TotalCommutes)
 	}
 
 	// With 90-day range: both commutes should be visible.
-	data90 := buildCommuteData(journeys, nil, 90)
+	data90 := buildCommuteData(journeys, nil, DateRange{Days: 90})
 	if data90.TotalCommutes != 2 {
 		t.Errorf("days=90: expected 2 commutes, got %d", data90.TotalCommutes)
 	}
 
 	// With all available (0): both commutes should be visible.
-	dataAll := buildCommuteData(journeys, nil, 0)
+	dataAll := buildCommuteData(journeys, nil, DateRange{})
 	if dataAll.TotalCommutes != 2 {
 		t.Errorf("days=0: expected 2 commutes, got %d", dataAll.TotalCommutes)
 	}
 }
+
+func TestHeatmapFilter(t *testing.T) {
+	today := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	f := heatmapFilter(today)
+	if want := time.Date(2023, 3, 5, 0, 0, 0, 0, time.UTC); !f.From.Equal(want) {
+		t.Errorf("From = %v, want %v", f.From, want)
+	}
+	if !f.To.IsZero() || len(f.Weekdays) != 0 {
+		t.Errorf("expected an open-ended filter without weekdays, got %+v", f)
+	}
+}
+
+func TestCommuteFilter(t *testing.T) {
+	today := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	h := &Handler{}
+
+	f := h.commuteFilter(nil, DateRange{Days: 30}, today)
+	if want := today.AddDate(0, 0, -30); !f.From.Equal(want) {
+		t.Errorf("days=30: From = %v, want %v", f.From, want)
+	}
+	if len(f.Tags) != 1 || len(f.Tags[0].Rules) != 1 || f.Tags[0].Rules[0].Tag != tags.Commute {
+		t.Errorf("days=30: expected the default commute rule, got %+v", f.Tags)
+	}
+	if f.Tags[0].Annotated != nil {
+		t.Errorf("expected no annotated journeys without annotations, got %+v", f.Tags[0].Annotated)
+	}
+
+	all := h.commuteFilter(nil, DateRange{}, today)
+	if !all.From.IsZero() {
+		t.Errorf("days=0: From = %v, want zero (unbounded)", all.From)
+	}
+}
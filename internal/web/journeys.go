@@ -0,0 +1,301 @@
+package web
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+	"github.com/its-the-vibe/pearl/internal/tags"
+)
+
+// journeysPageSize is the number of journeys shown on each page of the
+// journeys table.
+const journeysPageSize = 50
+
+// journeysColumn is a sortable column of the journeys table.
+type journeysColumn struct {
+	label string
+	sort  string // one of the bq.SortBy orders
+}
+
+// journeysColumns lists the sortable columns of the journeys table.
+var journeysColumns = []journeysColumn{
+	{"Date", bq.SortByDate},
+	{"Journey", bq.SortByAction},
+	{"Charge", bq.SortByCharge},
+	{"Balance", bq.SortByBalance},
+}
+
+// JourneysForm holds the journey filters and sort order, which echo the
+// request.
+type JourneysForm struct {
+	Text  string // station or other text in the journey action
+	Type  string // one of bq.JourneyTypes; empty for all
+	Min   string // minimum charge in pounds, e.g. "1.50"
+	Max   string
+	Note  string
+	Tag   string // selected tag; empty for all journeys
+	Sort  string // one of the bq.SortBy orders
+	Asc   bool
+	Error string // validation error shown to the user; empty when valid
+}
+
+// values returns the query parameters selecting f, without the date range
+// or page.
+func (f JourneysForm) values() url.Values {
+	v := url.Values{}
+	for name, value := range map[string]string{"q": f.Text, "type": f.Type, "min": f.Min, "max": f.Max, "note": f.Note, "tag": f.Tag} {
+		if value != "" {
+			v.Set(name, value)
+		}
+	}
+	if f.Sort != "" && f.Sort != bq.SortByDate {
+		v.Set("sort", f.Sort)
+	}
+	if f.Asc {
+		v.Set("order", "asc")
+	}
+	return v
+}
+
+// JourneyRow is a single journey in the journeys table.
+type JourneyRow struct {
+	Date    string // e.g. "Tue 05 Mar 2024"
+	Start   string
+	End     string
+	Action  string
+	Type    string
+	Charge  string // empty when nothing was charged
+	Credit  string // empty without a credit
+	Balance string
+	Note    string
+	Tags    []string // assigned by the tag rules
+}
+
+// JourneyLink is a link in the journeys page controls.
+type JourneyLink struct {
+	Label    string
+	URL      string
+	Selected bool
+	Asc      bool // for column headers: sorted in ascending order
+}
+
+// JourneysData is passed to the journeys template.
+type JourneysData struct {
+	Rows       []JourneyRow
+	Total      int // journeys matching the filters across all pages
+	Page       int
+	Pages      int
+	First      int // number of the first row shown
+	Last       int
+	PrevURL    string // empty on the first page
+	NextURL    string // empty on the last page
+	Columns    []JourneyLink
+	RangeLinks []JourneyLink
+	Types      []string
+	Form       JourneysForm
+	Range      DateRange
+	RangeForm  DateRangeForm
+	Nav        Nav
+}
+
+func (h *Handler) handleJourneys(w http.ResponseWriter, r *http.Request) {
+	sel, ok := h.selectCards(w, r, "journeys", false)
+	if !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	search, form, rng, page, err := parseJourneySearch(q, today)
+	if err != nil {
+		form.Error = err.Error()
+		data := buildJourneysData(nil, 0, form, DateRange{Days: customRangeDays}, 1)
+		data.RangeForm = DateRangeForm{From: q.Get("from"), To: q.Get("to")}
+		data.Nav = sel.Nav
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusBadRequest)
+		if err := h.tmpl.ExecuteTemplate(w, "journeys.html", data); err != nil {
+			slog.Error("rendering journeys template", "error", err)
+		}
+		return
+	}
+
+	ctx := bq.WithPage(r.Context(), "journeys")
+	search.Filter = h.tagged(sel.Notes, sel.Tag, search.Filter)
+	journeys, total, err := sel.Card.Client.SearchJourneys(ctx, search)
+	if err != nil {
+		slog.Error("querying bigquery for journeys", "card", sel.Card.ID, "error", err)
+		http.Error(w, "failed to load journeys", http.StatusInternalServerError)
+		return
+	}
+
+	data := buildJourneysData(journeys, total, form, rng, page)
+	for i, j := range journeys {
+		data.Rows[i].Tags = journeyTags(h.opts.Tags, j)
+	}
+	data.RangeForm = rng.Form()
+	data.Nav = sel.Nav
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.tmpl.ExecuteTemplate(w, "journeys.html", data); err != nil {
+		slog.Error("rendering journeys template", "error", err)
+	}
+}
+
+// parseJourneySearch parses the filters, sort order and page of the journeys
+// page. The form echoes the request even when it is invalid.
+func parseJourneySearch(q url.Values, today time.Time) (bq.JourneySearch, JourneysForm, DateRange, int, error) {
+	form := JourneysForm{
+		Text: strings.TrimSpace(q.Get("q")),
+		Type: q.Get("type"),
+		Min:  strings.TrimSpace(q.Get("min")),
+		Max:  strings.TrimSpace(q.Get("max")),
+		Note: strings.TrimSpace(q.Get("note")),
+		Tag:  tags.NormalizeTag(q.Get("tag")),
+		Sort: q.Get("sort"),
+		Asc:  q.Get("order") == "asc",
+	}
+	fail := func(err error) (bq.JourneySearch, JourneysForm, DateRange, int, error) {
+		return bq.JourneySearch{}, form, DateRange{}, 0, err
+	}
+
+	rng, err := parseDateRange(q, today)
+	if err != nil {
+		return fail(err)
+	}
+	if form.Type != "" && !slices.Contains(bq.JourneyTypes, form.Type) {
+		return fail(fmt.Errorf("invalid journey type %q", form.Type))
+	}
+	if form.Sort == "" {
+		form.Sort = bq.SortByDate
+	}
+	if !slices.ContainsFunc(journeysColumns, func(c journeysColumn) bool { return c.sort == form.Sort }) {
+		return fail(fmt.Errorf("invalid sort order %q", form.Sort))
+	}
+	if order := q.Get("order"); order != "" && order != "asc" && order != "desc" {
+		return fail(fmt.Errorf("invalid order %q: use asc or desc", order))
+	}
+
+	search := bq.JourneySearch{
+		Filter:    rng.journeyFilter(today),
+		Text:      form.Text,
+		Type:      form.Type,
+		Note:      form.Note,
+		Sort:      form.Sort,
+		Ascending: form.Asc,
+		Limit:     journeysPageSize,
+	}
+	for _, bound := range []struct {
+		name  string
+		value string
+		dst   **float64
+	}{{"minimum", form.Min, &search.MinCharge}, {"maximum", form.Max, &search.MaxCharge}} {
+		if bound.value == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimPrefix(bound.value, "£"), 64)
+		if err != nil || v < 0 {
+			return fail(fmt.Errorf("invalid %s charge %q: use an amount in pounds, such as 2.80", bound.name, bound.value))
+		}
+		*bound.dst = &v
+	}
+	if search.MinCharge != nil && search.MaxCharge != nil && *search.MinCharge > *search.MaxCharge {
+		return fail(fmt.Errorf("minimum charge %s is more than maximum charge %s", form.Min, form.Max))
+	}
+
+	page := 1
+	if s := q.Get("page"); s != "" {
+		if page, err = strconv.Atoi(s); err != nil || page < 1 {
+			return fail(fmt.Errorf("invalid page %q", s))
+		}
+	}
+	search.Offset = (page - 1) * journeysPageSize
+	return search, form, rng, page, nil
+}
+
+// buildJourneysData lays out a page of journeys, of total matching form and
+// rng, with links to the neighbouring pages and to other sort orders and
+// date ranges that keep the other filters.
+func buildJourneysData(journeys []bq.Journey, total int, form JourneysForm, rng DateRange, page int) JourneysData {
+	data := JourneysData{
+		Rows:  []JourneyRow{},
+		Total: total,
+		Page:  page,
+		Pages: max(1, (total+journeysPageSize-1)/journeysPageSize),
+		Types: bq.JourneyTypes,
+		Form:  form,
+		Range: rng,
+	}
+
+	link := func(v url.Values, rng DateRange, page int) string {
+		rv, _ := url.ParseQuery(string(rng.Query()))
+		for name, values := range rv {
+			v[name] = values
+		}
+		if page > 1 {
+			v.Set("page", strconv.Itoa(page))
+		}
+		return "/journeys?" + v.Encode()
+	}
+
+	for _, j := range journeys {
+		row := JourneyRow{
+			Date:    j.Date,
+			Start:   j.StartTime,
+			End:     j.EndTime,
+			Action:  j.JourneyAction,
+			Type:    bq.JourneyType(j),
+			Balance: formatPounds(j.Balance),
+			Note:    j.Note,
+		}
+		if t, err := parseJourneyDate(j.Date); err == nil {
+			row.Date = t.Format("Mon 02 Jan 2006")
+		}
+		if j.Charge != 0 {
+			row.Charge = formatPounds(j.Charge)
+		}
+		if j.Credit != 0 {
+			row.Credit = formatPounds(j.Credit)
+		}
+		data.Rows = append(data.Rows, row)
+	}
+	if len(data.Rows) > 0 {
+		data.First = (page-1)*journeysPageSize + 1
+		data.Last = data.First + len(data.Rows) - 1
+	}
+	if page > 1 {
+		data.PrevURL = link(form.values(), rng, min(page-1, data.Pages))
+	}
+	if page < data.Pages {
+		data.NextURL = link(form.values(), rng, page+1)
+	}
+
+	for _, c := range journeysColumns {
+		sorted := form
+		sorted.Sort = c.sort
+		// Sorting by the current column again reverses the order; other
+		// columns start with the largest or most recent first.
+		sorted.Asc = c.sort == form.Sort && !form.Asc
+		data.Columns = append(data.Columns, JourneyLink{
+			Label:    c.label,
+			URL:      link(sorted.values(), rng, 1),
+			Selected: c.sort == form.Sort,
+			Asc:      form.Asc,
+		})
+	}
+	for _, opt := range buildDateRangeOptions(rng.Days) {
+		data.RangeLinks = append(data.RangeLinks, JourneyLink{
+			Label:    opt.Label,
+			URL:      link(form.values(), DateRange{Days: opt.Days}, 1),
+			Selected: opt.Selected,
+		})
+	}
+	return data
+}
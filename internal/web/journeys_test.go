@@ -0,0 +1,127 @@
+package web
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+)
+
+func TestParseJourneySearch(t *testing.T) {
+	today := mustDate("2024-03-31")
+
+	q, _ := url.ParseQuery("q=+Bank+&type=rail&min=%C2%A31.50&max=3&note=late&sort=charge&order=asc&page=3&from=2024-03-01&to=2024-03-15&tag=+Strike")
+	search, form, rng, page, err := parseJourneySearch(q, today)
+	if err != nil {
+		t.Fatalf("parseJourneySearch: %v", err)
+	}
+	if search.Text != "Bank" || form.Text != "Bank" {
+		t.Errorf("Text = %q, form %q, want %q", search.Text, form.Text, "Bank")
+	}
+	if form.Tag != "strike" || form.values().Get("tag") != "strike" {
+		t.Errorf("Tag = %q, want strike", form.Tag)
+	}
+	if search.Type != bq.JourneyTypeRail || search.Note != "late" {
+		t.Errorf("Type, Note = %q, %q, want %q, %q", search.Type, search.Note, bq.JourneyTypeRail, "late")
+	}
+	if search.MinCharge == nil || *search.MinCharge != 1.5 || search.MaxCharge == nil || *search.MaxCharge != 3 {
+		t.Errorf("MinCharge, MaxCharge = %v, %v, want 1.5, 3", search.MinCharge, search.MaxCharge)
+	}
+	if search.Sort != bq.SortByCharge || !search.Ascending {
+		t.Errorf("Sort = %q ascending %v, want %q ascending", search.Sort, search.Ascending, bq.SortByCharge)
+	}
+	if page != 3 || search.Limit != journeysPageSize || search.Offset != 2*journeysPageSize {
+		t.Errorf("page %d: Limit, Offset = %d, %d, want 3: %d, %d", page, search.Limit, search.Offset, journeysPageSize, 2*journeysPageSize)
+	}
+	if !rng.Custom() || !search.Filter.From.Equal(mustDate("2024-03-01")) || !search.Filter.To.Equal(mustDate("2024-03-15")) {
+		t.Errorf("Filter = %v to %v, want 2024-03-01 to 2024-03-15", search.Filter.From, search.Filter.To)
+	}
+
+	search, form, rng, page, err = parseJourneySearch(url.Values{}, today)
+	if err != nil {
+		t.Fatalf("parseJourneySearch(defaults): %v", err)
+	}
+	if form.Sort != bq.SortByDate || form.Asc || page != 1 || search.Offset != 0 || rng.Days != 30 {
+		t.Errorf("defaults = sort %q asc %v page %d offset %d days %d, want date, descending, page 1, offset 0, 30 days", form.Sort, form.Asc, page, search.Offset, rng.Days)
+	}
+	if search.MinCharge != nil || search.MaxCharge != nil {
+		t.Errorf("defaults: charge bounds = %v, %v, want none", search.MinCharge, search.MaxCharge)
+	}
+
+	for _, query := range []string{
+		"type=plane",
+		"sort=note",
+		"order=up",
+		"min=abc",
+		"max=-1",
+		"min=5&max=2",
+		"page=0",
+		"page=x",
+		"from=2024-13-01",
+	} {
+		q, _ := url.ParseQuery(query)
+		_, form, _, _, err := parseJourneySearch(q, today)
+		if err == nil {
+			t.Errorf("parseJourneySearch(%q) succeeded, want an error", query)
+		}
+		if query == "min=abc" && form.Min != "abc" {
+			t.Errorf("parseJourneySearch(%q): form.Min = %q, want the request echoed", query, form.Min)
+		}
+	}
+}
+
+func TestBuildJourneysData(t *testing.T) {
+	journeys := []bq.Journey{
+		{Date: "05-Mar-24", StartTime: "08:00", EndTime: "08:45", JourneyAction: "Clapham Common to Bank", Charge: 2.8, Balance: 17.2},
+		{Date: "2024-03-05", StartTime: "12:00", JourneyAction: "Auto top-up", Credit: 20, Balance: 20, Note: "lunch"},
+	}
+	form := JourneysForm{Text: "Bank", Sort: bq.SortByCharge}
+	data := buildJourneysData(journeys, 120, form, DateRange{Days: 30}, 2)
+
+	if data.Pages != 3 || data.First != 51 || data.Last != 52 {
+		t.Errorf("Pages, First, Last = %d, %d, %d, want 3, 51, 52", data.Pages, data.First, data.Last)
+	}
+	if len(data.Rows) != 2 {
+		t.Fatalf("len(Rows) = %d, want 2", len(data.Rows))
+	}
+	row := data.Rows[0]
+	if row.Date != "Tue 05 Mar 2024" || row.Type != bq.JourneyTypeRail || row.Charge != "£2.80" || row.Credit != "" {
+		t.Errorf("Rows[0] = %+v, want Tue 05 Mar 2024, rail, £2.80 without a credit", row)
+	}
+	row = data.Rows[1]
+	if row.Type != bq.JourneyTypeTopUp || row.Charge != "" || row.Credit != "£20.00" || row.Note != "lunch" {
+		t.Errorf("Rows[1] = %+v, want a £20.00 top-up without a charge", row)
+	}
+
+	if want := "/journeys?days=30&q=Bank&sort=charge"; data.PrevURL != want {
+		t.Errorf("PrevURL = %q, want %q", data.PrevURL, want)
+	}
+	if want := "/journeys?days=30&page=3&q=Bank&sort=charge"; data.NextURL != want {
+		t.Errorf("NextURL = %q, want %q", data.NextURL, want)
+	}
+
+	for _, c := range data.Columns {
+		switch c.Label {
+		case "Charge":
+			// Sorting by the current column again reverses it.
+			if !c.Selected || !strings.Contains(c.URL, "order=asc") || strings.Contains(c.URL, "page=") {
+				t.Errorf("Charge column = %+v, want selected, linking to ascending order on the first page", c)
+			}
+		case "Date":
+			if c.Selected || c.URL != "/journeys?days=30&q=Bank" {
+				t.Errorf("Date column = %+v, want unselected, linking to the default order", c)
+			}
+		}
+	}
+	for _, l := range data.RangeLinks {
+		if !strings.Contains(l.URL, "q=Bank") || !strings.Contains(l.URL, "sort=charge") {
+			t.Errorf("range link %q = %q, want the filters kept", l.Label, l.URL)
+		}
+	}
+
+	last := buildJourneysData(nil, 0, JourneysForm{Sort: bq.SortByDate}, DateRange{Days: customRangeDays}, 1)
+	if last.Pages != 1 || last.First != 0 || last.PrevURL != "" || last.NextURL != "" {
+		t.Errorf("empty page = %+v, want a single page without links", last)
+	}
+}
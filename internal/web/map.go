@@ -0,0 +1,267 @@
+package web
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+	"github.com/its-the-vibe/pearl/internal/stations"
+)
+
+// Geometry of the station map, in SVG user units.
+const (
+	mapWidth      = 800
+	mapMaxHeight  = 800
+	mapPadding    = 24
+	mapMinRadius  = 3
+	mapMaxRadius  = 14
+	mapMinStroke  = 1
+	mapMaxStroke  = 8
+	mapMinDegrees = 0.01 // smallest span projected, so that a single station is not magnified
+)
+
+// MapStation is a visited station drawn as a circle sized by visits.
+type MapStation struct {
+	Name      string
+	Zone      string
+	X, Y      float64
+	Radius    float64
+	Visits    int
+	LastVisit string // e.g. "Tue 05 Mar 2024"
+}
+
+// Label returns the tooltip text of the station.
+func (s MapStation) Label() string {
+	return fmt.Sprintf("%s (zone %s): %d visits, last %s", s.Name, s.Zone, s.Visits, s.LastVisit)
+}
+
+// MapRoute is a line between two stations weighted by the number of journeys
+// between them in either direction.
+type MapRoute struct {
+	From, To  string
+	X1, Y1    float64
+	X2, Y2    float64
+	Width     float64
+	Journeys  int
+	LastVisit string
+}
+
+// Label returns the tooltip text of the route.
+func (r MapRoute) Label() string {
+	return fmt.Sprintf("%s – %s: %d journeys, last %s", r.From, r.To, r.Journeys, r.LastVisit)
+}
+
+// MapData is passed to the map template.
+type MapData struct {
+	Width            int
+	Height           int
+	Stations         []MapStation // most visited first, so that smaller circles are drawn on top
+	Routes           []MapRoute   // fewest journeys first, so that busier routes are drawn on top
+	Journeys         int          // journeys in the range
+	Unplaced         []string     // station names without coordinates, most visited first
+	DateRangeOptions []DateRangeOption
+	Range            DateRange
+	RangeForm        DateRangeForm
+	Nav              Nav
+}
+
+// mapVisits counts the visits to a station or route.
+type mapVisits struct {
+	count int
+	last  time.Time
+}
+
+func (v *mapVisits) add(t time.Time) {
+	v.count++
+	if t.After(v.last) {
+		v.last = t
+	}
+}
+
+func (h *Handler) handleMap(w http.ResponseWriter, r *http.Request) {
+	sel, ok := h.selectCards(w, r, "map", false)
+	if !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	rng, err := parseDateRange(q, today)
+	if err != nil {
+		data := buildMapData(nil, h.opts.Stations, DateRange{Days: customRangeDays})
+		data.RangeForm = DateRangeForm{From: q.Get("from"), To: q.Get("to"), Error: err.Error()}
+		data.Nav = sel.Nav
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusBadRequest)
+		if err := h.tmpl.ExecuteTemplate(w, "map.html", data); err != nil {
+			slog.Error("rendering map template", "error", err)
+		}
+		return
+	}
+
+	ctx := bq.WithPage(r.Context(), "map")
+	journeys, err := sel.Card.Client.CommuteJourneys(ctx, h.tagged(sel.Notes, sel.Tag, rng.journeyFilter(today)))
+	if err != nil {
+		slog.Error("querying bigquery for journeys", "card", sel.Card.ID, "error", err)
+		http.Error(w, "failed to load journey data", http.StatusInternalServerError)
+		return
+	}
+
+	data := buildMapData(journeys, h.opts.Stations, rng)
+	data.RangeForm = rng.Form()
+	data.Nav = sel.Nav
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.tmpl.ExecuteTemplate(w, "map.html", data); err != nil {
+		slog.Error("rendering map template", "error", err)
+	}
+}
+
+// buildMapData projects the stations and routes travelled in journeys onto
+// an SVG canvas fitted to the visited stations. Stations are located with
+// dir; names it cannot match are listed in Unplaced rather than drawn.
+func buildMapData(journeys []bq.CommuteJourney, dir *stations.Directory, rng DateRange) MapData {
+	data := MapData{
+		Width:            mapWidth,
+		Height:           mapWidth / 2,
+		Stations:         []MapStation{},
+		Routes:           []MapRoute{},
+		DateRangeOptions: buildDateRangeOptions(rng.Days),
+		Range:            rng,
+	}
+
+	located := make(map[string]*stations.Station)
+	visits := make(map[string]*mapVisits)    // by station name
+	routes := make(map[[2]string]*mapVisits) // by station names in alphabetical order
+	unplaced := make(map[string]int)
+	for _, j := range journeys {
+		t, err := parseJourneyDate(j.Date)
+		if err != nil {
+			continue
+		}
+		data.Journeys++
+		from, to := journeyStations(strings.TrimSpace(j.JourneyAction))
+		var ends []*stations.Station
+		for _, name := range []string{from, to} {
+			if name == "" {
+				continue
+			}
+			s, _, ok := dir.Match(name)
+			if !ok {
+				unplaced[name]++
+				continue
+			}
+			located[s.Name] = s
+			if visits[s.Name] == nil {
+				visits[s.Name] = &mapVisits{}
+			}
+			visits[s.Name].add(t)
+			ends = append(ends, s)
+		}
+		if len(ends) == 2 && ends[0] != ends[1] {
+			key := [2]string{ends[0].Name, ends[1].Name}
+			if key[1] < key[0] {
+				key[0], key[1] = key[1], key[0]
+			}
+			if routes[key] == nil {
+				routes[key] = &mapVisits{}
+			}
+			routes[key].add(t)
+		}
+	}
+
+	for _, u := range topCounts(unplaced, len(unplaced)) {
+		data.Unplaced = append(data.Unplaced, u.Name)
+	}
+	if len(visits) == 0 {
+		return data
+	}
+
+	// Equirectangular projection: at London's latitude a degree of longitude
+	// is shorter than a degree of latitude by the cosine of the latitude.
+	minLat, maxLat := math.Inf(1), math.Inf(-1)
+	minLon, maxLon := math.Inf(1), math.Inf(-1)
+	for _, s := range located {
+		minLat, maxLat = min(minLat, s.Lat), max(maxLat, s.Lat)
+		minLon, maxLon = min(minLon, s.Lon), max(maxLon, s.Lon)
+	}
+	midLat, midLon := (minLat+maxLat)/2, (minLon+maxLon)/2
+	xScale := math.Cos(midLat * math.Pi / 180)
+	spanX := max((maxLon-minLon)*xScale, mapMinDegrees)
+	spanY := max(maxLat-minLat, mapMinDegrees)
+
+	inner := float64(mapWidth - 2*mapPadding)
+	scale := inner / spanX
+	if spanY*scale > mapMaxHeight-2*mapPadding {
+		scale = (mapMaxHeight - 2*mapPadding) / spanY
+	}
+	data.Height = int(math.Ceil(spanY*scale)) + 2*mapPadding
+	project := func(s *stations.Station) (x, y float64) {
+		x = mapWidth/2 + (s.Lon-midLon)*xScale*scale
+		y = float64(data.Height)/2 - (s.Lat-midLat)*scale
+		return roundTenth(x), roundTenth(y)
+	}
+
+	maxVisits := 0
+	for _, v := range visits {
+		maxVisits = max(maxVisits, v.count)
+	}
+	for name, v := range visits {
+		s := located[name]
+		x, y := project(s)
+		data.Stations = append(data.Stations, MapStation{
+			Name:      name,
+			Zone:      s.Zone(),
+			X:         x,
+			Y:         y,
+			Radius:    roundTenth(mapMinRadius + (mapMaxRadius-mapMinRadius)*math.Sqrt(float64(v.count)/float64(maxVisits))),
+			Visits:    v.count,
+			LastVisit: v.last.Format("Mon 02 Jan 2006"),
+		})
+	}
+	sort.Slice(data.Stations, func(i, j int) bool {
+		a, b := data.Stations[i], data.Stations[j]
+		if a.Visits != b.Visits {
+			return a.Visits > b.Visits
+		}
+		return a.Name < b.Name
+	})
+
+	maxJourneys := 0
+	for _, v := range routes {
+		maxJourneys = max(maxJourneys, v.count)
+	}
+	for key, v := range routes {
+		x1, y1 := project(located[key[0]])
+		x2, y2 := project(located[key[1]])
+		data.Routes = append(data.Routes, MapRoute{
+			From:      key[0],
+			To:        key[1],
+			X1:        x1,
+			Y1:        y1,
+			X2:        x2,
+			Y2:        y2,
+			Width:     roundTenth(mapMinStroke + (mapMaxStroke-mapMinStroke)*float64(v.count)/float64(maxJourneys)),
+			Journeys:  v.count,
+			LastVisit: v.last.Format("Mon 02 Jan 2006"),
+		})
+	}
+	sort.Slice(data.Routes, func(i, j int) bool {
+		a, b := data.Routes[i], data.Routes[j]
+		if a.Journeys != b.Journeys {
+			return a.Journeys < b.Journeys
+		}
+		return a.From+a.To < b.From+b.To
+	})
+	return data
+}
+
+// roundTenth rounds v to one decimal place to keep the SVG compact.
+func roundTenth(v float64) float64 {
+	return math.Round(v*10) / 10
+}
@@ -0,0 +1,82 @@
+package web
+
+import (
+	"testing"
+
+	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+	"github.com/its-the-vibe/pearl/internal/stations"
+)
+
+func TestBuildMapData(t *testing.T) {
+	dir, err := stations.Embedded()
+	if err != nil {
+		t.Fatalf("stations.Embedded() unexpected error: %v", err)
+	}
+	data := buildMapData([]bq.CommuteJourney{
+		{Date: "2024-03-04", JourneyAction: "Clapham Common to Bank"},
+		{Date: "2024-03-04", JourneyAction: "Bank to Clapham Common"},
+		{Date: "2024-03-05", JourneyAction: "Clapham Common to Bank"},
+		{Date: "2024-03-06", JourneyAction: "Bank to Liverpool Street"},
+		{Date: "2024-03-07", JourneyAction: "Gatwick Airport to Bank"},
+		{Date: "2024-03-07", JourneyAction: "Bus journey, route 88"},
+	}, dir, DateRange{Days: 30})
+
+	if data.Journeys != 6 {
+		t.Errorf("Journeys = %d, want 6", data.Journeys)
+	}
+	if len(data.Stations) != 3 {
+		t.Fatalf("Stations = %+v, want Bank, Clapham Common and Liverpool Street", data.Stations)
+	}
+	bank := data.Stations[0]
+	if bank.Name != "Bank" || bank.Visits != 5 || bank.LastVisit != "Thu 07 Mar 2024" {
+		t.Errorf("Stations[0] = %+v, want Bank with 5 visits, last Thu 07 Mar 2024", bank)
+	}
+	if bank.Radius != mapMaxRadius {
+		t.Errorf("Bank radius = %v, want %v", bank.Radius, mapMaxRadius)
+	}
+	for _, s := range data.Stations {
+		if s.X < 0 || s.X > float64(data.Width) || s.Y < 0 || s.Y > float64(data.Height) {
+			t.Errorf("%s at (%v, %v) is outside the %dx%d map", s.Name, s.X, s.Y, data.Width, data.Height)
+		}
+	}
+
+	// Liverpool Street is north-east of Clapham Common.
+	pos := make(map[string]MapStation)
+	for _, s := range data.Stations {
+		pos[s.Name] = s
+	}
+	if ls, cc := pos["Liverpool Street"], pos["Clapham Common"]; ls.X <= cc.X || ls.Y >= cc.Y {
+		t.Errorf("Liverpool Street at (%v, %v) is not north-east of Clapham Common at (%v, %v)", ls.X, ls.Y, cc.X, cc.Y)
+	}
+
+	if len(data.Routes) != 2 {
+		t.Fatalf("Routes = %+v, want 2 routes", data.Routes)
+	}
+	busiest := data.Routes[len(data.Routes)-1]
+	if busiest.From != "Bank" || busiest.To != "Clapham Common" || busiest.Journeys != 3 || busiest.Width != mapMaxStroke {
+		t.Errorf("busiest route = %+v, want Bank – Clapham Common with 3 journeys in both directions", busiest)
+	}
+	if busiest.LastVisit != "Tue 05 Mar 2024" {
+		t.Errorf("busiest route LastVisit = %q, want %q", busiest.LastVisit, "Tue 05 Mar 2024")
+	}
+
+	if len(data.Unplaced) != 1 || data.Unplaced[0] != "Gatwick Airport" {
+		t.Errorf("Unplaced = %v, want [Gatwick Airport]", data.Unplaced)
+	}
+}
+
+func TestBuildMapData_SingleStation(t *testing.T) {
+	dir, err := stations.Embedded()
+	if err != nil {
+		t.Fatalf("stations.Embedded() unexpected error: %v", err)
+	}
+	data := buildMapData([]bq.CommuteJourney{
+		{Date: "2024-03-04", JourneyAction: "Entered Bank"},
+	}, dir, DateRange{Days: 30})
+	if len(data.Stations) != 1 || len(data.Routes) != 0 {
+		t.Fatalf("got %d stations and %d routes, want 1 station and no routes", len(data.Stations), len(data.Routes))
+	}
+	if s := data.Stations[0]; s.X != float64(data.Width)/2 || s.Y != float64(data.Height)/2 {
+		t.Errorf("single station at (%v, %v), want the centre of the %dx%d map", s.X, s.Y, data.Width, data.Height)
+	}
+}
@@ -0,0 +1,276 @@
+package web
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"time"
+
+	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+)
+
+// Selectable periods for the period-over-period comparison.
+const (
+	periodWeek  = "week"
+	periodMonth = "month"
+	periodYear  = "year"
+)
+
+// Directions of change reported for a PeriodMetric.
+const (
+	changeBetter  = "better"
+	changeWorse   = "worse"
+	changeNeutral = "neutral"
+)
+
+// PeriodOption represents a selectable comparison period.
+type PeriodOption struct {
+	Label    string
+	Value    string
+	Selected bool
+}
+
+// PeriodMetric compares one measure between the current and previous period.
+type PeriodMetric struct {
+	Name     string
+	Current  string // formatted, e.g. "£42.10"
+	Previous string
+	Delta    string // e.g. "+£3.40"; "–" when either period has no data
+	Change   string // percentage change, e.g. "+8%"; "–" when undefined
+	Trend    string // changeBetter, changeWorse or changeNeutral
+}
+
+// PeriodsData is passed to the periods template.
+type PeriodsData struct {
+	Options       []PeriodOption
+	CurrentLabel  string // e.g. "1–18 Oct 2026"
+	PreviousLabel string
+	Metrics       []PeriodMetric
+	Nav           Nav
+}
+
+// period is an inclusive range of days.
+type period struct {
+	From time.Time
+	To   time.Time
+}
+
+func (p period) contains(t time.Time) bool {
+	return !t.Before(p.From) && !t.After(p.To)
+}
+
+// dateRange returns p as an explicit DateRange.
+func (p period) dateRange() DateRange {
+	return DateRange{Days: customRangeDays, From: p.From, To: p.To}
+}
+
+// label formats p compactly, such as "1–18 Oct 2026" or "30 Dec 2025 – 5 Jan 2026".
+func (p period) label() string {
+	switch {
+	case p.From.Equal(p.To):
+		return p.From.Format("2 Jan 2006")
+	case p.From.Year() != p.To.Year():
+		return p.From.Format("2 Jan 2006") + " – " + p.To.Format("2 Jan 2006")
+	case p.From.Month() != p.To.Month():
+		return p.From.Format("2 Jan") + " – " + p.To.Format("2 Jan 2006")
+	default:
+		return fmt.Sprintf("%d–%s", p.From.Day(), p.To.Format("2 Jan 2006"))
+	}
+}
+
+func (h *Handler) handlePeriods(w http.ResponseWriter, r *http.Request) {
+	sel, ok := h.selectCards(w, r, "periods", false)
+	if !ok {
+		return
+	}
+
+	kind := parsePeriodParam(r.URL.Query().Get("period"))
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	cur, prev := periodBounds(kind, today)
+
+	// A single query per table covers both periods; they are split in Go.
+	ctx := bq.WithPage(r.Context(), "periods")
+	both := DateRange{Days: customRangeDays, From: prev.From, To: cur.To}
+	counts, err := sel.Card.Client.JourneyCountsByDay(ctx, h.tagged(sel.Notes, sel.Tag, both.journeyFilter(today)))
+	if err != nil {
+		slog.Error("querying bigquery", "card", sel.Card.ID, "error", err)
+		http.Error(w, "failed to load journey data", http.StatusInternalServerError)
+		return
+	}
+	commutes, err := sel.Card.Client.CommuteJourneys(ctx, h.tagged(sel.Notes, sel.Tag, h.commuteFilter(sel.Notes, both, today)))
+	if err != nil {
+		slog.Error("querying bigquery for commutes", "card", sel.Card.ID, "error", err)
+		http.Error(w, "failed to load commute data", http.StatusInternalServerError)
+		return
+	}
+	ratings, err := sel.Card.Client.Ratings(ctx, both.journeyFilter(today))
+	if err != nil {
+		// Ratings are optional; log and continue without them.
+		slog.Warn("querying bigquery for ratings", "card", sel.Card.ID, "error", err)
+	}
+
+	commutes = withoutDaysOff(commutes, h.opts.Calendar)
+	data := buildPeriodsData(kind, cur, prev, counts, commutes, ratings)
+	data.Nav = sel.Nav
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.tmpl.ExecuteTemplate(w, "periods.html", data); err != nil {
+		slog.Error("rendering periods template", "error", err)
+	}
+}
+
+// parsePeriodParam parses the "period" URL query parameter. Unknown values
+// select the default, month.
+func parsePeriodParam(s string) string {
+	switch s {
+	case periodWeek, periodYear:
+		return s
+	default:
+		return periodMonth
+	}
+}
+
+// periodBounds returns the current period to date and the same stretch of the
+// previous period, so that a partial week, month or year is compared like for
+// like: on 18 Oct, 1–18 Oct is compared with 1–18 Sep. Weeks start on Monday.
+func periodBounds(kind string, today time.Time) (cur, prev period) {
+	var from, prevFrom time.Time
+	switch kind {
+	case periodWeek:
+		from = today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+		prevFrom = from.AddDate(0, 0, -7)
+	case periodYear:
+		from = time.Date(today.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+		prevFrom = from.AddDate(-1, 0, 0)
+	default:
+		from = time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+		prevFrom = from.AddDate(0, -1, 0)
+	}
+
+	elapsed := int(today.Sub(from).Hours() / 24)
+	prevTo := prevFrom.AddDate(0, 0, elapsed)
+	if last := from.AddDate(0, 0, -1); prevTo.After(last) {
+		prevTo = last // e.g. 31 Mar compares with the whole of February
+	}
+	return period{From: from, To: today}, period{From: prevFrom, To: prevTo}
+}
+
+// buildPeriodsData compares journeys, spend, commute durations and ratings
+// between the current and previous periods.
+func buildPeriodsData(kind string, cur, prev period, counts []bq.DayCount, commutes []bq.CommuteJourney, ratings []bq.DailyRating) PeriodsData {
+	type totals struct {
+		journeys   int
+		activeDays int
+		spend      float64
+		ratingSum  float64
+		ratings    int
+	}
+	sum := func(p period) totals {
+		var t totals
+		for _, dc := range counts {
+			if p.contains(dc.Date) {
+				t.journeys += dc.Count
+				t.spend += dc.Spend
+				if dc.Count > 0 {
+					t.activeDays++
+				}
+			}
+		}
+		for _, r := range ratings {
+			if p.contains(r.Date) {
+				t.ratingSum += r.Rating
+				t.ratings++
+			}
+		}
+		return t
+	}
+	c, p := sum(cur), sum(prev)
+	cc := buildCommuteData(commutes, nil, cur.dateRange()).Durations
+	pc := buildCommuteData(commutes, nil, prev.dateRange()).Durations
+
+	count := func(v float64) string { return fmt.Sprintf("%d", int(v)) }
+	signedCount := func(v float64) string { return fmt.Sprintf("%+d", int(v)) }
+	signedPounds := func(v float64) string {
+		if v >= 0 {
+			return "+" + formatPounds(v)
+		}
+		return formatPounds(v)
+	}
+	signedMinutes := func(v float64) string {
+		if v < 0 {
+			return "-" + formatMinutes(-v)
+		}
+		return "+" + formatMinutes(v)
+	}
+	rating := func(v float64) string { return fmt.Sprintf("%.1f", v) }
+	signedRating := func(v float64) string { return fmt.Sprintf("%+.1f", v) }
+
+	metrics := []PeriodMetric{
+		comparePeriods("Journeys", float64(c.journeys), float64(p.journeys), true, true, count, signedCount, 0),
+		comparePeriods("Active days", float64(c.activeDays), float64(p.activeDays), true, true, count, signedCount, 0),
+		comparePeriods("Spend", c.spend, p.spend, true, true, formatPounds, signedPounds, -1),
+		comparePeriods("Commutes", float64(cc.Count), float64(pc.Count), true, true, count, signedCount, 0),
+		comparePeriods("Average commute", cc.MeanMinutes, pc.MeanMinutes, cc.Count > 0, pc.Count > 0, formatMinutes, signedMinutes, -1),
+		comparePeriods("P90 commute", cc.P90Minutes, pc.P90Minutes, cc.Count > 0, pc.Count > 0, formatMinutes, signedMinutes, -1),
+	}
+	if c.ratings > 0 || p.ratings > 0 {
+		metrics = append(metrics, comparePeriods("Average rating",
+			safeDiv(c.ratingSum, c.ratings), safeDiv(p.ratingSum, p.ratings),
+			c.ratings > 0, p.ratings > 0, rating, signedRating, 1))
+	}
+
+	options := []PeriodOption{
+		{Label: "This week vs last week", Value: periodWeek},
+		{Label: "This month vs last month", Value: periodMonth},
+		{Label: "This year vs last year", Value: periodYear},
+	}
+	for i := range options {
+		options[i].Selected = options[i].Value == kind
+	}
+
+	return PeriodsData{
+		Options:       options,
+		CurrentLabel:  cur.label(),
+		PreviousLabel: prev.label(),
+		Metrics:       metrics,
+	}
+}
+
+// comparePeriods builds a PeriodMetric from the current and previous values.
+// hasCur and hasPrev report whether each period has data for the measure.
+// better is 1 when an increase is an improvement, -1 when a decrease is, and
+// 0 when the direction carries no judgement.
+func comparePeriods(name string, cur, prev float64, hasCur, hasPrev bool, format, formatDelta func(float64) string, better int) PeriodMetric {
+	m := PeriodMetric{Name: name, Current: "–", Previous: "–", Delta: "–", Change: "–", Trend: changeNeutral}
+	if hasCur {
+		m.Current = format(cur)
+	}
+	if hasPrev {
+		m.Previous = format(prev)
+	}
+	if !hasCur || !hasPrev {
+		return m
+	}
+
+	delta := cur - prev
+	m.Delta = formatDelta(delta)
+	if prev != 0 {
+		m.Change = fmt.Sprintf("%+.0f%%", delta/math.Abs(prev)*100)
+	}
+	switch {
+	case better == 0 || math.Abs(delta) < 1e-9:
+	case (delta > 0) == (better > 0):
+		m.Trend = changeBetter
+	default:
+		m.Trend = changeWorse
+	}
+	return m
+}
+
+func safeDiv(sum float64, n int) float64 {
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
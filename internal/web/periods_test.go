@@ -0,0 +1,152 @@
+package web
+
+import (
+	"testing"
+	"time"
+
+	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+)
+
+func mustDate(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestPeriodBounds(t *testing.T) {
+	tests := []struct {
+		kind              string
+		today             string
+		curFrom, prevFrom string
+		prevTo            string
+	}{
+		{periodMonth, "2024-03-18", "2024-03-01", "2024-02-01", "2024-02-18"},
+		{periodMonth, "2024-03-31", "2024-03-01", "2024-02-01", "2024-02-29"},
+		{periodWeek, "2024-03-14", "2024-03-11", "2024-03-04", "2024-03-07"}, // Thursday
+		{periodWeek, "2024-03-17", "2024-03-11", "2024-03-04", "2024-03-10"}, // Sunday
+		{periodYear, "2024-02-10", "2024-01-01", "2023-01-01", "2023-02-10"},
+	}
+	for _, tt := range tests {
+		cur, prev := periodBounds(tt.kind, mustDate(tt.today))
+		if !cur.From.Equal(mustDate(tt.curFrom)) || !cur.To.Equal(mustDate(tt.today)) {
+			t.Errorf("%s on %s: current = %v–%v", tt.kind, tt.today, cur.From, cur.To)
+		}
+		if !prev.From.Equal(mustDate(tt.prevFrom)) || !prev.To.Equal(mustDate(tt.prevTo)) {
+			t.Errorf("%s on %s: previous = %s–%s, want %s–%s", tt.kind, tt.today,
+				prev.From.Format("2006-01-02"), prev.To.Format("2006-01-02"), tt.prevFrom, tt.prevTo)
+		}
+	}
+}
+
+func TestPeriodLabel(t *testing.T) {
+	tests := []struct {
+		p    period
+		want string
+	}{
+		{period{mustDate("2024-03-01"), mustDate("2024-03-18")}, "1–18 Mar 2024"},
+		{period{mustDate("2024-02-26"), mustDate("2024-03-03")}, "26 Feb – 3 Mar 2024"},
+		{period{mustDate("2023-12-30"), mustDate("2024-01-05")}, "30 Dec 2023 – 5 Jan 2024"},
+		{period{mustDate("2024-03-01"), mustDate("2024-03-01")}, "1 Mar 2024"},
+	}
+	for _, tt := range tests {
+		if got := tt.p.label(); got != tt.want {
+			t.Errorf("label() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestParsePeriodParam(t *testing.T) {
+	tests := map[string]string{"": periodMonth, "week": periodWeek, "year": periodYear, "decade": periodMonth}
+	for in, want := range tests {
+		if got := parsePeriodParam(in); got != want {
+			t.Errorf("parsePeriodParam(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestComparePeriods(t *testing.T) {
+	count := func(v float64) string { return "n" }
+	delta := func(v float64) string { return "d" }
+
+	m := comparePeriods("Spend", 110, 100, true, true, count, delta, -1)
+	if m.Change != "+10%" || m.Trend != changeWorse {
+		t.Errorf("higher spend: change %q, trend %q; want +10%%, worse", m.Change, m.Trend)
+	}
+	m = comparePeriods("Rating", 4, 5, true, true, count, delta, 1)
+	if m.Change != "-20%" || m.Trend != changeWorse {
+		t.Errorf("lower rating: change %q, trend %q; want -20%%, worse", m.Change, m.Trend)
+	}
+	m = comparePeriods("Journeys", 12, 10, true, true, count, delta, 0)
+	if m.Trend != changeNeutral {
+		t.Errorf("journeys: trend %q, want neutral", m.Trend)
+	}
+	m = comparePeriods("Journeys", 5, 0, true, true, count, delta, 0)
+	if m.Change != "–" || m.Delta != "d" {
+		t.Errorf("from zero: change %q, delta %q; want – and a delta", m.Change, m.Delta)
+	}
+	m = comparePeriods("Average commute", 40, 0, true, false, count, delta, -1)
+	if m.Previous != "–" || m.Delta != "–" {
+		t.Errorf("missing previous: previous %q, delta %q; want –", m.Previous, m.Delta)
+	}
+}
+
+func TestBuildPeriodsData(t *testing.T) {
+	cur := period{mustDate("2024-03-01"), mustDate("2024-03-14")}
+	prev := period{mustDate("2024-02-01"), mustDate("2024-02-14")}
+
+	counts := []bq.DayCount{
+		{Date: mustDate("2024-03-05"), Count: 4, Spend: 10},
+		{Date: mustDate("2024-03-06"), Count: 2, Spend: 5},
+		{Date: mustDate("2024-02-06"), Count: 2, Spend: 5},
+		{Date: mustDate("2024-02-20"), Count: 9, Spend: 50}, // outside the previous period
+	}
+	commutes := []bq.CommuteJourney{
+		{Date: "2024-03-05", StartTime: "08:00", EndTime: "08:30"}, // Tuesday
+		{Date: "2024-02-06", StartTime: "08:00", EndTime: "08:40"}, // Tuesday
+	}
+	ratings := []bq.DailyRating{
+		{Date: mustDate("2024-03-05"), Rating: 4},
+		{Date: mustDate("2024-02-06"), Rating: 2},
+	}
+
+	data := buildPeriodsData(periodMonth, cur, prev, counts, commutes, ratings)
+	if data.CurrentLabel != "1–14 Mar 2024" || data.PreviousLabel != "1–14 Feb 2024" {
+		t.Errorf("labels = %q / %q", data.CurrentLabel, data.PreviousLabel)
+	}
+
+	want := map[string]PeriodMetric{
+		"Journeys":        {Current: "6", Previous: "2", Delta: "+4", Change: "+200%", Trend: changeNeutral},
+		"Spend":           {Current: "£15.00", Previous: "£5.00", Delta: "+£10.00", Change: "+200%", Trend: changeWorse},
+		"Average commute": {Current: "30m", Previous: "40m", Delta: "-10m", Change: "-25%", Trend: changeBetter},
+		"Average rating":  {Current: "4.0", Previous: "2.0", Delta: "+2.0", Change: "+100%", Trend: changeBetter},
+	}
+	found := 0
+	for _, m := range data.Metrics {
+		w, ok := want[m.Name]
+		if !ok {
+			continue
+		}
+		found++
+		w.Name = m.Name
+		if m != w {
+			t.Errorf("%s = %+v, want %+v", m.Name, m, w)
+		}
+	}
+	if found != len(want) {
+		t.Errorf("found %d of %d expected metrics in %+v", found, len(want), data.Metrics)
+	}
+	if !data.Options[1].Selected {
+		t.Errorf("expected the month option to be selected")
+	}
+}
+
+func TestBuildPeriodsData_NoRatings(t *testing.T) {
+	data := buildPeriodsData(periodWeek, period{mustDate("2024-03-11"), mustDate("2024-03-14")}, period{mustDate("2024-03-04"), mustDate("2024-03-07")}, nil, nil, nil)
+	for _, m := range data.Metrics {
+		if m.Name == "Average rating" {
+			t.Errorf("expected no rating metric without ratings")
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package web
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"time"
+
+	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+)
+
+// Punchcard colour modes selected with the "color" query parameter.
+const (
+	punchcardColorCount    = "count"
+	punchcardColorDuration = "duration"
+)
+
+// Punchcard chart layout constants.
+const (
+	punchPaddingLeft   = 50
+	punchPaddingTop    = 10
+	punchPaddingBottom = 30
+	punchStep          = 30 // distance between dot centres, both axes
+	punchMaxRadius     = 12
+)
+
+// punchcardWeekdays lists the punchcard rows from top to bottom.
+var punchcardWeekdays = []time.Weekday{
+	time.Monday, time.Tuesday, time.Wednesday, time.Thursday,
+	time.Friday, time.Saturday, time.Sunday,
+}
+
+// PunchcardDot is a single weekday/hour circle on the punchcard.
+type PunchcardDot struct {
+	X      int
+	Y      int
+	Radius int
+	Level  int    // 0–4 colour level; by count, or by average duration
+	Label  string // e.g. "Tue 08:00–09:00: 12 journeys, avg 35m"
+}
+
+// HourLabel positions an hour label on the X-axis of the punchcard.
+type HourLabel struct {
+	X     int
+	Label string
+}
+
+// PunchcardData is passed to the punchcard template.
+type PunchcardData struct {
+	Dots             []PunchcardDot
+	DayLabels        []TimeLabel
+	HourLabels       []HourLabel
+	SVGWidth         int
+	SVGHeight        int
+	ChartLeft        int // x of the first hour column's left edge
+	LabelY           int // y for hour labels
+	TotalJourneys    int
+	BusiestSlot      string // e.g. "Tue 08:00"
+	AvgDuration      string
+	ColorBy          string // punchcardColorCount or punchcardColorDuration
+	DateRangeOptions []DateRangeOption
+	Range            DateRange
+	Nav              Nav
+}
+
+func (h *Handler) handlePunchcard(w http.ResponseWriter, r *http.Request) {
+	sel, ok := h.selectCards(w, r, "punchcard", false)
+	if !ok {
+		return
+	}
+
+	ctx := bq.WithPage(r.Context(), "punchcard")
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	rng, err := parseDateRange(r.URL.Query(), today)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	colorBy := parseColorParam(r.URL.Query().Get("color"))
+	counts, err := sel.Card.Client.JourneysByHour(ctx, h.tagged(sel.Notes, sel.Tag, rng.journeyFilter(today)))
+	if err != nil {
+		slog.Error("querying bigquery for punchcard", "card", sel.Card.ID, "error", err)
+		http.Error(w, "failed to load journey data", http.StatusInternalServerError)
+		return
+	}
+
+	data := buildPunchcardData(counts, rng, colorBy)
+	data.Nav = sel.Nav
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.tmpl.ExecuteTemplate(w, "punchcard.html", data); err != nil {
+		slog.Error("rendering punchcard template", "error", err)
+	}
+}
+
+// parseColorParam parses the "color" URL query parameter. Any value other
+// than "duration" selects colouring by journey count.
+func parseColorParam(s string) string {
+	if s == punchcardColorDuration {
+		return punchcardColorDuration
+	}
+	return punchcardColorCount
+}
+
+// buildPunchcardData lays out hourly counts as a 7 × 24 grid of circles whose
+// area is proportional to the number of journeys. Circles are coloured by
+// count, or by average journey duration when colorBy is "duration".
+func buildPunchcardData(counts []bq.HourCount, rng DateRange, colorBy string) PunchcardData {
+	type slot struct {
+		weekday time.Weekday
+		hour    int
+	}
+	lookup := make(map[slot]bq.HourCount, len(counts))
+	maxCount := 0
+	minAvg, maxAvg := math.Inf(1), math.Inf(-1)
+	totalJourneys := 0
+	var totalMinutes float64
+	var timedJourneys int
+	for _, hc := range counts {
+		if hc.Hour < 0 || hc.Hour > 23 || hc.Count <= 0 {
+			continue
+		}
+		lookup[slot{hc.Weekday, hc.Hour}] = hc
+		totalJourneys += hc.Count
+		if hc.Count > maxCount {
+			maxCount = hc.Count
+		}
+		if hc.AvgMinutes > 0 {
+			minAvg = math.Min(minAvg, hc.AvgMinutes)
+			maxAvg = math.Max(maxAvg, hc.AvgMinutes)
+			totalMinutes += hc.AvgMinutes * float64(hc.Count)
+			timedJourneys += hc.Count
+		}
+	}
+
+	var dots []PunchcardDot
+	var dayLabels []TimeLabel
+	busiestSlot, busiestCount := "–", 0
+	for row, wd := range punchcardWeekdays {
+		y := punchPaddingTop + row*punchStep + punchStep/2
+		dayLabels = append(dayLabels, TimeLabel{Y: y, Label: wd.String()[:3]})
+		for hour := 0; hour < 24; hour++ {
+			hc, ok := lookup[slot{wd, hour}]
+			if !ok {
+				continue
+			}
+			slotName := fmt.Sprintf("%s %02d:00", wd.String()[:3], hour)
+			if hc.Count > busiestCount {
+				busiestCount = hc.Count
+				busiestSlot = slotName
+			}
+
+			label := fmt.Sprintf("%s–%02d:00: %d journeys", slotName, (hour+1)%24, hc.Count)
+			if hc.AvgMinutes > 0 {
+				label += ", avg " + formatDuration(int(math.Round(hc.AvgMinutes)))
+			}
+
+			level := intensityLevel(hc.Count, maxCount)
+			if colorBy == punchcardColorDuration {
+				level = durationLevel(hc.AvgMinutes, minAvg, maxAvg)
+			}
+
+			dots = append(dots, PunchcardDot{
+				X:      punchPaddingLeft + hour*punchStep + punchStep/2,
+				Y:      y,
+				Radius: punchRadius(hc.Count, maxCount),
+				Level:  level,
+				Label:  label,
+			})
+		}
+	}
+
+	var hourLabels []HourLabel
+	for hour := 0; hour < 24; hour += 3 {
+		hourLabels = append(hourLabels, HourLabel{
+			X:     punchPaddingLeft + hour*punchStep + punchStep/2,
+			Label: fmt.Sprintf("%02d:00", hour),
+		})
+	}
+
+	avgDuration := "–"
+	if timedJourneys > 0 {
+		avgDuration = formatDuration(int(math.Round(totalMinutes / float64(timedJourneys))))
+	}
+
+	plotBottom := punchPaddingTop + len(punchcardWeekdays)*punchStep
+	return PunchcardData{
+		Dots:             dots,
+		DayLabels:        dayLabels,
+		HourLabels:       hourLabels,
+		SVGWidth:         punchPaddingLeft + 24*punchStep,
+		SVGHeight:        plotBottom + punchPaddingBottom,
+		ChartLeft:        punchPaddingLeft,
+		LabelY:           plotBottom + 15,
+		TotalJourneys:    totalJourneys,
+		BusiestSlot:      busiestSlot,
+		AvgDuration:      avgDuration,
+		ColorBy:          colorBy,
+		DateRangeOptions: buildDateRangeOptions(rng.Days),
+		Range:            rng,
+	}
+}
+
+// punchRadius returns the radius of a punchcard circle so that its area is
+// proportional to count, with the busiest slot drawn at punchMaxRadius.
+func punchRadius(count, max int) int {
+	if count <= 0 || max <= 0 {
+		return 0
+	}
+	r := int(math.Round(punchMaxRadius * math.Sqrt(float64(count)/float64(max))))
+	if r < 2 {
+		r = 2 // keep single journeys visible
+	}
+	return r
+}
+
+// durationLevel returns a 1–4 level placing avg within the range of average
+// durations [min, max]. It returns 0 when the slot has no timed journeys.
+func durationLevel(avg, min, max float64) int {
+	if avg <= 0 {
+		return 0
+	}
+	if max <= min {
+		return 1
+	}
+	ratio := (avg - min) / (max - min)
+	switch {
+	case ratio <= 0.25:
+		return 1
+	case ratio <= 0.50:
+		return 2
+	case ratio <= 0.75:
+		return 3
+	default:
+		return 4
+	}
+}
@@ -0,0 +1,115 @@
+package web
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+)
+
+func TestBuildPunchcardData_Empty(t *testing.T) {
+	data := buildPunchcardData(nil, DateRange{Days: 30}, punchcardColorCount)
+
+	if len(data.Dots) != 0 {
+		t.Errorf("expected no dots, got %d", len(data.Dots))
+	}
+	if len(data.DayLabels) != 7 || data.DayLabels[0].Label != "Mon" || data.DayLabels[6].Label != "Sun" {
+		t.Errorf("day labels = %+v, want Mon…Sun", data.DayLabels)
+	}
+	if len(data.HourLabels) != 8 {
+		t.Errorf("expected 8 hour labels, got %d", len(data.HourLabels))
+	}
+	if data.BusiestSlot != "–" || data.AvgDuration != "–" {
+		t.Errorf("expected '–' stats, got busiest %q, avg %q", data.BusiestSlot, data.AvgDuration)
+	}
+}
+
+func TestBuildPunchcardData_Layout(t *testing.T) {
+	counts := []bq.HourCount{
+		{Weekday: time.Tuesday, Hour: 8, Count: 16, AvgMinutes: 40},
+		{Weekday: time.Sunday, Hour: 14, Count: 4, AvgMinutes: 20},
+		{Weekday: time.Monday, Hour: 25, Count: 3}, // out of range, ignored
+	}
+	data := buildPunchcardData(counts, DateRange{}, punchcardColorCount)
+
+	if len(data.Dots) != 2 {
+		t.Fatalf("expected 2 dots, got %d", len(data.Dots))
+	}
+	if data.TotalJourneys != 20 {
+		t.Errorf("TotalJourneys = %d, want 20", data.TotalJourneys)
+	}
+	if data.BusiestSlot != "Tue 08:00" {
+		t.Errorf("BusiestSlot = %q, want %q", data.BusiestSlot, "Tue 08:00")
+	}
+	// Weighted by count: (16×40 + 4×20) / 20 = 36.
+	if data.AvgDuration != "36m" {
+		t.Errorf("AvgDuration = %q, want %q", data.AvgDuration, "36m")
+	}
+
+	tue, sun := data.Dots[0], data.Dots[1]
+	if tue.X != punchPaddingLeft+8*punchStep+punchStep/2 || tue.Y != punchPaddingTop+1*punchStep+punchStep/2 {
+		t.Errorf("Tue 08:00 dot at (%d, %d)", tue.X, tue.Y)
+	}
+	if sun.Y != punchPaddingTop+6*punchStep+punchStep/2 {
+		t.Errorf("Sunday should be the last row, got y %d", sun.Y)
+	}
+	// Area is proportional to count: a quarter of the journeys is half the radius.
+	if tue.Radius != punchMaxRadius || sun.Radius != punchMaxRadius/2 {
+		t.Errorf("radii = %d, %d; want %d, %d", tue.Radius, sun.Radius, punchMaxRadius, punchMaxRadius/2)
+	}
+	if tue.Level != 4 || sun.Level != 1 {
+		t.Errorf("count levels = %d, %d; want 4, 1", tue.Level, sun.Level)
+	}
+	if !strings.Contains(tue.Label, "Tue 08:00–09:00: 16 journeys, avg 40m") {
+		t.Errorf("unexpected label %q", tue.Label)
+	}
+}
+
+func TestBuildPunchcardData_ColorByDuration(t *testing.T) {
+	counts := []bq.HourCount{
+		{Weekday: time.Monday, Hour: 8, Count: 10, AvgMinutes: 60},
+		{Weekday: time.Monday, Hour: 9, Count: 1, AvgMinutes: 20},
+		{Weekday: time.Monday, Hour: 10, Count: 5},
+	}
+	data := buildPunchcardData(counts, DateRange{Days: 30}, punchcardColorDuration)
+
+	want := []int{4, 1, 0}
+	for i, d := range data.Dots {
+		if d.Level != want[i] {
+			t.Errorf("dot %d: level = %d, want %d", i, d.Level, want[i])
+		}
+	}
+	if data.ColorBy != punchcardColorDuration {
+		t.Errorf("ColorBy = %q, want %q", data.ColorBy, punchcardColorDuration)
+	}
+}
+
+func TestPunchRadius(t *testing.T) {
+	tests := []struct {
+		count, max, want int
+	}{
+		{0, 10, 0},
+		{10, 10, punchMaxRadius},
+		{1, 1000, 2}, // minimum visible size
+	}
+	for _, tt := range tests {
+		if got := punchRadius(tt.count, tt.max); got != tt.want {
+			t.Errorf("punchRadius(%d, %d) = %d, want %d", tt.count, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestParseColorParam(t *testing.T) {
+	tests := map[string]string{
+		"":         punchcardColorCount,
+		"count":    punchcardColorCount,
+		"duration": punchcardColorDuration,
+		"bogus":    punchcardColorCount,
+	}
+	for in, want := range tests {
+		if got := parseColorParam(in); got != want {
+			t.Errorf("parseColorParam(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
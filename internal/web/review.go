@@ -0,0 +1,344 @@
+package web
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+)
+
+const (
+	// reviewTopN is the number of stations and routes listed in the review.
+	reviewTopN = 5
+	// reviewConsistencyMinutes is how close to the typical duration a commute
+	// must be to count as consistent.
+	reviewConsistencyMinutes = 10
+	// reviewFirstYear is the earliest year a review can be requested for.
+	reviewFirstYear = 2000
+)
+
+// Geometry of the month-by-month small multiples: one bar per day.
+const (
+	reviewBarStep   = 5
+	reviewBarWidth  = 4
+	reviewBarHeight = 40
+)
+
+// ReviewCount is a station or route with the number of times it was used.
+type ReviewCount struct {
+	Name    string
+	Count   int
+	Percent int // relative to the most used entry, for bar widths
+}
+
+// ReviewJourney describes a single notable journey.
+type ReviewJourney struct {
+	Date     string // e.g. "Wed 12 Jun"
+	Route    string
+	Duration string // e.g. "1h 52m"
+}
+
+// ReviewBar is a single day in a month's small multiple.
+type ReviewBar struct {
+	X, Y, Height int
+	Label        string // e.g. "12 Jun: 4 journeys"
+}
+
+// ReviewMonth summarises one month of the year.
+type ReviewMonth struct {
+	Name     string // e.g. "Jan"
+	Journeys int
+	Spend    string
+	Busiest  bool
+	Bars     []ReviewBar
+}
+
+// ReviewData is passed to the review template.
+type ReviewData struct {
+	Year           int
+	Journeys       int
+	ActiveDays     int
+	Spend          string
+	Stations       []ReviewCount
+	Routes         []ReviewCount
+	Longest        *ReviewJourney // nil without timed journeys
+	BusiestMonth   string         // e.g. "March"; empty without journeys
+	Commutes       int
+	TypicalCommute string // median commute duration
+	Consistency    int    // % of commutes within reviewConsistencyMinutes of the median
+	AverageRating  string // e.g. "3.8"; empty without ratings
+	Ratings        int
+	Narrative      []string
+	Months         []ReviewMonth
+	ChartWidth     int
+	ChartHeight    int
+	PrevYear       int  // 0 when there is no earlier year to link to
+	NextYear       int  // 0 when the next year has not started
+	Static         bool // rendered as a standalone file, without navigation
+	Nav            Nav
+}
+
+// handleReviewRedirect sends /review to the review of the current year.
+func (h *Handler) handleReviewRedirect(w http.ResponseWriter, r *http.Request) {
+	target := fmt.Sprintf("/review/%d", time.Now().UTC().Year())
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+func (h *Handler) handleReview(w http.ResponseWriter, r *http.Request) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	year, err := strconv.Atoi(r.PathValue("year"))
+	if err != nil || year < reviewFirstYear || year > today.Year() {
+		http.NotFound(w, r)
+		return
+	}
+
+	sel, ok := h.selectCards(w, r, "review", false)
+	if !ok {
+		return
+	}
+
+	from := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(1, 0, -1)
+	if to.After(today) {
+		to = today
+	}
+	ctx := bq.WithPage(r.Context(), "review")
+	rng := DateRange{Days: customRangeDays, From: from, To: to}
+	filter := h.tagged(sel.Notes, sel.Tag, rng.journeyFilter(today))
+	counts, err := sel.Card.Client.JourneyCountsByDay(ctx, filter)
+	if err != nil {
+		slog.Error("querying bigquery", "card", sel.Card.ID, "error", err)
+		http.Error(w, "failed to load journey data", http.StatusInternalServerError)
+		return
+	}
+	journeys, err := sel.Card.Client.CommuteJourneys(ctx, filter)
+	if err != nil {
+		slog.Error("querying bigquery for journeys", "card", sel.Card.ID, "error", err)
+		http.Error(w, "failed to load journey data", http.StatusInternalServerError)
+		return
+	}
+	commutes, err := sel.Card.Client.CommuteJourneys(ctx, h.tagged(sel.Notes, sel.Tag, h.commuteFilter(sel.Notes, rng, today)))
+	if err != nil {
+		slog.Error("querying bigquery for commutes", "card", sel.Card.ID, "error", err)
+		http.Error(w, "failed to load commute data", http.StatusInternalServerError)
+		return
+	}
+	ratings, err := sel.Card.Client.Ratings(ctx, filter)
+	if err != nil {
+		// Ratings are optional; log and continue without them.
+		slog.Warn("querying bigquery for ratings", "card", sel.Card.ID, "error", err)
+	}
+
+	commutes = withoutDaysOff(commutes, h.opts.Calendar)
+	data := buildReviewData(year, today, counts, journeys, commutes, ratings)
+	data.Nav = sel.Nav
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if r.URL.Query().Get("download") != "" {
+		data.Static = true
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="pearl-review-%d.html"`, year))
+	}
+	if err := h.tmpl.ExecuteTemplate(w, "review.html", data); err != nil {
+		slog.Error("rendering review template", "error", err)
+	}
+}
+
+// buildReviewData summarises a year of travel: totals from counts, stations,
+// routes and the longest journey from journeys, commute consistency from
+// commutes (journeys tagged as commutes without days off) and the average of
+// ratings. today bounds the year in progress.
+func buildReviewData(year int, today time.Time, counts []bq.DayCount, journeys, commutes []bq.CommuteJourney, ratings []bq.DailyRating) ReviewData {
+	data := ReviewData{
+		Year:        year,
+		ChartWidth:  31 * reviewBarStep,
+		ChartHeight: reviewBarHeight,
+	}
+	if year > reviewFirstYear {
+		data.PrevYear = year - 1
+	}
+	if year < today.Year() {
+		data.NextYear = year + 1
+	}
+
+	// Totals and month-by-month small multiples.
+	var spend float64
+	var monthSpend [12]float64
+	var monthJourneys [12]int
+	daily := make(map[string]int)
+	maxDaily := 0
+	for _, dc := range counts {
+		if dc.Date.Year() != year {
+			continue
+		}
+		data.Journeys += dc.Count
+		spend += dc.Spend
+		if dc.Count > 0 {
+			data.ActiveDays++
+		}
+		monthJourneys[dc.Date.Month()-1] += dc.Count
+		monthSpend[dc.Date.Month()-1] += dc.Spend
+		daily[dc.Date.Format("2006-01-02")] = dc.Count
+		maxDaily = max(maxDaily, dc.Count)
+	}
+	data.Spend = formatPounds(spend)
+
+	busiest := -1
+	for m := range 12 {
+		if monthJourneys[m] > 0 && (busiest < 0 || monthJourneys[m] > monthJourneys[busiest]) {
+			busiest = m
+		}
+	}
+	for m := range 12 {
+		first := time.Date(year, time.Month(m+1), 1, 0, 0, 0, 0, time.UTC)
+		month := ReviewMonth{
+			Name:     first.Format("Jan"),
+			Journeys: monthJourneys[m],
+			Spend:    formatPounds(monthSpend[m]),
+			Busiest:  m == busiest,
+		}
+		for d := first; d.Month() == first.Month(); d = d.AddDate(0, 0, 1) {
+			count := daily[d.Format("2006-01-02")]
+			if count == 0 || maxDaily == 0 {
+				continue
+			}
+			height := max(1, count*reviewBarHeight/maxDaily)
+			month.Bars = append(month.Bars, ReviewBar{
+				X:      (d.Day() - 1) * reviewBarStep,
+				Y:      reviewBarHeight - height,
+				Height: height,
+				Label:  fmt.Sprintf("%s: %d journeys", d.Format("02 Jan"), count),
+			})
+		}
+		data.Months = append(data.Months, month)
+	}
+	if busiest >= 0 {
+		data.BusiestMonth = time.Month(busiest + 1).String()
+	}
+
+	// Stations, routes and the longest journey.
+	stations := make(map[string]int)
+	routes := make(map[string]int)
+	longest := -1
+	for _, j := range journeys {
+		route := strings.TrimSpace(j.JourneyAction)
+		if route != "" {
+			routes[route]++
+		}
+		from, to := journeyStations(route)
+		for _, s := range []string{from, to} {
+			if s != "" {
+				stations[s]++
+			}
+		}
+
+		t, err := parseJourneyDate(j.Date)
+		if err != nil {
+			continue
+		}
+		start, err1 := parseTimeToMinutes(j.StartTime)
+		end, err2 := parseTimeToMinutes(j.EndTime)
+		if err1 != nil || err2 != nil || end <= start {
+			continue
+		}
+		if d := end - start; d > longest {
+			longest = d
+			data.Longest = &ReviewJourney{Date: t.Format("Mon 02 Jan"), Route: route, Duration: formatDuration(d)}
+		}
+	}
+	data.Stations = topCounts(stations, reviewTopN)
+	data.Routes = topCounts(routes, reviewTopN)
+
+	// Commute consistency.
+	yearRange := DateRange{Days: customRangeDays, From: time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC), To: time.Date(year, 12, 31, 0, 0, 0, 0, time.UTC)}
+	points := buildCommuteData(commutes, nil, yearRange).Commutes
+	durations := buildDurationStats(points)
+	data.Commutes = durations.Count
+	if durations.Count > 0 {
+		data.TypicalCommute = formatMinutes(durations.P50Minutes)
+		consistent := 0
+		for _, p := range points {
+			if math.Abs(float64(p.Minutes)-durations.P50Minutes) <= reviewConsistencyMinutes {
+				consistent++
+			}
+		}
+		data.Consistency = consistent * 100 / durations.Count
+	}
+
+	var ratingSum float64
+	for _, r := range ratings {
+		if r.Date.Year() == year {
+			ratingSum += r.Rating
+			data.Ratings++
+		}
+	}
+	if data.Ratings > 0 {
+		data.AverageRating = fmt.Sprintf("%.1f", ratingSum/float64(data.Ratings))
+	}
+
+	data.Narrative = reviewNarrative(data)
+	return data
+}
+
+// topCounts returns the n entries of counts used most often, most used first
+// and ties broken alphabetically.
+func topCounts(counts map[string]int, n int) []ReviewCount {
+	top := make([]ReviewCount, 0, len(counts))
+	for name, c := range counts {
+		top = append(top, ReviewCount{Name: name, Count: c})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Name < top[j].Name
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+	for i := range top {
+		top[i].Percent = top[i].Count * 100 / top[0].Count
+	}
+	return top
+}
+
+// reviewNarrative describes the year in a few sentences.
+func reviewNarrative(d ReviewData) []string {
+	if d.Journeys == 0 {
+		return []string{fmt.Sprintf("No journeys were recorded in %d.", d.Year)}
+	}
+	lines := []string{fmt.Sprintf("In %d you made %d journeys on %d days and spent %s.", d.Year, d.Journeys, d.ActiveDays, d.Spend)}
+	if d.BusiestMonth != "" {
+		for _, m := range d.Months {
+			if m.Busiest {
+				lines = append(lines, fmt.Sprintf("%s was your busiest month, with %d journeys.", d.BusiestMonth, m.Journeys))
+			}
+		}
+	}
+	if len(d.Stations) > 0 {
+		s := d.Stations[0]
+		lines = append(lines, fmt.Sprintf("You passed through %s more than any other station: %d times.", s.Name, s.Count))
+	}
+	if len(d.Routes) > 0 {
+		lines = append(lines, fmt.Sprintf("Your most travelled route was %s, %d times.", d.Routes[0].Name, d.Routes[0].Count))
+	}
+	if d.Longest != nil {
+		lines = append(lines, fmt.Sprintf("Your longest journey took %s: %s on %s.", d.Longest.Duration, d.Longest.Route, d.Longest.Date))
+	}
+	if d.Commutes > 0 {
+		lines = append(lines, fmt.Sprintf("%d%% of your %d commutes were within %d minutes of the typical %s.",
+			d.Consistency, d.Commutes, reviewConsistencyMinutes, d.TypicalCommute))
+	}
+	if d.AverageRating != "" {
+		lines = append(lines, fmt.Sprintf("You rated your days %s out of 5 on average.", d.AverageRating))
+	}
+	return lines
+}
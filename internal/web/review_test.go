@@ -0,0 +1,89 @@
+package web
+
+import (
+	"strings"
+	"testing"
+
+	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+)
+
+func TestTopCounts(t *testing.T) {
+	got := topCounts(map[string]int{"Bank": 4, "Angel": 2, "Oval": 2, "Kew": 1}, 3)
+	want := []ReviewCount{{"Bank", 4, 100}, {"Angel", 2, 50}, {"Oval", 2, 50}}
+	if len(got) != len(want) {
+		t.Fatalf("topCounts() returned %d entries, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("topCounts()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if got := topCounts(nil, 3); len(got) != 0 {
+		t.Errorf("topCounts(nil) = %+v, want empty", got)
+	}
+}
+
+func TestBuildReviewData(t *testing.T) {
+	counts := []bq.DayCount{
+		{Date: mustDate("2024-03-05"), Count: 4, Spend: 11.2},
+		{Date: mustDate("2024-03-06"), Count: 2, Spend: 5.6},
+		{Date: mustDate("2024-06-12"), Count: 3, Spend: 8.4},
+		{Date: mustDate("2023-12-31"), Count: 9, Spend: 20}, // another year
+	}
+	journeys := []bq.CommuteJourney{
+		{Date: "2024-03-05", StartTime: "08:00", EndTime: "08:40", JourneyAction: "Clapham Common to Bank"},
+		{Date: "2024-03-05", StartTime: "18:00", EndTime: "18:45", JourneyAction: "Bank to Clapham Common"},
+		{Date: "2024-03-06", StartTime: "08:00", EndTime: "08:42", JourneyAction: "Clapham Common to Bank"},
+		{Date: "2024-06-12", StartTime: "10:45", EndTime: "12:37", JourneyAction: "Bank to Heathrow Terminal 5"},
+	}
+	ratings := []bq.DailyRating{{Date: mustDate("2024-03-05"), Rating: 4}, {Date: mustDate("2024-03-06"), Rating: 3}}
+
+	// The commute query returns the morning journeys to Bank.
+	commutes := []bq.CommuteJourney{journeys[0], journeys[2]}
+
+	data := buildReviewData(2024, mustDate("2024-10-18"), counts, journeys, commutes, ratings)
+
+	if data.Journeys != 9 || data.ActiveDays != 3 || data.Spend != "£25.20" {
+		t.Errorf("totals = %d journeys, %d days, %s; want 9, 3, £25.20", data.Journeys, data.ActiveDays, data.Spend)
+	}
+	if data.BusiestMonth != "March" || !data.Months[2].Busiest || data.Months[2].Journeys != 6 {
+		t.Errorf("busiest month = %q, March = %+v", data.BusiestMonth, data.Months[2])
+	}
+	if len(data.Months) != 12 || len(data.Months[2].Bars) != 2 {
+		t.Fatalf("expected 12 months with 2 bars in March, got %d months", len(data.Months))
+	}
+	if bar := data.Months[2].Bars[0]; bar.Height != reviewBarHeight || bar.X != 4*reviewBarStep {
+		t.Errorf("5 Mar bar = %+v, want full height at day 5", bar)
+	}
+	if len(data.Stations) == 0 || data.Stations[0].Name != "Bank" || data.Stations[0].Count != 4 {
+		t.Errorf("Stations = %+v, want Bank first with 4", data.Stations)
+	}
+	if len(data.Routes) == 0 || data.Routes[0].Name != "Clapham Common to Bank" || data.Routes[0].Count != 2 {
+		t.Errorf("Routes = %+v", data.Routes)
+	}
+	if data.Longest == nil || data.Longest.Duration != "1h 52m" || data.Longest.Date != "Wed 12 Jun" {
+		t.Errorf("Longest = %+v, want 1h 52m on Wed 12 Jun", data.Longest)
+	}
+	if data.Commutes != 2 || data.Consistency != 100 {
+		t.Errorf("commutes = %d, consistency = %d%%, want 2 and 100%%", data.Commutes, data.Consistency)
+	}
+	if data.AverageRating != "3.5" {
+		t.Errorf("AverageRating = %q, want %q", data.AverageRating, "3.5")
+	}
+	if data.PrevYear != 2023 || data.NextYear != 0 {
+		t.Errorf("PrevYear, NextYear = %d, %d, want 2023, 0", data.PrevYear, data.NextYear)
+	}
+	if len(data.Narrative) == 0 || !strings.HasPrefix(data.Narrative[0], "In 2024 you made 9 journeys on 3 days") {
+		t.Errorf("Narrative = %q", data.Narrative)
+	}
+}
+
+func TestBuildReviewDataEmpty(t *testing.T) {
+	data := buildReviewData(2023, mustDate("2024-10-18"), nil, nil, nil, nil)
+	if data.NextYear != 2024 || data.BusiestMonth != "" || data.Longest != nil {
+		t.Errorf("empty review = %+v", data)
+	}
+	if len(data.Narrative) != 1 || data.Narrative[0] != "No journeys were recorded in 2023." {
+		t.Errorf("Narrative = %q", data.Narrative)
+	}
+}
@@ -0,0 +1,330 @@
+package web
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+	"github.com/its-the-vibe/pearl/internal/stations"
+)
+
+const (
+	// stationDefaultDays is the preset date range of a station page when
+	// none is requested.
+	stationDefaultDays = 90
+	// stationTopN is the number of destinations listed on a station page.
+	stationTopN = 10
+	// stationWeeklyMaxDays is the longest range charted by week; longer
+	// ranges are charted by month.
+	stationWeeklyMaxDays = 182
+)
+
+// Geometry of the station page bar charts.
+const (
+	stationChartWidth  = 600
+	stationChartHeight = 80
+	stationHourStep    = 20 // width of each hour of the time-of-day chart
+)
+
+// StationBar is a single bar in a station page chart.
+type StationBar struct {
+	X, Y, Width, Height int
+	Label               string // e.g. "w/c 04 Mar: 6 journeys"
+}
+
+// StationDestination is a station travelled to from the selected station.
+type StationDestination struct {
+	Name        string
+	Journeys    int
+	AvgDuration string // empty without timed journeys
+	Percent     int    // relative to the most travelled destination, for bar widths
+}
+
+// StationData is passed to the station template.
+type StationData struct {
+	Name             string // as requested, or the matched station's name
+	Known            bool   // found in the station reference data
+	Zone             string
+	Lines            string // e.g. "Central, Northern"
+	Journeys         int    // journeys starting or ending here
+	Departures       int
+	Arrivals         int
+	Spend            string // charged for journeys starting or ending here
+	LastVisit        string // e.g. "Tue 05 Mar 2024"; empty without journeys
+	BusiestHour      string // e.g. "08:00–09:00"
+	Periods          []StationBar
+	PeriodLabel      string // "week" or "month"
+	Hours            []StationBar
+	Destinations     []StationDestination
+	ChartWidth       int
+	ChartHeight      int
+	HoursWidth       int
+	DateRangeOptions []DateRangeOption
+	Range            DateRange
+	RangeForm        DateRangeForm
+	Nav              Nav
+}
+
+func (h *Handler) handleStation(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSpace(r.PathValue("name"))
+	if name == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	sel, ok := h.selectCards(w, r, "station", false)
+	if !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	rng, err := parseDateRangeDefault(q, today, stationDefaultDays)
+	if err != nil {
+		data := buildStationData(name, nil, h.opts.Stations, DateRange{Days: customRangeDays}, today)
+		data.RangeForm = DateRangeForm{From: q.Get("from"), To: q.Get("to"), Error: err.Error()}
+		data.Nav = sel.Nav
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusBadRequest)
+		if err := h.tmpl.ExecuteTemplate(w, "station.html", data); err != nil {
+			slog.Error("rendering station template", "error", err)
+		}
+		return
+	}
+
+	ctx := bq.WithPage(r.Context(), "station")
+	journeys, err := sel.Card.Client.Journeys(ctx, h.tagged(sel.Notes, sel.Tag, rng.journeyFilter(today)))
+	if err != nil {
+		slog.Error("querying bigquery for journeys", "card", sel.Card.ID, "error", err)
+		http.Error(w, "failed to load journey data", http.StatusInternalServerError)
+		return
+	}
+
+	data := buildStationData(name, journeys, h.opts.Stations, rng, today)
+	data.RangeForm = rng.Form()
+	data.Nav = sel.Nav
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.tmpl.ExecuteTemplate(w, "station.html", data); err != nil {
+		slog.Error("rendering station template", "error", err)
+	}
+}
+
+// stationResolver maps station names as they appear in journey actions to a
+// canonical name, so that aliases and misspellings of a station in dir are
+// treated as the same station. Names dir does not know are kept as they are.
+func stationResolver(dir *stations.Directory) func(string) string {
+	cache := make(map[string]string)
+	return func(name string) string {
+		if canonical, ok := cache[name]; ok {
+			return canonical
+		}
+		canonical := name
+		if s, _, ok := dir.Match(name); ok {
+			canonical = s.Name
+		}
+		cache[name] = canonical
+		return canonical
+	}
+}
+
+// buildStationData summarises the journeys that start or end at the station
+// called name: counts by week or month over rng, departures and arrivals by
+// hour of day, and the destinations travelled to from it.
+func buildStationData(name string, journeys []bq.Journey, dir *stations.Directory, rng DateRange, today time.Time) StationData {
+	resolve := stationResolver(dir)
+	data := StationData{
+		Name:             resolve(name),
+		Periods:          []StationBar{},
+		Hours:            []StationBar{},
+		Destinations:     []StationDestination{},
+		ChartWidth:       stationChartWidth,
+		ChartHeight:      stationChartHeight,
+		HoursWidth:       24 * stationHourStep,
+		DateRangeOptions: buildDateRangeOptions(rng.Days),
+		Range:            rng,
+	}
+	if s, _, ok := dir.Match(name); ok {
+		data.Known = true
+		data.Zone = s.Zone()
+		data.Lines = strings.Join(s.Lines, ", ")
+	}
+
+	var spend float64
+	var last time.Time
+	var days []time.Time
+	var hours [24]int
+	destinations := make(map[string]int)
+	durations := make(map[string][]int)
+	for _, j := range journeys {
+		action := strings.TrimSpace(j.JourneyAction)
+		from, to := journeyStations(action)
+		if strings.HasPrefix(action, "Entered ") {
+			// A touch in without a recorded exit departs from the station.
+			from, to = to, ""
+		}
+		departs := from != "" && strings.EqualFold(resolve(from), data.Name)
+		arrives := to != "" && strings.EqualFold(resolve(to), data.Name)
+		if !departs && !arrives {
+			continue
+		}
+		t, err := parseJourneyDate(j.Date)
+		if err != nil {
+			continue
+		}
+		data.Journeys++
+		spend += j.Charge
+		days = append(days, t)
+		if t.After(last) {
+			last = t
+		}
+
+		if departs {
+			data.Departures++
+			if start, err := parseTimeToMinutes(j.StartTime); err == nil {
+				hours[start/60]++
+			}
+			if to != "" && !arrives {
+				dest := resolve(to)
+				destinations[dest]++
+				start, err1 := parseTimeToMinutes(j.StartTime)
+				end, err2 := parseTimeToMinutes(j.EndTime)
+				if err1 == nil && err2 == nil && end > start {
+					durations[dest] = append(durations[dest], end-start)
+				}
+			}
+		}
+		if arrives {
+			data.Arrivals++
+			// A touch out without a recorded entry has only a start time.
+			end := j.EndTime
+			if end == "" {
+				end = j.StartTime
+			}
+			if m, err := parseTimeToMinutes(end); err == nil {
+				hours[m/60]++
+			}
+		}
+	}
+	data.Spend = formatPounds(spend)
+	if data.Journeys == 0 {
+		return data
+	}
+	data.LastVisit = last.Format("Mon 02 Jan 2006")
+
+	// Journeys by hour of day.
+	busiest, maxHour := 0, 0
+	for hr, n := range hours {
+		if n > maxHour {
+			busiest, maxHour = hr, n
+		}
+	}
+	if maxHour > 0 {
+		data.BusiestHour = fmt.Sprintf("%02d:00–%02d:00", busiest, (busiest+1)%24)
+		for hr, n := range hours {
+			if n == 0 {
+				continue
+			}
+			height := max(1, n*stationChartHeight/maxHour)
+			data.Hours = append(data.Hours, StationBar{
+				X:      hr * stationHourStep,
+				Y:      stationChartHeight - height,
+				Width:  stationHourStep - 2,
+				Height: height,
+				Label:  fmt.Sprintf("%02d:00–%02d:00: %d journeys", hr, (hr+1)%24, n),
+			})
+		}
+	}
+
+	data.Periods, data.PeriodLabel = stationPeriods(days, rng, today)
+	data.Destinations = stationDestinations(destinations, durations)
+	return data
+}
+
+// stationPeriods charts the number of journeys on days by week, or by month
+// for ranges longer than stationWeeklyMaxDays. Unbounded ranges start from the
+// earliest of days.
+func stationPeriods(days []time.Time, rng DateRange, today time.Time) ([]StationBar, string) {
+	from, to := rng.bounds(today)
+	if from.IsZero() {
+		from = slices.MinFunc(days, func(a, b time.Time) int { return a.Compare(b) })
+	}
+	if to.IsZero() || to.After(today) {
+		to = today
+	}
+
+	monthly := to.Sub(from) > stationWeeklyMaxDays*24*time.Hour
+	start := func(t time.Time) time.Time {
+		if monthly {
+			return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		}
+		return t.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7)) // Monday
+	}
+	next := func(t time.Time) time.Time {
+		if monthly {
+			return t.AddDate(0, 1, 0)
+		}
+		return t.AddDate(0, 0, 7)
+	}
+
+	counts := make(map[time.Time]int)
+	for _, d := range days {
+		counts[start(d)]++
+	}
+	var periods []time.Time
+	maxCount := 0
+	for p := start(from); !p.After(to); p = next(p) {
+		periods = append(periods, p)
+		maxCount = max(maxCount, counts[p])
+	}
+
+	bars := []StationBar{}
+	if maxCount == 0 {
+		return bars, "week"
+	}
+	step := stationChartWidth / len(periods)
+	for i, p := range periods {
+		n := counts[p]
+		if n == 0 {
+			continue
+		}
+		height := max(1, n*stationChartHeight/maxCount)
+		label := fmt.Sprintf("w/c %s: %d journeys", p.Format("02 Jan 2006"), n)
+		if monthly {
+			label = fmt.Sprintf("%s: %d journeys", p.Format("Jan 2006"), n)
+		}
+		bars = append(bars, StationBar{
+			X:      i * step,
+			Y:      stationChartHeight - height,
+			Width:  max(1, step-1),
+			Height: height,
+			Label:  label,
+		})
+	}
+	if monthly {
+		return bars, "month"
+	}
+	return bars, "week"
+}
+
+// stationDestinations lists the stationTopN most travelled destinations with
+// the average duration of the timed journeys to each.
+func stationDestinations(counts map[string]int, durations map[string][]int) []StationDestination {
+	dests := make([]StationDestination, 0, len(counts))
+	for _, c := range topCounts(counts, stationTopN) {
+		d := StationDestination{Name: c.Name, Journeys: c.Count, Percent: c.Percent}
+		if mins := durations[c.Name]; len(mins) > 0 {
+			total := 0
+			for _, m := range mins {
+				total += m
+			}
+			d.AvgDuration = formatDuration((total + len(mins)/2) / len(mins))
+		}
+		dests = append(dests, d)
+	}
+	return dests
+}
@@ -0,0 +1,89 @@
+package web
+
+import (
+	"testing"
+	"time"
+
+	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+	"github.com/its-the-vibe/pearl/internal/stations"
+)
+
+func TestBuildStationData(t *testing.T) {
+	dir, err := stations.Embedded()
+	if err != nil {
+		t.Fatalf("stations.Embedded() unexpected error: %v", err)
+	}
+	today := mustDate("2024-03-31")
+	journeys := []bq.Journey{
+		{Date: "2024-03-04", StartTime: "08:05", EndTime: "08:35", JourneyAction: "Clapham Common to Bank", Charge: 2.8},
+		{Date: "2024-03-04", StartTime: "18:10", EndTime: "18:50", JourneyAction: "Bank to Clapham Common", Charge: 2.8},
+		{Date: "2024-03-05", StartTime: "08:15", EndTime: "08:45", JourneyAction: "Clapham Common to Bank", Charge: 2.8},
+		{Date: "05-Mar-24", StartTime: "18:00", EndTime: "18:30", JourneyAction: "Bank to Clapham Common", Charge: 2.8},
+		{Date: "2024-03-12", StartTime: "17:30", EndTime: "17:50", JourneyAction: "Bank to Liverpool Street", Charge: 1.9},
+		{Date: "2024-03-13", StartTime: "08:20", JourneyAction: "Entered Bank [No touch-out]", Charge: 8.8},
+		{Date: "2024-03-13", StartTime: "09:00", EndTime: "09:30", JourneyAction: "Clapham Common to Oxford Circus", Charge: 2.8},
+	}
+	data := buildStationData("bank", journeys, dir, DateRange{Days: 30}, today)
+
+	if data.Name != "Bank" || !data.Known || data.Zone != "1" {
+		t.Errorf("station = %q known %v zone %q, want Bank in zone 1", data.Name, data.Known, data.Zone)
+	}
+	if data.Journeys != 6 || data.Departures != 4 || data.Arrivals != 2 {
+		t.Errorf("journeys = %d (%d out, %d in), want 6 (4 out, 2 in)", data.Journeys, data.Departures, data.Arrivals)
+	}
+	if data.Spend != "£21.90" {
+		t.Errorf("Spend = %q, want %q", data.Spend, "£21.90")
+	}
+	if data.LastVisit != "Wed 13 Mar 2024" {
+		t.Errorf("LastVisit = %q, want %q", data.LastVisit, "Wed 13 Mar 2024")
+	}
+	// Arrivals at 08:35 and 08:45 and a departure at 08:20.
+	if data.BusiestHour != "08:00–09:00" {
+		t.Errorf("BusiestHour = %q, want %q", data.BusiestHour, "08:00–09:00")
+	}
+	if data.PeriodLabel != "week" || len(data.Periods) != 2 {
+		t.Errorf("Periods = %+v by %s, want 2 weeks with journeys", data.Periods, data.PeriodLabel)
+	}
+
+	if len(data.Destinations) != 2 {
+		t.Fatalf("Destinations = %+v, want Clapham Common and Liverpool Street", data.Destinations)
+	}
+	if d := data.Destinations[0]; d.Name != "Clapham Common" || d.Journeys != 2 || d.AvgDuration != "35m" || d.Percent != 100 {
+		t.Errorf("Destinations[0] = %+v, want Clapham Common, 2 journeys averaging 35m", d)
+	}
+	if d := data.Destinations[1]; d.Name != "Liverpool Street" || d.AvgDuration != "20m" {
+		t.Errorf("Destinations[1] = %+v, want Liverpool Street averaging 20m", d)
+	}
+}
+
+func TestBuildStationData_Unknown(t *testing.T) {
+	data := buildStationData("Gatwick Airport", []bq.Journey{
+		{Date: "2024-03-04", StartTime: "07:00", EndTime: "07:50", JourneyAction: "Gatwick Airport to Victoria", Charge: 12.1},
+		{Date: "2024-03-04", StartTime: "09:00", EndTime: "09:30", JourneyAction: "Victoria to Bank", Charge: 2.8},
+	}, nil, DateRange{Days: 0}, mustDate("2024-03-31"))
+
+	if data.Known || data.Journeys != 1 || data.Departures != 1 {
+		t.Errorf("data = %+v, want one departure from an unknown station", data)
+	}
+	// An unbounded range is charted from the first journey.
+	if data.PeriodLabel != "week" || len(data.Periods) != 1 || data.Periods[0].X != 0 {
+		t.Errorf("Periods = %+v by %s, want a single week starting the chart", data.Periods, data.PeriodLabel)
+	}
+}
+
+func TestStationPeriods_Monthly(t *testing.T) {
+	bars, label := stationPeriods(
+		[]time.Time{mustDate("2023-01-10"), mustDate("2023-01-20"), mustDate("2023-06-01")},
+		DateRange{Days: customRangeDays, From: mustDate("2023-01-01"), To: mustDate("2023-12-31")},
+		mustDate("2024-03-31"),
+	)
+	if label != "month" || len(bars) != 2 {
+		t.Fatalf("stationPeriods() = %+v by %s, want 2 months with journeys", bars, label)
+	}
+	if bars[0].Label != "Jan 2023: 2 journeys" || bars[0].Height != stationChartHeight {
+		t.Errorf("bars[0] = %+v, want January at full height", bars[0])
+	}
+	if bars[1].X != 5*(stationChartWidth/12) {
+		t.Errorf("June bar at x = %d, want %d", bars[1].X, 5*(stationChartWidth/12))
+	}
+}
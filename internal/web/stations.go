@@ -0,0 +1,92 @@
+package web
+
+import (
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+	"github.com/its-the-vibe/pearl/internal/stations"
+)
+
+// StationMatch is a station name from the journey history and the station it
+// was matched to.
+type StationMatch struct {
+	Name     string // as it appears in journey actions
+	Journeys int
+	Station  string // matched station name; empty when unmatched
+	Zone     string // e.g. "2/3"
+}
+
+// StationReportData is passed to the stations template.
+type StationReportData struct {
+	Stations  int // stations in the reference data
+	Matched   int // distinct names matched exactly
+	Fuzzy     []StationMatch
+	Unmatched []StationMatch
+}
+
+// handleStationReport lists the station names in the last year of journeys
+// that the station reference data matches only approximately or not at all,
+// so that aliases can be added to the data file.
+func (h *Handler) handleStationReport(w http.ResponseWriter, r *http.Request) {
+	sel, ok := h.selectCards(w, r, "stations", false)
+	if !ok {
+		return
+	}
+
+	ctx := bq.WithPage(r.Context(), "stations")
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	actions, err := sel.Card.Client.JourneyActions(ctx, heatmapFilter(today))
+	if err != nil {
+		slog.Error("querying bigquery for journey actions", "card", sel.Card.ID, "error", err)
+		http.Error(w, "failed to load journey data", http.StatusInternalServerError)
+		return
+	}
+
+	data := buildStationReport(actions, h.opts.Stations)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.tmpl.ExecuteTemplate(w, "stations.html", data); err != nil {
+		slog.Error("rendering stations template", "error", err)
+	}
+}
+
+// buildStationReport matches the stations named in actions against dir.
+// Actions that name no station, such as bus journeys, are skipped. Both lists
+// are ordered by journeys, most first.
+func buildStationReport(actions []bq.ActionCount, dir *stations.Directory) StationReportData {
+	journeys := make(map[string]int)
+	for _, a := range actions {
+		from, to := journeyStations(strings.TrimSpace(a.Action))
+		for _, name := range []string{from, to} {
+			if name != "" {
+				journeys[name] += a.Count
+			}
+		}
+	}
+
+	data := StationReportData{Stations: dir.Len(), Fuzzy: []StationMatch{}, Unmatched: []StationMatch{}}
+	for name, n := range journeys {
+		s, fuzzy, ok := dir.Match(name)
+		switch {
+		case !ok:
+			data.Unmatched = append(data.Unmatched, StationMatch{Name: name, Journeys: n})
+		case fuzzy:
+			data.Fuzzy = append(data.Fuzzy, StationMatch{Name: name, Journeys: n, Station: s.Name, Zone: s.Zone()})
+		default:
+			data.Matched++
+		}
+	}
+	for _, list := range [][]StationMatch{data.Fuzzy, data.Unmatched} {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Journeys != list[j].Journeys {
+				return list[i].Journeys > list[j].Journeys
+			}
+			return list[i].Name < list[j].Name
+		})
+	}
+	return data
+}
@@ -0,0 +1,43 @@
+package web
+
+import (
+	"testing"
+
+	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+	"github.com/its-the-vibe/pearl/internal/stations"
+)
+
+func TestBuildStationReport(t *testing.T) {
+	dir, err := stations.Embedded()
+	if err != nil {
+		t.Fatalf("stations.Embedded() unexpected error: %v", err)
+	}
+	data := buildStationReport([]bq.ActionCount{
+		{Action: "Clapham Common to Bank", Count: 10},
+		{Action: "Bank to Clapham Common", Count: 8},
+		{Action: "Picadilly Circus to Bank", Count: 2},
+		{Action: "Gatwick Airport to Bank", Count: 1},
+		{Action: "Entered Lewisham [National Rail]", Count: 3},
+		{Action: "Bus journey, route 88", Count: 5},
+	}, dir)
+
+	if data.Stations != dir.Len() {
+		t.Errorf("Stations = %d, want %d", data.Stations, dir.Len())
+	}
+	if data.Matched != 2 {
+		t.Errorf("Matched = %d, want 2 (Bank and Clapham Common)", data.Matched)
+	}
+	if len(data.Fuzzy) != 1 || data.Fuzzy[0].Station != "Piccadilly Circus" || data.Fuzzy[0].Journeys != 2 {
+		t.Errorf("Fuzzy = %+v, want Picadilly Circus matched to Piccadilly Circus", data.Fuzzy)
+	}
+	if len(data.Unmatched) != 2 {
+		t.Fatalf("Unmatched = %+v, want Lewisham and Gatwick Airport", data.Unmatched)
+	}
+	if data.Unmatched[0].Name != "Lewisham" || data.Unmatched[1].Name != "Gatwick Airport" {
+		t.Errorf("Unmatched = %+v, want most journeys first", data.Unmatched)
+	}
+
+	if empty := buildStationReport(nil, nil); empty.Stations != 0 || len(empty.Unmatched) != 0 {
+		t.Errorf("buildStationReport(nil, nil) = %+v, want an empty report", empty)
+	}
+}
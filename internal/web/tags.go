@@ -0,0 +1,57 @@
+package web
+
+import (
+	"slices"
+	"time"
+
+	"github.com/its-the-vibe/pearl/internal/annotations"
+	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+	"github.com/its-the-vibe/pearl/internal/tags"
+)
+
+// tagFilter returns the filter selecting the journeys tagged with tag, by the
+// tag rules or by one of the annotations in notes.
+func (h *Handler) tagFilter(notes []annotations.Annotation, tag string) bq.TagFilter {
+	return bq.TagFilter{Rules: h.opts.Tags.For(tag), Annotated: tagSelection(notes, tag)}
+}
+
+// tagged narrows filter to the journeys tagged with tag, given the
+// annotations of their card in notes. An empty tag leaves the filter
+// unchanged.
+func (h *Handler) tagged(notes []annotations.Annotation, tag string, filter bq.JourneyFilter) bq.JourneyFilter {
+	if tag == "" {
+		return filter
+	}
+	filter.Tags = append(slices.Clip(filter.Tags), h.tagFilter(notes, tag))
+	return filter
+}
+
+// commuteFilter returns the journey filter for the commutes chart: the
+// journeys within rng tagged as commutes, given the annotations of their
+// card in notes.
+func (h *Handler) commuteFilter(notes []annotations.Annotation, rng DateRange, today time.Time) bq.JourneyFilter {
+	return h.tagged(notes, tags.Commute, rng.journeyFilter(today))
+}
+
+// tagOptions lists the tags offered by the tag filter: those of the rules in
+// configuration order, then those of the annotations in notes, together with
+// selected when it is not among them.
+func (h *Handler) tagOptions(notes []annotations.Annotation, selected string) []string {
+	options := h.opts.Tags.Tags()
+	for _, tag := range annotationTags(notes, selected) {
+		if !slices.Contains(options, tag) {
+			options = append(options, tag)
+		}
+	}
+	return options
+}
+
+// journeyTags returns the tags the rules assign to j.
+func journeyTags(rules tags.Rules, j bq.Journey) []string {
+	t, err := parseJourneyDate(j.Date)
+	if err != nil {
+		return nil
+	}
+	from, to := journeyStations(j.JourneyAction)
+	return rules.Match(tags.Journey{Date: t, StartTime: j.StartTime, From: from, To: to, Note: j.Note})
+}
@@ -0,0 +1,70 @@
+package web
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/its-the-vibe/pearl/internal/annotations"
+	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+	"github.com/its-the-vibe/pearl/internal/tags"
+)
+
+func TestTagged(t *testing.T) {
+	h := &Handler{opts: Options{Tags: tags.Rules{{Tag: "work", Stations: []string{"Bank"}}}}}
+	base := bq.JourneyFilter{From: mustDate("2024-03-01")}
+
+	if f := h.tagged(nil, "", base); len(f.Tags) != 0 {
+		t.Errorf("no tag: Tags = %+v, want none", f.Tags)
+	}
+	f := h.tagged(nil, "work", base)
+	if len(f.Tags) != 1 || len(f.Tags[0].Rules) != 1 || f.Tags[0].Rules[0].Stations[0] != "Bank" {
+		t.Errorf("work: Tags = %+v, want the work rule", f.Tags)
+	}
+	if !f.From.Equal(base.From) {
+		t.Errorf("work: From = %v, want %v", f.From, base.From)
+	}
+
+	// Annotations add the journeys annotated with the tag.
+	notes := []annotations.Annotation{{Date: mustDate("2024-03-05"), Tags: []string{"work"}}}
+	if f := h.tagged(notes, "work", base); f.Tags[0].Annotated == nil || len(f.Tags[0].Annotated.Days) != 1 {
+		t.Errorf("annotated work: Tags = %+v, want the annotated day", f.Tags)
+	}
+
+	// Tagged commutes need both tags.
+	both := h.tagged(nil, "work", h.commuteFilter(nil, DateRange{Days: 30}, mustDate("2024-03-31")))
+	if len(both.Tags) != 2 || both.Tags[0].Rules[0].Tag != tags.Commute || both.Tags[1].Rules[0].Tag != "work" {
+		t.Errorf("work commutes: Tags = %+v, want commute and work", both.Tags)
+	}
+}
+
+func TestTagOptions(t *testing.T) {
+	h := &Handler{opts: Options{Tags: tags.Rules{{Tag: "work"}, {Tag: "airport"}}}}
+	if got, want := h.tagOptions(nil, "strike"), []string{"work", "airport", "commute", "strike"}; !slices.Equal(got, want) {
+		t.Errorf("tagOptions = %q, want %q", got, want)
+	}
+	notes := []annotations.Annotation{{Tags: []string{"wfh", "work"}}}
+	if got, want := h.tagOptions(notes, ""), []string{"work", "airport", "commute", "wfh"}; !slices.Equal(got, want) {
+		t.Errorf("tagOptions with annotations = %q, want %q", got, want)
+	}
+}
+
+func TestJourneyTags(t *testing.T) {
+	rules := tags.Rules{
+		{Tag: "airport", Stations: []string{"Heathrow Terminals 2 + 3"}},
+		{Tag: "late", From: "22:00", To: "04:00"},
+	}
+	tests := []struct {
+		j    bq.Journey
+		want []string
+	}{
+		{bq.Journey{Date: "2024-03-05", StartTime: "08:10", JourneyAction: "Clapham Common to Heathrow Terminals 2 + 3"}, []string{"airport", "commute"}},
+		{bq.Journey{Date: "09-Mar-24", StartTime: "23:30", JourneyAction: "Bank to Clapham Common [No touch-out]"}, []string{"late"}},
+		{bq.Journey{Date: "2024-03-05", StartTime: "12:00", JourneyAction: "Entered Heathrow Terminals 2 + 3"}, []string{"airport"}},
+		{bq.Journey{Date: "not a date", StartTime: "08:10"}, nil},
+	}
+	for _, tt := range tests {
+		if got := journeyTags(rules, tt.j); !slices.Equal(got, tt.want) {
+			t.Errorf("journeyTags(%s %s %s) = %q, want %q", tt.j.Date, tt.j.StartTime, tt.j.JourneyAction, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package web
+
+import (
+	"html/template"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/its-the-vibe/pearl/internal/annotations"
+	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+	"github.com/its-the-vibe/pearl/internal/calendar"
+	"github.com/its-the-vibe/pearl/internal/stations"
+)
+
+// TestTemplatesRender executes each page template with representative data
+// to catch references to missing fields, which only fail at execution time.
+func TestTemplatesRender(t *testing.T) {
+	tmpl, err := template.ParseFS(templateFS, "templates/*.html")
+	if err != nil {
+		t.Fatalf("parsing templates: %v", err)
+	}
+
+	nav := Nav{
+		Active:  "overview",
+		Cards:   []CardOption{{ID: "a", Name: "A", Selected: true}, {ID: "b", Name: "B"}},
+		Compare: []CardOption{{ID: "b", Name: "B"}},
+		User:    "alice",
+		Tag:     "strike",
+		Tags:    []string{"commute", "strike"},
+	}
+
+	heatmap := buildHeatmapData(nil, []bq.BusJourney{{Date: time.Now().UTC().Format("2006-01-02"), JourneyAction: "Bus journey, route 88"}}, calendar.New([]calendar.DayOff{{Date: time.Now().UTC().Truncate(24 * time.Hour), Kind: calendar.KindLeave}}))
+	heatmap.Tag = "strike"
+	compareHeatmap := buildHeatmapData(nil, nil, nil)
+	heatmap.Compare = &compareHeatmap
+	markHeatmapAnnotations(&heatmap, newAnnotationIndex([]annotations.Annotation{{Date: time.Now().UTC().Truncate(24 * time.Hour), Tags: []string{"strike"}}}))
+	heatmap.Nav = nav
+
+	tue, _, _ := commuteWeekDates()
+	commutes := buildCommuteData([]bq.CommuteJourney{
+		{Date: tue.Format("2006-01-02"), StartTime: "08:00", EndTime: "08:45"},
+	}, nil, DateRange{})
+	compareCommutes := buildCommuteData(nil, nil, DateRange{Days: customRangeDays, From: tue})
+	compareCommutes.RangeForm = DateRangeForm{From: "2024-13-01", Error: "invalid from date"}
+	commutes.Anomalies = detectAnomalies(routePoints("A to B", 40, 40, 40, 40, 90))
+	commutes.Trend = buildCommuteTrend(trendPoints(30, 40, 50, 60), defaultTimeAxis, 3)
+	commutes.Compare = &compareCommutes
+	if len(commutes.Commutes) > 0 {
+		commutes.Commutes[0].Annotation = "strike"
+	}
+	commutes.Nav = nav
+
+	punchcard := buildPunchcardData([]bq.HourCount{{Weekday: time.Monday, Hour: 8, Count: 3, AvgMinutes: 30}}, DateRange{Days: 30}, punchcardColorDuration)
+	punchcard.Nav = nav
+
+	periods := buildPeriodsData(periodMonth, period{tue, tue}, period{tue.AddDate(0, -1, 0), tue.AddDate(0, -1, 0)}, nil, nil, []bq.DailyRating{{Date: tue, Rating: 4}})
+	periods.Nav = nav
+
+	attendance := buildAttendanceData([]bq.Journey{
+		{Date: tue.Format("2006-01-02"), StartTime: "08:00", EndTime: "08:45", JourneyAction: "Clapham Common to Bank"},
+	}, AttendanceOptions{
+		WorkStations:  []string{"Bank"},
+		WeeklyTarget:  3,
+		MonthlyTarget: 12,
+		Overrides:     map[string]AttendanceOverride{tue.AddDate(0, 0, 1).Format("2006-01-02"): {Office: true, Note: "cycled"}},
+	}, calendar.New([]calendar.DayOff{{Date: tue.AddDate(0, 0, 7), Kind: calendar.KindBankHoliday, Name: "Christmas Day"}}), DateRange{Days: 30}, tue.AddDate(0, 0, 14))
+	attendance.Nav = nav
+
+	dir, err := stations.Embedded()
+	if err != nil {
+		t.Fatalf("loading stations: %v", err)
+	}
+	stationMap := buildMapData([]bq.CommuteJourney{
+		{Date: tue.Format("2006-01-02"), StartTime: "08:00", EndTime: "08:45", JourneyAction: "Clapham Common to Bank"},
+		{Date: tue.Format("2006-01-02"), StartTime: "18:00", EndTime: "18:45", JourneyAction: "Bank to Gatwick Airport"},
+	}, dir, DateRange{Days: 30})
+	stationMap.Nav = nav
+
+	buses := buildBusData([]bq.BusJourney{
+		{Date: tue.Format("2006-01-02"), StartTime: "08:00", JourneyAction: "Bus journey, route 88", Charge: 1.75},
+		{Date: tue.Format("2006-01-02"), StartTime: "08:20", JourneyAction: "Bus journey, route 155"},
+	}, DateRange{Days: 30}, tue)
+	buses.Nav = nav
+
+	station := buildStationData("Bank", []bq.Journey{
+		{Date: tue.Format("2006-01-02"), StartTime: "08:00", EndTime: "08:45", JourneyAction: "Clapham Common to Bank", Charge: 2.8},
+		{Date: tue.Format("2006-01-02"), StartTime: "18:00", EndTime: "18:45", JourneyAction: "Bank to Clapham Common", Charge: 2.8},
+	}, dir, DateRange{Days: 30}, tue)
+	station.Nav = nav
+
+	journeys := buildJourneysData([]bq.Journey{
+		{Date: tue.Format("2006-01-02"), StartTime: "08:00", EndTime: "08:45", JourneyAction: "Clapham Common to Bank", Charge: 2.8, Balance: 17.2},
+		{Date: tue.Format("2006-01-02"), StartTime: "12:00", JourneyAction: "Auto top-up", Credit: 20, Balance: 20, Note: "lunch"},
+	}, 120, JourneysForm{Type: bq.JourneyTypeRail, Sort: bq.SortByCharge}, DateRange{Days: 30}, 2)
+	journeys.Rows[0].Tags = []string{"commute"}
+	journeys.Nav = nav
+
+	day := buildDayData(tue, tue.AddDate(0, 0, 1), []bq.Journey{
+		{Date: tue.Format("2006-01-02"), StartTime: "08:00", EndTime: "08:45", JourneyAction: "Clapham Common to Bank", Charge: 2.8, Balance: 17.2},
+		{Date: tue.Format("2006-01-02"), StartTime: "12:00", JourneyAction: "Auto top-up", Credit: 20, Balance: 37.2, Note: "lunch"},
+	}, []bq.DailyRating{{Date: tue, Rating: 4, Comment: "signal failure"}}, []annotations.Annotation{
+		{ID: "a", Date: tue, Tags: []string{"strike"}, Note: "walked"},
+		{ID: "b", Date: tue, StartTime: "08:00", Action: "Clapham Common to Bank", Note: "delayed"},
+	}, calendar.New([]calendar.DayOff{{Date: tue, Kind: calendar.KindLeave}}), map[string]AttendanceOverride{tue.Format("2006-01-02"): {Office: true}})
+	day.Annotating, day.FormError = true, "add a note or at least one tag"
+	day.Nav = nav
+
+	review := buildReviewData(tue.Year(), tue, []bq.DayCount{{Date: tue, Count: 2, Spend: 5.6}}, []bq.CommuteJourney{
+		{Date: tue.Format("2006-01-02"), StartTime: "08:00", EndTime: "08:45", JourneyAction: "Clapham Common to Bank"},
+	}, nil, []bq.DailyRating{{Date: tue, Rating: 4}})
+	review.Nav = nav
+	staticReview := review
+	staticReview.Static = true
+
+	calendarPage := buildCalendarData(tue.AddDate(0, 0, -tue.Day()+1), tue, []bq.DayCount{{Date: tue, Count: 2, Spend: 5.6, FirstTapIn: "08:00"}}, []bq.DailyRating{{Date: tue, Rating: 4}}, nil)
+	calendarPage.Nav = nav
+
+	digest := buildDigestData(periodWeek, digestPeriod(periodWeek, tue), []bq.Journey{
+		{Date: tue.Format("2006-01-02"), JourneyAction: "Bank to Clapham Common [No touch-out]", Charge: 8.8, Note: "capped"},
+	}, nil, "https://pearl.example.com")
+
+	pages := map[string]any{
+		"attendance.html": attendance,
+		"buses.html":      buses,
+		"calendar.html":   calendarPage,
+		"day.html":        day,
+		"digest.html":     digest,
+		"heatmap.html":    heatmap,
+		"journeys.html":   journeys,
+		"map.html":        stationMap,
+		"commutes.html":   commutes,
+		"periods.html":    periods,
+		"punchcard.html":  punchcard,
+		"review.html":     review,
+		"station.html":    station,
+		"stations.html":   buildStationReport([]bq.ActionCount{{Action: "Picadilly Circus to Gatwick Airport", Count: 1}}, nil),
+		"usage.html":      buildUsageData(nil),
+	}
+	for name, data := range pages {
+		if err := tmpl.ExecuteTemplate(io.Discard, name, data); err != nil {
+			t.Errorf("executing %s: %v", name, err)
+		}
+	}
+	if err := tmpl.ExecuteTemplate(io.Discard, "review.html", staticReview); err != nil {
+		t.Errorf("executing static review.html: %v", err)
+	}
+}
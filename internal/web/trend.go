@@ -0,0 +1,149 @@
+package web
+
+import (
+	"fmt"
+	"image"
+	"strconv"
+	"time"
+)
+
+// trendStableMinutes is the change in commute duration per month below which
+// the trend is reported as stable.
+const trendStableMinutes = 1.0
+
+// movingAverageWindows lists the selectable moving-average windows, in
+// commutes. 0 turns the moving-average lines off.
+var movingAverageWindows = []int{0, 3, 5, 10}
+
+// WindowOption represents a selectable moving-average window.
+type WindowOption struct {
+	Label    string
+	Window   int // 0 = off
+	Selected bool
+}
+
+// CommuteTrend holds the moving-average lines and linear trend drawn on the
+// commute chart.
+type CommuteTrend struct {
+	Window        int
+	StartPath     string // SVG path for the moving average of start times
+	EndPath       string // SVG path for the moving average start time plus moving average duration
+	Direction     string // "longer", "shorter" or "stable"; empty with too little data
+	PerMonth      string // change in duration per 30 days, e.g. "+2m / month"
+	WindowOptions []WindowOption
+}
+
+// parseWindowParam parses the "window" URL query parameter into a
+// moving-average window. Values other than the selectable windows return 0.
+func parseWindowParam(s string) int {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0
+	}
+	for _, w := range movingAverageWindows {
+		if w == n {
+			return n
+		}
+	}
+	return 0
+}
+
+// buildWindowOptions returns the moving-average window options with the
+// selected flag set on whichever option matches selected.
+func buildWindowOptions(selected int) []WindowOption {
+	options := make([]WindowOption, 0, len(movingAverageWindows))
+	for _, w := range movingAverageWindows {
+		label := "Off"
+		if w > 0 {
+			label = fmt.Sprintf("%d commutes", w)
+		}
+		options = append(options, WindowOption{Label: label, Window: w, Selected: w == selected})
+	}
+	return options
+}
+
+// buildCommuteTrend computes trailing moving averages over window commutes of
+// the start time and duration of points, which buildCommuteData sorts by date
+// and start time, together with the least-squares trend of duration over
+// time, drawn on axis. A window of 0 omits the moving-average lines.
+func buildCommuteTrend(points []CommutePoint, axis timeAxis, window int) CommuteTrend {
+	trend := CommuteTrend{
+		Window:        window,
+		WindowOptions: buildWindowOptions(window),
+	}
+
+	if window > 1 && len(points) >= window {
+		var starts, ends []image.Point
+		sumStart, sumMinutes := 0, 0
+		for i, p := range points {
+			sumStart += p.StartMinutes
+			sumMinutes += p.Minutes
+			if i >= window {
+				sumStart -= points[i-window].StartMinutes
+				sumMinutes -= points[i-window].Minutes
+			}
+			if i < window-1 {
+				continue
+			}
+			avgStart := float64(sumStart) / float64(window)
+			avgMinutes := float64(sumMinutes) / float64(window)
+			starts = append(starts, image.Pt(p.X, axis.y(int(avgStart+0.5))))
+			ends = append(ends, image.Pt(p.X, axis.y(int(avgStart+avgMinutes+0.5))))
+		}
+		trend.StartPath = smoothPath(starts)
+		trend.EndPath = smoothPath(ends)
+	}
+
+	if slope, ok := durationSlope(points); ok {
+		perMonth := slope * 30
+		switch {
+		case perMonth >= trendStableMinutes:
+			trend.Direction = "longer"
+		case perMonth <= -trendStableMinutes:
+			trend.Direction = "shorter"
+		default:
+			trend.Direction = "stable"
+		}
+		trend.PerMonth = fmt.Sprintf("%+.1fm / month", perMonth)
+	}
+	return trend
+}
+
+// durationSlope returns the least-squares slope of commute duration against
+// date, in minutes per day. It reports false when the commutes span fewer
+// than two distinct days.
+func durationSlope(points []CommutePoint) (float64, bool) {
+	var xs, ys []float64
+	var first time.Time
+	for _, p := range points {
+		t, err := time.Parse("2006-01-02", p.ISODate)
+		if err != nil {
+			continue
+		}
+		if len(xs) == 0 {
+			first = t
+		}
+		xs = append(xs, t.Sub(first).Hours()/24)
+		ys = append(ys, float64(p.Minutes))
+	}
+	if len(xs) < 2 {
+		return 0, false
+	}
+
+	n := float64(len(xs))
+	var sumX, sumY float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+	}
+	meanX, meanY := sumX/n, sumY/n
+	var cov, varX float64
+	for i := range xs {
+		cov += (xs[i] - meanX) * (ys[i] - meanY)
+		varX += (xs[i] - meanX) * (xs[i] - meanX)
+	}
+	if varX == 0 {
+		return 0, false
+	}
+	return cov / varX, true
+}
@@ -0,0 +1,163 @@
+package web
+
+import (
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+)
+
+// trendPoints returns one commute per day starting on 2024-03-05 with the
+// given durations, all starting at 08:00.
+func trendPoints(durations ...int) []CommutePoint {
+	start := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	points := make([]CommutePoint, len(durations))
+	for i, d := range durations {
+		points[i] = CommutePoint{
+			ISODate:      start.AddDate(0, 0, i).Format("2006-01-02"),
+			StartMinutes: 8 * 60,
+			Minutes:      d,
+			X:            svgPaddingLeft + i*svgBarStep + svgBarStep/2,
+		}
+	}
+	return points
+}
+
+func TestParseWindowParam(t *testing.T) {
+	tests := map[string]int{
+		"":    0,
+		"0":   0,
+		"3":   3,
+		"5":   5,
+		"10":  10,
+		"4":   0,
+		"abc": 0,
+	}
+	for in, want := range tests {
+		if got := parseWindowParam(in); got != want {
+			t.Errorf("parseWindowParam(%q) = %d, want %d", in, got, want)
+		}
+	}
+}
+
+func TestBuildWindowOptions(t *testing.T) {
+	opts := buildWindowOptions(5)
+	if len(opts) != len(movingAverageWindows) {
+		t.Fatalf("expected %d options, got %d", len(movingAverageWindows), len(opts))
+	}
+	for _, o := range opts {
+		if o.Selected != (o.Window == 5) {
+			t.Errorf("option %d: Selected = %v", o.Window, o.Selected)
+		}
+	}
+	if opts[0].Label != "Off" || opts[2].Label != "5 commutes" {
+		t.Errorf("unexpected labels %q, %q", opts[0].Label, opts[2].Label)
+	}
+}
+
+func TestBuildCommuteTrend_MovingAverage(t *testing.T) {
+	points := trendPoints(30, 40, 50, 60)
+
+	trend := buildCommuteTrend(points, defaultTimeAxis, 3)
+	// Two windows of three: one point per complete window.
+	if n := strings.Count(trend.StartPath, " C "); n != 1 {
+		t.Errorf("expected 1 curve segment in StartPath, got %d: %q", n, trend.StartPath)
+	}
+	// Start times are constant, so the start line is flat at 08:00.
+	y := defaultTimeAxis.y(8 * 60)
+	if !strings.HasPrefix(trend.StartPath, "M ") || !strings.HasSuffix(trend.StartPath, " "+strconv.Itoa(points[3].X)+","+strconv.Itoa(y)) {
+		t.Errorf("StartPath = %q, want flat line at y=%d", trend.StartPath, y)
+	}
+	// The last window averages 40, 50 and 60 minutes.
+	wantEnd := defaultTimeAxis.y(8*60 + 50)
+	if !strings.HasSuffix(trend.EndPath, ","+strconv.Itoa(wantEnd)) {
+		t.Errorf("EndPath = %q, want to end at y=%d", trend.EndPath, wantEnd)
+	}
+}
+
+func TestBuildCommuteTrend_NoWindow(t *testing.T) {
+	trend := buildCommuteTrend(trendPoints(30, 40, 50), defaultTimeAxis, 0)
+	if trend.StartPath != "" || trend.EndPath != "" {
+		t.Errorf("expected no moving-average lines, got %q / %q", trend.StartPath, trend.EndPath)
+	}
+	// The trend indicator is still computed.
+	if trend.Direction != "longer" {
+		t.Errorf("Direction = %q, want %q", trend.Direction, "longer")
+	}
+}
+
+func TestBuildCommuteTrend_WindowLargerThanData(t *testing.T) {
+	trend := buildCommuteTrend(trendPoints(30, 40), defaultTimeAxis, 5)
+	if trend.StartPath != "" {
+		t.Errorf("expected no line with fewer commutes than the window, got %q", trend.StartPath)
+	}
+}
+
+func TestBuildCommuteTrend_Direction(t *testing.T) {
+	tests := []struct {
+		name      string
+		durations []int
+		direction string
+		perMonth  string
+	}{
+		{"longer", []int{30, 31, 32}, "longer", "+30.0m / month"},
+		{"shorter", []int{60, 50, 40}, "shorter", "-300.0m / month"},
+		{"stable", []int{45, 45, 45}, "stable", "+0.0m / month"},
+		{"single commute", []int{45}, "", ""},
+	}
+	for _, tt := range tests {
+		trend := buildCommuteTrend(trendPoints(tt.durations...), defaultTimeAxis, 0)
+		if trend.Direction != tt.direction || trend.PerMonth != tt.perMonth {
+			t.Errorf("%s: got %q / %q, want %q / %q", tt.name, trend.Direction, trend.PerMonth, tt.direction, tt.perMonth)
+		}
+	}
+}
+
+func TestDurationSlope(t *testing.T) {
+	slope, ok := durationSlope(trendPoints(10, 12, 14, 16))
+	if !ok || math.Abs(slope-2) > 1e-9 {
+		t.Errorf("durationSlope = %v, %v; want 2, true", slope, ok)
+	}
+
+	// Several commutes on the same day have no spread in time.
+	same := trendPoints(10, 20)
+	same[1].ISODate = same[0].ISODate
+	if _, ok := durationSlope(same); ok {
+		t.Error("expected no slope for commutes on a single day")
+	}
+}
+
+func TestBuildCommuteTrend_OysterDatesInDateOrder(t *testing.T) {
+	// Sorted by the raw date column, as the commute query returns them.
+	journeys := []bq.CommuteJourney{
+		{Date: "01-Mar-24", StartTime: "08:00", EndTime: "09:00"},
+		{Date: "28-Feb-24", StartTime: "08:00", EndTime: "08:30"},
+		{Date: "29-Feb-24", StartTime: "08:10", EndTime: "08:40"},
+		{Date: "29-Feb-24", StartTime: "08:00", EndTime: "08:30"},
+	}
+	data := buildCommuteData(journeys, nil, DateRange{})
+
+	var got []string
+	for i, p := range data.Commutes {
+		got = append(got, p.ISODate+" "+p.Start)
+		if i > 0 && p.X < data.Commutes[i-1].X {
+			t.Errorf("commute %d at x=%d is left of the previous one at x=%d", i, p.X, data.Commutes[i-1].X)
+		}
+	}
+	want := []string{"2024-02-28 08:00", "2024-02-29 08:00", "2024-02-29 08:10", "2024-03-01 08:00"}
+	if !slices.Equal(got, want) {
+		t.Errorf("commutes = %q, want %q", got, want)
+	}
+
+	// The last window of three averages the last three days, ending at the
+	// 1 March bar: (30 + 30 + 60) / 3 = 40 minutes after an 08:03 start.
+	trend := buildCommuteTrend(data.Commutes, data.Axis, 3)
+	last := data.Commutes[3]
+	if want := " " + strconv.Itoa(last.X) + "," + strconv.Itoa(data.Axis.y(8*60+3+40)); !strings.HasSuffix(trend.EndPath, want) {
+		t.Errorf("EndPath = %q, want it to end with %q", trend.EndPath, want)
+	}
+}
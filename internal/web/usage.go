@@ -0,0 +1,135 @@
+package web
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+
+	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+)
+
+// UsageRow is a single page's query usage on a single day.
+type UsageRow struct {
+	Day            string // e.g. "2024-03-05"
+	Page           string
+	Queries        int
+	BytesProcessed string // human-readable, e.g. "1.2 GB"
+	BytesBilled    string
+}
+
+// UsageTotal summarises a page's query usage since the server started.
+type UsageTotal struct {
+	Page           string
+	Queries        int
+	BytesProcessed string
+	BytesBilled    string
+	Share          int // percentage of all bytes processed, 0–100
+}
+
+// UsageData is passed to the usage template.
+type UsageData struct {
+	Rows                []UsageRow
+	Totals              []UsageTotal
+	TotalQueries        int
+	TotalBytesProcessed string
+	TotalBytesBilled    string
+}
+
+func (h *Handler) handleUsage(w http.ResponseWriter, _ *http.Request) {
+	// Every card's client shares the same usage tracker.
+	data := buildUsageData(h.cards[0].Client.Usage().Snapshot())
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.tmpl.ExecuteTemplate(w, "usage.html", data); err != nil {
+		slog.Error("rendering usage template", "error", err)
+	}
+}
+
+// buildUsageData summarises usage records into per-day rows (most recent
+// first) and per-page totals ordered by bytes processed.
+func buildUsageData(records []bq.UsageRecord) UsageData {
+	type total struct {
+		queries   int
+		processed int64
+		billed    int64
+	}
+	totals := make(map[string]*total)
+	var allProcessed, allBilled int64
+	var allQueries int
+
+	rows := make([]UsageRow, 0, len(records))
+	for i := len(records) - 1; i >= 0; i-- {
+		rec := records[i]
+		rows = append(rows, UsageRow{
+			Day:            rec.Day.Format("2006-01-02"),
+			Page:           rec.Page,
+			Queries:        rec.Queries,
+			BytesProcessed: formatBytes(rec.BytesProcessed),
+			BytesBilled:    formatBytes(rec.BytesBilled),
+		})
+
+		t, ok := totals[rec.Page]
+		if !ok {
+			t = &total{}
+			totals[rec.Page] = t
+		}
+		t.queries += rec.Queries
+		t.processed += rec.BytesProcessed
+		t.billed += rec.BytesBilled
+		allQueries += rec.Queries
+		allProcessed += rec.BytesProcessed
+		allBilled += rec.BytesBilled
+	}
+
+	pages := make([]string, 0, len(totals))
+	for page := range totals {
+		pages = append(pages, page)
+	}
+	sort.Slice(pages, func(i, j int) bool {
+		pi, pj := totals[pages[i]], totals[pages[j]]
+		if pi.processed != pj.processed {
+			return pi.processed > pj.processed
+		}
+		return pages[i] < pages[j]
+	})
+
+	var pageTotals []UsageTotal
+	for _, page := range pages {
+		t := totals[page]
+		share := 0
+		if allProcessed > 0 {
+			share = int(t.processed * 100 / allProcessed)
+		}
+		pageTotals = append(pageTotals, UsageTotal{
+			Page:           page,
+			Queries:        t.queries,
+			BytesProcessed: formatBytes(t.processed),
+			BytesBilled:    formatBytes(t.billed),
+			Share:          share,
+		})
+	}
+
+	return UsageData{
+		Rows:                rows,
+		Totals:              pageTotals,
+		TotalQueries:        allQueries,
+		TotalBytesProcessed: formatBytes(allProcessed),
+		TotalBytesBilled:    formatBytes(allBilled),
+	}
+}
+
+// formatBytes converts a byte count into a human-readable string using
+// decimal units, such as "512 B" or "1.5 GB".
+func formatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
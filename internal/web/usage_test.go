@@ -0,0 +1,64 @@
+package web
+
+import (
+	"testing"
+	"time"
+
+	bq "github.com/its-the-vibe/pearl/internal/bigquery"
+)
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0, "0 B"},
+		{999, "999 B"},
+		{1000, "1.0 kB"},
+		{1500000, "1.5 MB"},
+		{2 * 1000 * 1000 * 1000, "2.0 GB"},
+	}
+	for _, tt := range tests {
+		if got := formatBytes(tt.n); got != tt.want {
+			t.Errorf("formatBytes(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestBuildUsageData(t *testing.T) {
+	day1 := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+
+	records := []bq.UsageRecord{
+		{Page: "commutes", Day: day1, Queries: 2, BytesProcessed: 3000, BytesBilled: 10000},
+		{Page: "heatmap", Day: day1, Queries: 1, BytesProcessed: 1000},
+		{Page: "commutes", Day: day2, Queries: 1, BytesProcessed: 6000},
+	}
+
+	data := buildUsageData(records)
+
+	if data.TotalQueries != 4 {
+		t.Errorf("TotalQueries = %d, want 4", data.TotalQueries)
+	}
+	if data.TotalBytesProcessed != "10.0 kB" {
+		t.Errorf("TotalBytesProcessed = %q, want %q", data.TotalBytesProcessed, "10.0 kB")
+	}
+	if len(data.Rows) != 3 {
+		t.Fatalf("expected 3 rows, got %d", len(data.Rows))
+	}
+	// Rows are ordered most recent first.
+	if data.Rows[0].Day != "2024-03-05" {
+		t.Errorf("Rows[0].Day = %q, want %q", data.Rows[0].Day, "2024-03-05")
+	}
+
+	if len(data.Totals) != 2 {
+		t.Fatalf("expected 2 page totals, got %d", len(data.Totals))
+	}
+	// Totals are ordered by bytes processed, largest first.
+	if data.Totals[0].Page != "commutes" || data.Totals[0].Queries != 3 {
+		t.Errorf("Totals[0] = %+v, want commutes with 3 queries", data.Totals[0])
+	}
+	if data.Totals[0].Share != 90 || data.Totals[1].Share != 10 {
+		t.Errorf("shares = %d/%d, want 90/10", data.Totals[0].Share, data.Totals[1].Share)
+	}
+}